@@ -0,0 +1,333 @@
+package api
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/labstack/echo/v4"
+)
+
+const jsonRPCVersion = "2.0"
+
+// Error codes as defined by the JSON-RPC 2.0 specification.
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+	// ErrCodeServer is used for all errors returned by the handler logic.
+	ErrCodeServer = -32000
+)
+
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON always includes the result of a successful response, even if
+// it is null. JSON-RPC 2.0 requires the result member on success and forbids
+// it on error.
+func (r RPCResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *RPCError       `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.JSONRPC, r.Error, r.ID})
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  any             `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.ID})
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+type rpcMethod func(s *Server, params []json.RawMessage) (any, error)
+
+var rpcMethods = map[string]rpcMethod{
+	"account_getNonce":    rpcGetNonce,
+	"chain_getBlock":      rpcGetBlock,
+	"chain_getTx":         rpcGetTx,
+	"chain_getReceipt":    rpcGetReceipt,
+	"chain_getStatus":     rpcGetStatus,
+	"chain_getValidators": rpcGetValidators,
+	"tx_send":             rpcSendTx,
+	"voting_getBudget":    rpcGetBudget,
+	"voting_getElection":  rpcGetElection,
+	"voting_getResults":   rpcGetResults,
+}
+
+// handleJSONRPC serves JSON-RPC 2.0 requests. Both single requests and
+// batches are supported, notifications (requests without id) get no response.
+func (s *Server) handleJSONRPC(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusOK, newRPCErrorResponse(nil, ErrCodeParse, err.Error()))
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return c.JSON(http.StatusOK, newRPCErrorResponse(nil, ErrCodeParse, err.Error()))
+		}
+		if len(batch) == 0 {
+			return c.JSON(http.StatusOK, newRPCErrorResponse(nil, ErrCodeInvalidRequest, "empty batch"))
+		}
+
+		responses := []*RPCResponse{}
+		for _, raw := range batch {
+			if resp := s.processRPC(raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+
+		if len(responses) == 0 {
+			return c.NoContent(http.StatusNoContent)
+		}
+
+		return c.JSON(http.StatusOK, responses)
+	}
+
+	resp := s.processRPC(body)
+	if resp == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) processRPC(raw json.RawMessage) *RPCResponse {
+	var req RPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		// Entries of a batch are valid JSON, but not necessarily objects.
+		if !json.Valid(raw) {
+			return newRPCErrorResponse(nil, ErrCodeParse, err.Error())
+		}
+		return newRPCErrorResponse(nil, ErrCodeInvalidRequest, err.Error())
+	}
+
+	if req.JSONRPC != jsonRPCVersion || len(req.Method) == 0 {
+		return newRPCErrorResponse(req.ID, ErrCodeInvalidRequest, "invalid request")
+	}
+
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		return newRPCErrorResponse(req.ID, ErrCodeMethodNotFound, fmt.Sprintf("method %s not found", req.Method))
+	}
+
+	// Only positional params are supported.
+	var params []json.RawMessage
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return newRPCErrorResponse(req.ID, ErrCodeInvalidParams, "params should be an array")
+		}
+	}
+
+	result, err := method(s, params)
+
+	// requests without an id are notifications.
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	if err != nil {
+		if rerr, ok := err.(*RPCError); ok {
+			return newRPCErrorResponse(req.ID, rerr.Code, rerr.Message)
+		}
+		return newRPCErrorResponse(req.ID, ErrCodeServer, err.Error())
+	}
+
+	return &RPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Result:  result,
+		ID:      req.ID,
+	}
+}
+
+func newRPCErrorResponse(id json.RawMessage, code int, msg string) *RPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &RPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Error: &RPCError{
+			Code:    code,
+			Message: msg,
+		},
+		ID: id,
+	}
+}
+
+func invalidParams(format string, a ...any) *RPCError {
+	return &RPCError{
+		Code:    ErrCodeInvalidParams,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func stringParam(params []json.RawMessage, i int) (string, error) {
+	if len(params) <= i {
+		return "", invalidParams("missing param %d", i)
+	}
+
+	var v string
+	if err := json.Unmarshal(params[i], &v); err != nil {
+		return "", invalidParams("param %d should be a string", i)
+	}
+
+	return v, nil
+}
+
+// rpcGetBlock accepts either the block height as number or the block hash.
+func rpcGetBlock(s *Server, params []json.RawMessage) (any, error) {
+	if len(params) == 0 {
+		return nil, invalidParams("missing block hash or height")
+	}
+
+	var height uint32
+	if err := json.Unmarshal(params[0], &height); err == nil {
+		return s.getBlock(strconv.Itoa(int(height)))
+	}
+
+	hashOrID, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getBlock(hashOrID)
+}
+
+// rpcGetStatus returns the chain ID, genesis hash, height and finalized
+// height of the node.
+func rpcGetStatus(s *Server, params []json.RawMessage) (any, error) {
+	return s.getStatus(), nil
+}
+
+// rpcGetValidators accepts an optional block height, the validator set of
+// the next block is returned if it is omitted.
+func rpcGetValidators(s *Server, params []json.RawMessage) (any, error) {
+	height := s.bc.Height() + 1
+	if len(params) > 0 {
+		if err := json.Unmarshal(params[0], &height); err != nil {
+			return nil, invalidParams("param 0 should be a block height")
+		}
+	}
+
+	return s.getValidators(height), nil
+}
+
+func rpcGetTx(s *Server, params []json.RawMessage) (any, error) {
+	hash, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getTx(hash)
+}
+
+func rpcGetNonce(s *Server, params []json.RawMessage) (any, error) {
+	address, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getNonce(address)
+}
+
+func rpcGetReceipt(s *Server, params []json.RawMessage) (any, error) {
+	hash, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getReceipt(hash)
+}
+
+// rpcSendTx expects the hex encoded gob transaction, the same encoding that
+// is used by POST /tx.
+func rpcSendTx(s *Server, params []json.RawMessage) (any, error) {
+	txHex, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, invalidParams("invalid transaction encoding: %s", err)
+	}
+
+	tx := &core.Transaction{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(tx); err != nil {
+		return nil, invalidParams("invalid transaction: %s", err)
+	}
+
+	hash, err := s.sendTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.String(), nil
+}
+
+func rpcGetElection(s *Server, params []json.RawMessage) (any, error) {
+	electionID, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	includeCandidates := false
+	if len(params) > 1 {
+		if err := json.Unmarshal(params[1], &includeCandidates); err != nil {
+			return nil, invalidParams("param 1 should be a boolean")
+		}
+	}
+
+	return s.getElection(electionID, includeCandidates)
+}
+
+func rpcGetBudget(s *Server, params []json.RawMessage) (any, error) {
+	electionID, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getElectionBudget(electionID)
+}
+
+func rpcGetResults(s *Server, params []json.RawMessage) (any, error) {
+	electionID, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getElectionResults(electionID)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRPCGetBlock(t *testing.T) {
+	s := newTestServer(t)
+	genesis, err := s.bc.GetBlock(0)
+	assert.Nil(t, err)
+
+	resp := doRPC(t, s, `{"jsonrpc":"2.0","method":"chain_getBlock","params":[0],"id":1}`)
+
+	var res RPCResponse
+	assert.Nil(t, json.Unmarshal(resp, &res))
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "1", string(res.ID))
+
+	block := res.Result.(map[string]any)
+	assert.Equal(t, genesis.Hash(core.BlockHasher{}).String(), block["Hash"])
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := doRPC(t, s, `[
+		{"jsonrpc":"2.0","method":"chain_getBlock","params":[0],"id":1},
+		{"jsonrpc":"2.0","method":"voting_getElection","params":["nope"],"id":2},
+		{"jsonrpc":"2.0","method":"foo","id":3},
+		{"jsonrpc":"2.0","method":"chain_getBlock","params":[0]}
+	]`)
+
+	var res []RPCResponse
+	assert.Nil(t, json.Unmarshal(resp, &res))
+	assert.Equal(t, 3, len(res))
+	assert.Nil(t, res[0].Error)
+	assert.Equal(t, ErrCodeServer, res[1].Error.Code)
+	assert.Equal(t, ErrCodeMethodNotFound, res[2].Error.Code)
+}
+
+func TestJSONRPCInvalidRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	var res RPCResponse
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `{"jsonrpc":"2.0","method":`), &res))
+	assert.Equal(t, ErrCodeParse, res.Error.Code)
+
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `"foo"`), &res))
+	assert.Equal(t, ErrCodeInvalidRequest, res.Error.Code)
+
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `[]`), &res))
+	assert.Equal(t, ErrCodeInvalidRequest, res.Error.Code)
+
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `{"jsonrpc":"2.0","method":"chain_getTx","params":[1],"id":1}`), &res))
+	assert.Equal(t, ErrCodeInvalidParams, res.Error.Code)
+}
+
+func TestJSONRPCResponseMembers(t *testing.T) {
+	// A successful call always has a result, even if it is null.
+	b, err := json.Marshal(&RPCResponse{JSONRPC: jsonRPCVersion, ID: json.RawMessage("1")})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":null,"id":1}`, string(b))
+
+	// An error never has one.
+	b, err = json.Marshal(newRPCErrorResponse(nil, ErrCodeParse, "parse error"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`, string(b))
+}
+
+func TestJSONRPCSendTx(t *testing.T) {
+	s := newTestServer(t)
+
+	privKey := crypto.GeneratePrivateKey()
+	tx := core.NewTransaction([]byte("foo"))
+	tx.From = privKey.PublicKey()
+	tx.ChainID = "test"
+	assert.Nil(t, tx.Sign(privKey))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(core.NewGobTxEncoder(buf)))
+
+	body := `{"jsonrpc":"2.0","method":"tx_send","params":["` + hex.EncodeToString(buf.Bytes()) + `"],"id":"a"}`
+	var res RPCResponse
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, body), &res))
+	assert.Nil(t, res.Error)
+
+	received := <-s.txChan
+	assert.Nil(t, received.Verify())
+	assert.Equal(t, tx.Hash(core.TxHasher{}).String(), res.Result)
+}
+
+func newTestServer(t *testing.T) *Server {
+	bc, err := core.NewBlockchain(log.NewNopLogger(), &core.Genesis{ChainID: "test"})
+	assert.Nil(t, err)
+
+	return NewServer(ServerConfig{Logger: log.NewNopLogger()}, bc, make(chan *core.Transaction, 1))
+}
+
+func doRPC(t *testing.T, s *Server, body string) []byte {
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	assert.Nil(t, s.handleJSONRPC(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	return rec.Body.Bytes()
+}
+
+func TestJSONRPCGetValidators(t *testing.T) {
+	s := newTestServer(t)
+	privKey := crypto.GeneratePrivateKey()
+	s.bc.SetValidatorSet(core.NewValidatorSet(privKey.PublicKey()))
+
+	var res RPCResponse
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `{"jsonrpc":"2.0","method":"chain_getValidators","params":[10],"id":1}`), &res))
+	assert.Nil(t, res.Error)
+
+	result := res.Result.(map[string]any)
+	assert.Equal(t, float64(10), result["height"])
+	assert.Equal(t, []any{privKey.PublicKey().String()}, result["validators"])
+	assert.Equal(t, float64(1), result["quorum"])
+}
+
+func TestJSONRPCGetReceipt(t *testing.T) {
+	s := newTestServer(t)
+
+	res := struct {
+		Result ReceiptResponse
+	}{}
+	assert.Nil(t, json.Unmarshal(doRPC(t, s, `{"jsonrpc":"2.0","method":"chain_getReceipt","params":["0000000000000000000000000000000000000000000000000000000000000000"],"id":1}`), &res))
+	assert.Equal(t, ReceiptStatusUnknown, res.Result.Status)
+}
+
+func TestJSONRPCGetStatus(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := doRPC(t, s, `{"jsonrpc":"2.0","method":"chain_getStatus","id":1}`)
+
+	var res struct {
+		Result StatusResponse `json:"result"`
+	}
+	assert.Nil(t, json.Unmarshal(resp, &res))
+	assert.Equal(t, "test", res.Result.ChainID)
+	assert.Equal(t, s.bc.Genesis().Block().Hash(core.BlockHasher{}).String(), res.Result.GenesisHash)
+	assert.Equal(t, uint32(0), res.Result.Height)
+}
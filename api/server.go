@@ -4,6 +4,7 @@ import (
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -37,6 +38,60 @@ type Block struct {
 	TxResponse TxResponse
 }
 
+// Receipt statuses of transactions that are not on the chain.
+const (
+	ReceiptStatusPending = "pending"
+	ReceiptStatusUnknown = "unknown"
+)
+
+// ReceiptResponse represents the outcome of a transaction. The status is
+// either success or failed for transactions in a block, pending for
+// transactions in the mempool and unknown otherwise.
+type ReceiptResponse struct {
+	TxHash      string          `json:"txHash"`
+	Status      string          `json:"status"`
+	BlockHeight uint32          `json:"blockHeight,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	GasUsed     uint64          `json:"gasUsed,omitempty"`
+	Fee         uint64          `json:"fee,omitempty"`
+	Events      []EventResponse `json:"events,omitempty"`
+}
+
+// EventResponse represents a voting event emitted by a transaction
+type EventResponse struct {
+	Type        string `json:"type"`
+	ElectionID  string `json:"electionId,omitempty"`
+	VoterID     string `json:"voterId,omitempty"`
+	CandidateID string `json:"candidateId,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// NonceResponse represents the nonce the next transaction of an address
+// has to use. Nonce takes the pending transactions of the address into
+// account, ConfirmedNonce only the transactions on the chain.
+type NonceResponse struct {
+	Address        string `json:"address"`
+	Nonce          uint64 `json:"nonce"`
+	ConfirmedNonce uint64 `json:"confirmedNonce"`
+}
+
+// StatusResponse represents the identity of the chain and its progress.
+// Nodes only sync with nodes of the same chain ID and genesis hash.
+type StatusResponse struct {
+	ChainID         string `json:"chainId"`
+	GenesisHash     string `json:"genesisHash"`
+	Height          uint32 `json:"height"`
+	FinalizedHeight uint32 `json:"finalizedHeight"`
+}
+
+// ValidatorSetResponse represents the validator set that is active at a
+// height
+type ValidatorSetResponse struct {
+	Height     uint32   `json:"height"`
+	Validators []string `json:"validators"`
+	Quorum     int      `json:"quorum"`
+}
+
 // VoterResponse represents voter information returned by the API
 type VoterResponse struct {
 	ID          string `json:"id"`
@@ -71,6 +126,15 @@ type ElectionResponse struct {
 	VoteCounts  map[string]uint64   `json:"voteCounts,omitempty"`
 }
 
+// BudgetResponse represents the sponsorship budget of an election that
+// pays the fees of sponsored votes and voter registrations.
+type BudgetResponse struct {
+	ElectionID string   `json:"electionId"`
+	Balance    uint64   `json:"balance"`
+	Spent      uint64   `json:"spent"`
+	Paymasters []string `json:"paymasters"`
+}
+
 // ElectionResultsResponse represents election results returned by the API
 type ElectionResultsResponse struct {
 	ElectionID string            `json:"electionId"`
@@ -115,9 +179,19 @@ type ApprovalRequest struct {
 	Approve    bool   `json:"approve"`
 }
 
+// PendingPool holds the transactions that are waiting to be included in a
+// block.
+type PendingPool interface {
+	IsPending(hash types.Hash) bool
+	Pending() []*core.Transaction
+}
+
 type ServerConfig struct {
 	Logger     log.Logger
 	ListenAddr string
+	// Mempool is used to check new transactions against the pending ones and
+	// to report transactions that are not in a block yet, it is optional.
+	Mempool PendingPool
 }
 
 type Server struct {
@@ -139,7 +213,12 @@ func (s *Server) Start() error {
 
 	e.GET("/block/:hashorid", s.handleGetBlock)
 	e.GET("/tx/:hash", s.handleGetTx)
+	e.GET("/tx/:hash/receipt", s.handleGetReceipt)
 	e.POST("/tx", s.handlePostTx)
+	e.GET("/status", s.handleGetStatus)
+	e.GET("/validators", s.handleGetValidators)
+	e.GET("/account/:address/nonce", s.handleGetNonce)
+	e.POST("/rpc", s.handleJSONRPC)
 
 	// Voting API endpoints
 	e.POST("/voting/register/voter", s.handleRegisterVoter)
@@ -148,6 +227,7 @@ func (s *Server) Start() error {
 	e.POST("/voting/vote", s.handleCastVote)
 	e.GET("/voting/election/:id", s.handleGetElection)
 	e.GET("/voting/election/:id/results", s.handleGetElectionResults)
+	e.GET("/voting/election/:id/budget", s.handleGetElectionBudget)
 	e.GET("/voting/voter/:id", s.handleGetVoter)
 	e.GET("/voting/candidate/:electionId/:id", s.handleGetCandidate)
 	e.POST("/voting/approve/voter", s.handleApproveVoter)
@@ -161,53 +241,217 @@ func (s *Server) handlePostTx(c echo.Context) error {
 	if err := gob.NewDecoder(c.Request().Body).Decode(tx); err != nil {
 		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
 	}
-	s.txChan <- tx
+	if _, err := s.sendTx(tx); err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+	}
 
 	return nil
 }
 
 func (s *Server) handleGetTx(c echo.Context) error {
-	hash := c.Param("hash")
-
-	b, err := hex.DecodeString(hash)
+	tx, err := s.getTx(c.Param("hash"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+		return writeError(c, err)
 	}
 
-	tx, err := s.bc.GetTxByHash(types.HashFromBytes(b))
+	return c.JSON(http.StatusOK, tx)
+}
+
+func (s *Server) handleGetReceipt(c echo.Context) error {
+	receipt, err := s.getReceipt(c.Param("hash"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+		return writeError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, tx)
+	return c.JSON(http.StatusOK, receipt)
 }
 
 func (s *Server) handleGetBlock(c echo.Context) error {
-	hashOrID := c.Param("hashorid")
+	block, err := s.getBlock(c.Param("hashorid"))
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, block)
+}
+
+// sendTx checks if the transaction would be admitted to the mempool, hands
+// it over to the node and returns its hash.
+func (s *Server) sendTx(tx *core.Transaction) (types.Hash, error) {
+	var pending []*core.Transaction
+	if s.Mempool != nil {
+		pending = s.Mempool.Pending()
+	}
+
+	if err := s.bc.CheckTransaction(tx, pending); err != nil {
+		return types.Hash{}, err
+	}
+
+	s.txChan <- tx
+
+	return tx.Hash(core.TxHasher{}), nil
+}
+
+func (s *Server) getTx(hash string) (*core.Transaction, error) {
+	h, err := decodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.bc.GetTxByHash(h)
+	if err != nil {
+		return nil, newStatusError(http.StatusBadRequest, err)
+	}
+
+	return tx, nil
+}
+
+func (s *Server) getReceipt(hash string) (*ReceiptResponse, error) {
+	h, err := decodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := s.bc.GetReceipt(h)
+	if err != nil {
+		status := ReceiptStatusUnknown
+		if s.Mempool != nil && s.Mempool.IsPending(h) {
+			status = ReceiptStatusPending
+		}
+
+		return &ReceiptResponse{TxHash: h.String(), Status: status}, nil
+	}
+
+	events := make([]EventResponse, len(receipt.Events))
+	for i, ev := range receipt.Events {
+		events[i] = EventResponse{
+			Type:        string(ev.Type),
+			ElectionID:  ev.ElectionID,
+			VoterID:     ev.VoterID,
+			CandidateID: ev.CandidateID,
+			Timestamp:   ev.Timestamp,
+		}
+	}
 
+	return &ReceiptResponse{
+		TxHash:      h.String(),
+		Status:      receipt.Status.String(),
+		BlockHeight: receipt.BlockHeight,
+		Error:       receipt.Error,
+		GasUsed:     receipt.GasUsed,
+		Fee:         receipt.Fee,
+		Events:      events,
+	}, nil
+}
+
+func (s *Server) getBlock(hashOrID string) (*Block, error) {
 	height, err := strconv.Atoi(hashOrID)
 	// If the error is nil we can assume the height of the block is given.
 	if err == nil {
 		block, err := s.bc.GetBlock(uint32(height))
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+			return nil, newStatusError(http.StatusBadRequest, err)
 		}
 
-		return c.JSON(http.StatusOK, intoJSONBlock(block))
+		jsonBlock := intoJSONBlock(block)
+		return &jsonBlock, nil
 	}
 
 	// otherwise assume its the hash
-	b, err := hex.DecodeString(hashOrID)
+	h, err := decodeHash(hashOrID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+		return nil, err
 	}
 
-	block, err := s.bc.GetBlockByHash(types.HashFromBytes(b))
+	block, err := s.bc.GetBlockByHash(h)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+		return nil, newStatusError(http.StatusBadRequest, err)
+	}
+
+	jsonBlock := intoJSONBlock(block)
+	return &jsonBlock, nil
+}
+
+func (s *Server) handleGetNonce(c echo.Context) error {
+	nonce, err := s.getNonce(c.Param("address"))
+	if err != nil {
+		return writeError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, intoJSONBlock(block))
+	return c.JSON(http.StatusOK, nonce)
+}
+
+func (s *Server) getNonce(address string) (*NonceResponse, error) {
+	addr, err := decodeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed := s.bc.NextNonce(addr)
+
+	// Pending transactions that follow the confirmed nonce without a gap
+	// use up the nonces after it.
+	nonce := confirmed
+	if s.Mempool != nil {
+		pending := make(map[uint64]bool)
+		for _, tx := range s.Mempool.Pending() {
+			if tx.From != nil && tx.From.Address() == addr {
+				pending[tx.Nonce] = true
+			}
+		}
+		for pending[nonce] {
+			nonce++
+		}
+	}
+
+	return &NonceResponse{
+		Address:        addr.String(),
+		Nonce:          nonce,
+		ConfirmedNonce: confirmed,
+	}, nil
+}
+
+func (s *Server) handleGetStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.getStatus())
+}
+
+func (s *Server) getStatus() *StatusResponse {
+	return &StatusResponse{
+		ChainID:         s.bc.ChainID(),
+		GenesisHash:     s.bc.GenesisHash().String(),
+		Height:          s.bc.Height(),
+		FinalizedHeight: s.bc.FinalizedHeight(),
+	}
+}
+
+// handleGetValidators returns the validator set at the height given by the
+// height query parameter, or the set of the next block if none is given.
+func (s *Server) handleGetValidators(c echo.Context) error {
+	height := s.bc.Height() + 1
+	if h := c.QueryParam("height"); len(h) > 0 {
+		n, err := strconv.ParseUint(h, 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, APIError{Error: "invalid height"})
+		}
+		height = uint32(n)
+	}
+
+	return c.JSON(http.StatusOK, s.getValidators(height))
+}
+
+func (s *Server) getValidators(height uint32) *ValidatorSetResponse {
+	vs := s.bc.ValidatorSetAt(height)
+
+	validators := make([]string, vs.Len())
+	for i, v := range vs.Validators() {
+		validators[i] = v.String()
+	}
+
+	return &ValidatorSetResponse{
+		Height:     height,
+		Validators: validators,
+		Quorum:     vs.QuorumSize(),
+	}
 }
 
 // handleRegisterVoter handles voter registration requests
@@ -236,11 +480,14 @@ func (s *Server) handleRegisterVoter(c echo.Context) error {
 		Timestamp:      time.Now().Unix(),
 	}
 
+	tx.ChainID = s.bc.ChainID()
 	if err := tx.Sign(privKey); err != nil {
 		return c.JSON(http.StatusInternalServerError, APIError{Error: err.Error()})
 	}
 
-	s.txChan <- tx
+	if _, err := s.sendTx(tx); err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "success",
@@ -274,11 +521,14 @@ func (s *Server) handleRegisterCandidate(c echo.Context) error {
 		Timestamp:          time.Now().Unix(),
 	}
 
+	tx.ChainID = s.bc.ChainID()
 	if err := tx.Sign(privKey); err != nil {
 		return c.JSON(http.StatusInternalServerError, APIError{Error: err.Error()})
 	}
 
-	s.txChan <- tx
+	if _, err := s.sendTx(tx); err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "success",
@@ -314,11 +564,14 @@ func (s *Server) handleCreateElection(c echo.Context) error {
 		Timestamp:      time.Now().Unix(),
 	}
 
+	tx.ChainID = s.bc.ChainID()
 	if err := tx.Sign(privKey); err != nil {
 		return c.JSON(http.StatusInternalServerError, APIError{Error: err.Error()})
 	}
 
-	s.txChan <- tx
+	if _, err := s.sendTx(tx); err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "success",
@@ -351,11 +604,14 @@ func (s *Server) handleCastVote(c echo.Context) error {
 		Timestamp:      time.Now().Unix(),
 	}
 
+	tx.ChainID = s.bc.ChainID()
 	if err := tx.Sign(privKey); err != nil {
 		return c.JSON(http.StatusInternalServerError, APIError{Error: err.Error()})
 	}
 
-	s.txChan <- tx
+	if _, err := s.sendTx(tx); err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "success",
@@ -365,18 +621,36 @@ func (s *Server) handleCastVote(c echo.Context) error {
 
 // handleGetElection handles requests to get election information
 func (s *Server) handleGetElection(c echo.Context) error {
-	electionID := c.Param("id")
+	response, err := s.getElection(c.Param("id"), c.QueryParam("includeCandidates") == "true")
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// handleGetElectionResults handles requests to get election results
+func (s *Server) handleGetElectionResults(c echo.Context) error {
+	response, err := s.getElectionResults(c.Param("id"))
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) getElection(electionID string, includeCandidates bool) (*ElectionResponse, error) {
 	if electionID == "" {
-		return c.JSON(http.StatusBadRequest, APIError{Error: "election ID is required"})
+		return nil, newStatusError(http.StatusBadRequest, fmt.Errorf("election ID is required"))
 	}
 
 	election, err := s.bc.GetVotingState().GetElection(electionID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, APIError{Error: err.Error()})
+		return nil, newStatusError(http.StatusNotFound, err)
 	}
 
 	// Convert to response format
-	response := ElectionResponse{
+	response := &ElectionResponse{
 		ID:          election.ID,
 		Title:       election.Title,
 		Description: election.Description,
@@ -398,7 +672,7 @@ func (s *Server) handleGetElection(c echo.Context) error {
 	}
 
 	// Include candidates if requested
-	if c.QueryParam("includeCandidates") == "true" {
+	if includeCandidates {
 		candidates := make([]CandidateResponse, 0, len(election.Candidates))
 		for _, candidate := range election.Candidates {
 			var statusStr string
@@ -424,26 +698,64 @@ func (s *Server) handleGetElection(c echo.Context) error {
 		response.Candidates = candidates
 	}
 
+	return response, nil
+}
+
+// handleGetElectionBudget handles requests to get the sponsorship budget of
+// an election
+func (s *Server) handleGetElectionBudget(c echo.Context) error {
+	response, err := s.getElectionBudget(c.Param("id"))
+	if err != nil {
+		return writeError(c, err)
+	}
+
 	return c.JSON(http.StatusOK, response)
 }
 
-// handleGetElectionResults handles requests to get election results
-func (s *Server) handleGetElectionResults(c echo.Context) error {
-	electionID := c.Param("id")
+func (s *Server) getElectionBudget(electionID string) (*BudgetResponse, error) {
 	if electionID == "" {
-		return c.JSON(http.StatusBadRequest, APIError{Error: "election ID is required"})
+		return nil, newStatusError(http.StatusBadRequest, fmt.Errorf("election ID is required"))
+	}
+
+	if _, err := s.bc.GetVotingState().GetElection(electionID); err != nil {
+		return nil, newStatusError(http.StatusNotFound, err)
+	}
+
+	response := &BudgetResponse{
+		ElectionID: electionID,
+		Paymasters: []string{},
+	}
+
+	// Elections that were never funded have an empty budget.
+	budget, err := s.bc.GetSponsorBudget(electionID)
+	if err != nil {
+		return response, nil
+	}
+
+	response.Balance = budget.Balance
+	response.Spent = budget.Spent
+	for _, p := range budget.Paymasters {
+		response.Paymasters = append(response.Paymasters, p.String())
+	}
+
+	return response, nil
+}
+
+func (s *Server) getElectionResults(electionID string) (*ElectionResultsResponse, error) {
+	if electionID == "" {
+		return nil, newStatusError(http.StatusBadRequest, fmt.Errorf("election ID is required"))
 	}
 
 	// First get the election to check if it has ended
 	election, err := s.bc.GetVotingState().GetElection(electionID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, APIError{Error: err.Error()})
+		return nil, newStatusError(http.StatusNotFound, err)
 	}
 
 	// Get results
 	results, err := s.bc.GetVotingState().GetElectionResults(electionID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
+		return nil, newStatusError(http.StatusBadRequest, err)
 	}
 
 	// Create a map of candidate IDs to names/profile hashes
@@ -452,15 +764,13 @@ func (s *Server) handleGetElectionResults(c echo.Context) error {
 		candidateMap[id] = candidate.IPFSProfileHash
 	}
 
-	response := ElectionResultsResponse{
+	return &ElectionResultsResponse{
 		ElectionID: election.ID,
 		Title:      election.Title,
 		EndTime:    election.EndTime,
 		Results:    results,
 		Candidates: candidateMap,
-	}
-
-	return c.JSON(http.StatusOK, response)
+	}, nil
 }
 
 // handleGetVoter handles requests to get voter information
@@ -534,40 +844,24 @@ func (s *Server) handleGetCandidate(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-// handleApproveVoter handles requests to approve or reject voters
+// handleApproveVoter handles requests to approve or reject voters. The
+// approval is sent as a transaction signed by the registrar key of the
+// X-Admin-Key header.
 func (s *Server) handleApproveVoter(c echo.Context) error {
 	var req ApprovalRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
 	}
 
-	// Get the admin private key from the request header
-	privKeyHex := c.Request().Header.Get("X-Admin-Key")
-	if privKeyHex == "" {
-		return c.JSON(http.StatusBadRequest, APIError{Error: "admin key is required"})
-	}
-
-	// TODO: Implement proper key handling
-	adminKey := crypto.GeneratePrivateKey() // Replace with actual key from header
-
-	var err error
-	if req.Approve {
-		err = s.bc.GetVotingState().ApproveVoter(req.ID, adminKey.PublicKey())
-	} else {
-		err = s.bc.GetVotingState().RejectVoter(req.ID, adminKey.PublicKey())
-	}
-
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
-	}
-
-	return c.JSON(http.StatusOK, map[string]string{
-		"status": "success",
-		"action": map[bool]string{true: "approved", false: "rejected"}[req.Approve],
+	return s.sendApproval(c, req, core.VoterApprovalTx{
+		VoterID: req.ID,
+		Approve: req.Approve,
 	})
 }
 
-// handleApproveCandidate handles requests to approve or reject candidates
+// handleApproveCandidate handles requests to approve or reject candidates.
+// The approval is sent as a transaction signed by the election admin key of
+// the X-Admin-Key header.
 func (s *Server) handleApproveCandidate(c echo.Context) error {
 	var req ApprovalRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
@@ -578,22 +872,45 @@ func (s *Server) handleApproveCandidate(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, APIError{Error: "election ID is required"})
 	}
 
-	// Get the admin private key from the request header
+	return s.sendApproval(c, req, core.CandidateApprovalTx{
+		ElectionID:  req.ElectionID,
+		CandidateID: req.ID,
+		Approve:     req.Approve,
+	})
+}
+
+// sendApproval signs the approval with the hex encoded private key of the
+// X-Admin-Key header and sends it to the network. The status changes once
+// the transaction is included in a block.
+func (s *Server) sendApproval(c echo.Context, req ApprovalRequest, inner any) error {
 	privKeyHex := c.Request().Header.Get("X-Admin-Key")
 	if privKeyHex == "" {
 		return c.JSON(http.StatusBadRequest, APIError{Error: "admin key is required"})
 	}
 
-	// TODO: Implement proper key handling
-	adminKey := crypto.GeneratePrivateKey() // Replace with actual key from header
+	b, err := hex.DecodeString(privKeyHex)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: "invalid admin key"})
+	}
+	adminKey, err := crypto.PrivateKeyFromBytes(b)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, APIError{Error: "invalid admin key"})
+	}
+
+	nonce, err := s.getNonce(adminKey.PublicKey().Address().String())
+	if err != nil {
+		return writeError(c, err)
+	}
 
-	var err error
-	if req.Approve {
-		err = s.bc.GetVotingState().ApproveCandidate(req.ElectionID, req.ID, adminKey.PublicKey())
-	} else {
-		err = s.bc.GetVotingState().RejectCandidate(req.ElectionID, req.ID, adminKey.PublicKey())
+	tx := core.NewTransaction(nil)
+	tx.TxInner = inner
+	tx.Nonce = nonce.Nonce
+	tx.ChainID = s.bc.ChainID()
+	if err := tx.Sign(adminKey); err != nil {
+		return c.JSON(http.StatusInternalServerError, APIError{Error: err.Error()})
 	}
 
+	hash, err := s.sendTx(tx)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, APIError{Error: err.Error()})
 	}
@@ -601,9 +918,61 @@ func (s *Server) handleApproveCandidate(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "success",
 		"action": map[bool]string{true: "approved", false: "rejected"}[req.Approve],
+		"txHash": hash.String(),
 	})
 }
 
+// statusError is an error that knows which HTTP status code the REST
+// handlers should respond with.
+type statusError struct {
+	status int
+	err    error
+}
+
+func newStatusError(status int, err error) *statusError {
+	return &statusError{
+		status: status,
+		err:    err,
+	}
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func writeError(c echo.Context, err error) error {
+	status := http.StatusInternalServerError
+	if serr, ok := err.(*statusError); ok {
+		status = serr.status
+	}
+
+	return c.JSON(status, APIError{Error: err.Error()})
+}
+
+func decodeHash(s string) (types.Hash, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return types.Hash{}, newStatusError(http.StatusBadRequest, err)
+	}
+	if len(b) != 32 {
+		return types.Hash{}, newStatusError(http.StatusBadRequest, fmt.Errorf("invalid hash length %d", len(b)))
+	}
+
+	return types.HashFromBytes(b), nil
+}
+
+func decodeAddress(s string) (types.Address, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return types.Address{}, newStatusError(http.StatusBadRequest, err)
+	}
+	if len(b) != 20 {
+		return types.Address{}, newStatusError(http.StatusBadRequest, fmt.Errorf("invalid address length %d", len(b)))
+	}
+
+	return types.AddressFromBytes(b), nil
+}
+
 func intoJSONBlock(block *core.Block) Block {
 	txResponse := TxResponse{
 		TxCount: uint(len(block.Transactions)),
@@ -614,6 +983,13 @@ func intoJSONBlock(block *core.Block) Block {
 		txResponse.Hashes[i] = block.Transactions[i].Hash(core.TxHasher{}).String()
 	}
 
+	// The genesis block is not signed.
+	var validator, signature string
+	if block.Signature != nil {
+		validator = block.Validator.Address().String()
+		signature = block.Signature.String()
+	}
+
 	return Block{
 		Hash:          block.Hash(core.BlockHasher{}).String(),
 		Version:       block.Header.Version,
@@ -621,8 +997,8 @@ func intoJSONBlock(block *core.Block) Block {
 		DataHash:      block.Header.DataHash.String(),
 		PrevBlockHash: block.Header.PrevBlockHash.String(),
 		Timestamp:     block.Header.Timestamp,
-		Validator:     block.Validator.Address().String(),
-		Signature:     block.Signature.String(),
+		Validator:     validator,
+		Signature:     signature,
 		TxResponse:    txResponse,
 	}
 }
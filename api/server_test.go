@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/go-kit/log"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetValidators(t *testing.T) {
+	s := newTestServer(t)
+	keys := []crypto.PublicKey{
+		crypto.GeneratePrivateKey().PublicKey(),
+		crypto.GeneratePrivateKey().PublicKey(),
+	}
+	s.bc.SetValidatorSet(core.NewValidatorSet(keys...))
+
+	req := httptest.NewRequest(http.MethodGet, "/validators", nil)
+	rec := httptest.NewRecorder()
+	assert.Nil(t, s.handleGetValidators(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res ValidatorSetResponse
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, uint32(1), res.Height)
+	assert.Equal(t, []string{keys[0].String(), keys[1].String()}, res.Validators)
+	assert.Equal(t, 2, res.Quorum)
+
+	req = httptest.NewRequest(http.MethodGet, "/validators?height=foo", nil)
+	rec = httptest.NewRecorder()
+	assert.Nil(t, s.handleGetValidators(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+type testMempool []*core.Transaction
+
+func (m testMempool) IsPending(hash types.Hash) bool {
+	for _, tx := range m {
+		if tx.Hash(core.TxHasher{}) == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (m testMempool) Pending() []*core.Transaction {
+	return m
+}
+
+func TestGetReceipt(t *testing.T) {
+	s := newTestServer(t)
+	privKey := crypto.GeneratePrivateKey()
+	now := time.Now().Unix()
+
+	election := core.NewTransaction(nil)
+	election.TxInner = core.ElectionCreationTx{
+		ElectionID:     "e1",
+		StartTime:      now - 10,
+		EndTime:        now + 100,
+		AdminPublicKey: privKey.PublicKey(),
+	}
+	election.ChainID = "test"
+	assert.Nil(t, election.Sign(privKey))
+
+	// The voter is not registered, so the vote fails.
+	vote := core.NewTransaction(nil)
+	vote.TxInner = core.VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: privKey.PublicKey()}
+	vote.Nonce = 1
+	vote.ChainID = "test"
+	assert.Nil(t, vote.Sign(privKey))
+
+	header, err := s.bc.GetHeader(0)
+	assert.Nil(t, err)
+	block, err := core.NewBlockFromPrevHeader(header, []*core.Transaction{election, vote})
+	assert.Nil(t, err)
+	block.ChainID = "test"
+	assert.Nil(t, block.Sign(privKey))
+	assert.Nil(t, s.bc.AddBlock(block))
+
+	pending := core.NewTransaction(nil)
+	pending.Nonce = 2
+	pending.ChainID = "test"
+	assert.Nil(t, pending.Sign(privKey))
+	s.Mempool = testMempool{pending}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("hash")
+	c.SetParamValues(election.Hash(core.TxHasher{}).String())
+	assert.Nil(t, s.handleGetReceipt(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res ReceiptResponse
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "success", res.Status)
+	assert.Equal(t, uint32(1), res.BlockHeight)
+	assert.Equal(t, core.TxBaseGas, res.GasUsed)
+	assert.Len(t, res.Events, 1)
+	assert.Equal(t, string(core.VotingEventElectionOpened), res.Events[0].Type)
+	assert.Equal(t, "e1", res.Events[0].ElectionID)
+
+	receipt, err := s.getReceipt(vote.Hash(core.TxHasher{}).String())
+	assert.Nil(t, err)
+	assert.Equal(t, "failed", receipt.Status)
+	assert.Equal(t, "voter not found or not registered", receipt.Error)
+	assert.Empty(t, receipt.Events)
+
+	receipt, err = s.getReceipt(pending.Hash(core.TxHasher{}).String())
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusPending, receipt.Status)
+
+	receipt, err = s.getReceipt(types.Hash{}.String())
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusUnknown, receipt.Status)
+
+	_, err = s.getReceipt("foo")
+	assert.NotNil(t, err)
+}
+
+func TestCastVoteRejectedAtAdmission(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/voting/vote", strings.NewReader(`{"electionId":"e1","candidateId":"c1"}`))
+	req.Header.Set("X-Private-Key", "00")
+	rec := httptest.NewRecorder()
+	assert.Nil(t, s.handleCastVote(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var res APIError
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Contains(t, res.Error, "election with ID e1 does not exist")
+	assert.Empty(t, s.txChan)
+}
+
+func TestApproveVoterSendsSignedTransaction(t *testing.T) {
+	s := newTestServer(t)
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+
+	register := core.NewTransaction(nil)
+	register.TxInner = core.VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}
+	register.ChainID = "test"
+	assert.Nil(t, register.Sign(voter))
+	header, err := s.bc.GetHeader(0)
+	assert.Nil(t, err)
+	block, err := core.NewBlockFromPrevHeader(header, []*core.Transaction{register})
+	assert.Nil(t, err)
+	assert.Nil(t, block.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, s.bc.AddBlock(block))
+
+	approve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/voting/approve/voter", strings.NewReader(`{"id":"v1","approve":true}`))
+		req.Header.Set("X-Admin-Key", key)
+		rec := httptest.NewRecorder()
+		assert.Nil(t, s.handleApproveVoter(echo.New().NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, approve("00").Code)
+	assert.Empty(t, s.txChan)
+
+	// The approval is signed with the key of the header and only takes
+	// effect once it is in a block.
+	assert.Equal(t, http.StatusOK, approve(hex.EncodeToString(admin.Bytes())).Code)
+	tx := <-s.txChan
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, admin.PublicKey(), tx.From)
+	assert.Equal(t, core.VoterApprovalTx{VoterID: "v1", Approve: true}, tx.TxInner)
+
+	v, err := s.bc.GetVotingState().GetVoter("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, core.VoterStatusPending, v.Status)
+}
+
+func TestGetNonce(t *testing.T) {
+	s := newTestServer(t)
+	privKey := crypto.GeneratePrivateKey()
+	addr := privKey.PublicKey().Address()
+
+	queued := []*core.Transaction{}
+	for _, nonce := range []uint64{0, 1, 3} {
+		tx := core.NewTransaction(nil)
+		tx.Nonce = nonce
+		tx.ChainID = "test"
+		assert.Nil(t, tx.Sign(privKey))
+		queued = append(queued, tx)
+	}
+	s.Mempool = testMempool(queued)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("address")
+	c.SetParamValues(addr.String())
+	assert.Nil(t, s.handleGetNonce(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Nonce 2 is missing, so the queued transaction with nonce 3 does not
+	// count.
+	var res NonceResponse
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, addr.String(), res.Address)
+	assert.Equal(t, uint64(2), res.Nonce)
+	assert.Equal(t, uint64(0), res.ConfirmedNonce)
+
+	_, err := s.getNonce("foo")
+	assert.NotNil(t, err)
+}
+
+func TestGetElectionBudget(t *testing.T) {
+	bc, err := core.NewBlockchain(log.NewNopLogger(), &core.Genesis{ChainID: "test", Fees: core.FeeConfig{BlockReward: 500}})
+	assert.Nil(t, err)
+	s := NewServer(ServerConfig{Logger: log.NewNopLogger()}, bc, make(chan *core.Transaction, 1))
+	privKey := crypto.GeneratePrivateKey()
+
+	// The block reward of the first block funds the budget.
+	election := core.NewTransaction(nil)
+	election.TxInner = core.ElectionCreationTx{ElectionID: "e1", AdminPublicKey: privKey.PublicKey()}
+	election.ChainID = "test"
+	assert.Nil(t, election.Sign(privKey))
+	funding := core.NewTransaction(nil)
+	funding.TxInner = core.ElectionBudgetTx{ElectionID: "e1", Amount: 300}
+	funding.Nonce = 1
+	funding.ChainID = "test"
+	assert.Nil(t, funding.Sign(privKey))
+
+	for _, tx := range []*core.Transaction{election, funding} {
+		header, err := s.bc.GetHeader(s.bc.Height())
+		assert.Nil(t, err)
+		block, err := core.NewBlockFromPrevHeader(header, []*core.Transaction{tx})
+		assert.Nil(t, err)
+		block.ChainID = "test"
+		assert.Nil(t, block.Sign(privKey))
+		assert.Nil(t, s.bc.AddBlock(block))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("e1")
+	assert.Nil(t, s.handleGetElectionBudget(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res BudgetResponse
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "e1", res.ElectionID)
+	assert.Equal(t, uint64(300), res.Balance)
+	assert.Equal(t, []string{privKey.PublicKey().String()}, res.Paymasters)
+
+	_, err = s.getElectionBudget("unknown")
+	assert.NotNil(t, err)
+}
@@ -16,6 +16,8 @@ var (
 type Account struct {
 	Address types.Address
 	Balance uint64
+	// Nonce is the nonce the next transaction of the account has to use.
+	Nonce uint64
 }
 
 func (a *Account) String() string {
@@ -25,6 +27,7 @@ func (a *Account) String() string {
 type AccountState struct {
 	mu       sync.RWMutex
 	accounts map[types.Address]*Account
+	journal  *Journal
 }
 
 func NewAccountState() *AccountState {
@@ -37,6 +40,8 @@ func (s *AccountState) CreateAccount(address types.Address) *Account {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.journalAccount(address)
+
 	acc := &Account{Address: address}
 	s.accounts[address] = acc
 	return acc
@@ -70,6 +75,70 @@ func (s *AccountState) GetBalance(address types.Address) (uint64, error) {
 	return account.Balance, nil
 }
 
+// Nonce returns the nonce of the next transaction of the account. Accounts
+// that do not exist yet start at zero.
+func (s *AccountState) Nonce(address types.Address) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, err := s.getAccountWithoutLock(address)
+	if err != nil {
+		return 0
+	}
+
+	return account.Nonce
+}
+
+// incrementNonce uses up the current nonce of the account, the account is
+// created if it does not exist.
+func (s *AccountState) incrementNonce(address types.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.journalAccount(address)
+
+	account, ok := s.accounts[address]
+	if !ok {
+		account = &Account{Address: address}
+		s.accounts[address] = account
+	}
+	account.Nonce++
+}
+
+// addBalance credits the account, it is created if it does not exist.
+func (s *AccountState) addBalance(address types.Address, amount uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.journalAccount(address)
+
+	account, ok := s.accounts[address]
+	if !ok {
+		account = &Account{Address: address}
+		s.accounts[address] = account
+	}
+	account.Balance += amount
+}
+
+// subBalance debits the account.
+func (s *AccountState) subBalance(address types.Address, amount uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.getAccountWithoutLock(address)
+	if err != nil {
+		return err
+	}
+	if account.Balance < amount {
+		return ErrInsufficientBalance
+	}
+
+	s.journalAccount(address)
+	account.Balance -= amount
+
+	return nil
+}
+
 func (s *AccountState) Transfer(from, to types.Address, amount uint64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -79,15 +148,14 @@ func (s *AccountState) Transfer(from, to types.Address, amount uint64) error {
 		return err
 	}
 
-	if fromAccount.Address.String() != "996fb92427ae41e4649b934ca495991b7852b855" {
-		if fromAccount.Balance < amount {
-			return ErrInsufficientBalance
-		}
+	if fromAccount.Balance < amount {
+		return ErrInsufficientBalance
 	}
 
-	if fromAccount.Balance != 0 {
-		fromAccount.Balance -= amount
-	}
+	s.journalAccount(from)
+	s.journalAccount(to)
+
+	fromAccount.Balance -= amount
 
 	if s.accounts[to] == nil {
 		s.accounts[to] = &Account{
@@ -99,3 +167,29 @@ func (s *AccountState) Transfer(from, to types.Address, amount uint64) error {
 
 	return nil
 }
+
+// journalAccount records the current state of the account, so a change to
+// it can be reverted. The lock has to be held.
+func (s *AccountState) journalAccount(address types.Address) {
+	if s.journal == nil {
+		return
+	}
+
+	prev, ok := s.accounts[address]
+	var value Account
+	if ok {
+		value = *prev
+	}
+
+	s.journal.record(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if !ok {
+			delete(s.accounts, address)
+			return
+		}
+		*prev = value
+		s.accounts[address] = prev
+	})
+}
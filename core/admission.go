@@ -0,0 +1,138 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anthdm/projectx/types"
+)
+
+var ErrTxRejected = errors.New("transaction rejected")
+
+const (
+	// MaxNonceGap is how far the nonce of a transaction may be ahead of
+	// the next nonce of its sender to be queued.
+	MaxNonceGap = 64
+	// MaxQueuedTxsPerSender is the number of transactions with a future
+	// nonce that are queued per sender.
+	MaxQueuedTxsPerSender = 16
+)
+
+// CheckTransaction checks if the transaction can be admitted to the
+// mempool. Voting transactions are executed on top of the head state and
+// the pending transactions they depend on, so votes that would fail are rejected before
+// they are broadcast.
+func (bc *Blockchain) CheckTransaction(tx *Transaction, pending []*Transaction) error {
+	if err := tx.Verify(); err != nil {
+		return err
+	}
+
+	if tx.ChainID != bc.ChainID() {
+		return fmt.Errorf("%w: transaction belongs to chain (%s)", ErrTxRejected, tx.ChainID)
+	}
+
+	bc.stateLock.Lock()
+	defer bc.stateLock.Unlock()
+
+	hash := tx.Hash(TxHasher{})
+	if bc.hasTransaction(hash) {
+		return fmt.Errorf("%w: transaction (%s) is already on the chain", ErrTxRejected, hash)
+	}
+
+	snapshot := bc.journal.Snapshot()
+	defer func() {
+		bc.journal.RevertTo(snapshot)
+		bc.journal.Commit()
+	}()
+
+	// A transaction that is already pending was admitted before.
+	for _, p := range pending {
+		if p.Hash(TxHasher{}) == hash {
+			return nil
+		}
+	}
+
+	// Admission is not tied to a block, the pending transactions are
+	// executed at the current time like the transaction itself.
+	now := time.Now().Unix()
+	bc.applyTransactions(pendingDependencies(tx, pending), now)
+
+	next := bc.accountState.Nonce(tx.From.Address())
+	if tx.Nonce < next {
+		return fmt.Errorf("%w: nonce (%d) is already used, next nonce is (%d)", ErrTxRejected, tx.Nonce, next)
+	}
+
+	if min := bc.MinFee(tx); tx.Fee < min {
+		return fmt.Errorf("%w: fee (%d) is below the minimum fee (%d)", ErrTxRejected, tx.Fee, min)
+	}
+
+	// Transactions with a future nonce are queued until the gap is filled,
+	// they cannot be checked against the state before that. Both the gap
+	// and the queue of a sender are bounded, so a single key can not fill
+	// the mempool with transactions that never become executable.
+	if tx.Nonce > next {
+		if tx.Nonce-next > MaxNonceGap {
+			return fmt.Errorf("%w: nonce (%d) is too far ahead of the next nonce (%d)", ErrTxRejected, tx.Nonce, next)
+		}
+		if queued := countQueued(pending, tx.From.Address(), next); queued >= MaxQueuedTxsPerSender {
+			return fmt.Errorf("%w: sender has (%d) queued transactions already", ErrTxRejected, queued)
+		}
+		return nil
+	}
+
+	if err := bc.checkTransaction(tx, nil); err != nil {
+		return fmt.Errorf("%w: %v", ErrTxRejected, err)
+	}
+
+	if !isVotingTx(tx) {
+		return nil
+	}
+
+	receipt := bc.applyTransaction(tx, bc.Height()+1, now)
+	if receipt.Status == ReceiptStatusFailed {
+		return fmt.Errorf("%w: %s", ErrTxRejected, receipt.Error)
+	}
+
+	return nil
+}
+
+// countQueued returns the number of pending transactions of the sender that
+// wait for a nonce gap to be filled.
+func countQueued(pending []*Transaction, from types.Address, next uint64) int {
+	n := 0
+	for _, tx := range pending {
+		if tx.Nonce > next && tx.From.Address() == from {
+			n++
+		}
+	}
+
+	return n
+}
+
+// pendingDependencies returns the pending transactions the checks of the
+// transaction depend on: the ones of the same sender and, if the
+// transaction has an inner type, the pending transactions with an inner
+// type as they share the native state. Transfers and contract calls of
+// other senders are not executed, which keeps admission linear in the size
+// of the pool.
+func pendingDependencies(tx *Transaction, pending []*Transaction) []*Transaction {
+	deps := []*Transaction{}
+	for _, p := range pending {
+		if bytes.Equal(p.From, tx.From) || (tx.TxInner != nil && p.TxInner != nil) {
+			deps = append(deps, p)
+		}
+	}
+
+	return deps
+}
+
+func isVotingTx(tx *Transaction) bool {
+	switch tx.TxInner.(type) {
+	case VoterRegistrationTx, CandidateRegistrationTx, ElectionCreationTx, VoteTx, VoterApprovalTx, CandidateApprovalTx:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newVotingTx(t *testing.T, privKey crypto.PrivateKey, nonce uint64, inner any) *Transaction {
+	tx := NewTransaction(nil)
+	tx.TxInner = inner
+	tx.Nonce = nonce
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+
+	return tx
+}
+
+func TestCheckTransaction(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+	now := time.Now().Unix()
+
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0],
+		newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "open", StartTime: now - 10, EndTime: now + 100, AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, admin, 1, ElectionCreationTx{ElectionID: "closed", StartTime: now - 10, EndTime: now - 5, AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}),
+		newVotingTx(t, admin, 2, CandidateRegistrationTx{CandidateID: "c1", ElectionID: "open"}),
+	)))
+	vs := bc.GetVotingState()
+	assert.Nil(t, vs.ApproveVoter("v1", admin.PublicKey()))
+	assert.Nil(t, vs.ApproveCandidate("open", "c1", admin.PublicKey()))
+
+	vote := newVotingTx(t, voter, 1, VoteTx{ElectionID: "open", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	assert.Nil(t, bc.CheckTransaction(vote, nil))
+
+	// A second vote of the same voter is rejected while the first one is
+	// still pending, resubmitting the first one is fine.
+	other := newVotingTx(t, voter, 2, VoteTx{ElectionID: "open", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	err := bc.CheckTransaction(other, []*Transaction{vote})
+	assert.True(t, errors.Is(err, ErrTxRejected))
+	assert.Contains(t, err.Error(), "voter has already cast a vote")
+	assert.Nil(t, bc.CheckTransaction(vote, []*Transaction{vote}))
+
+	closed := newVotingTx(t, voter, 1, VoteTx{ElectionID: "closed", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	assert.True(t, errors.Is(bc.CheckTransaction(closed, nil), ErrTxRejected))
+
+	unknown := crypto.GeneratePrivateKey()
+	unregistered := newVotingTx(t, unknown, 0, VoteTx{ElectionID: "open", CandidateID: "c1", VoterPublicKey: unknown.PublicKey()})
+	assert.True(t, errors.Is(bc.CheckTransaction(unregistered, nil), ErrTxRejected))
+
+	// The checks do not change the state.
+	assert.Nil(t, bc.CheckTransaction(vote, nil))
+
+	// Transactions that are already on the chain or have no
+	// signature are rejected.
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], vote)))
+	assert.True(t, errors.Is(bc.CheckTransaction(vote, nil), ErrTxRejected))
+
+	unsigned := newVotingTx(t, voter, 2, VoteTx{ElectionID: "open", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	unsigned.Signature = nil
+	assert.NotNil(t, bc.CheckTransaction(unsigned, nil))
+}
+
+func TestCheckTransactionNonce(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	privKey := crypto.GeneratePrivateKey()
+
+	first := newElectionTx(t, "e1")
+	first.From = nil
+	first.Nonce = 0
+	first.ChainID = testChainID
+	assert.Nil(t, first.Sign(privKey))
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], first)))
+	assert.Equal(t, uint64(1), bc.NextNonce(privKey.PublicKey().Address()))
+
+	// The nonce of the first transaction is used up.
+	stale := NewTransaction(nil)
+	stale.To = crypto.GeneratePrivateKey().PublicKey()
+	stale.ChainID = testChainID
+	assert.Nil(t, stale.Sign(privKey))
+	err := bc.CheckTransaction(stale, nil)
+	assert.True(t, errors.Is(err, ErrTxRejected))
+	assert.Contains(t, err.Error(), "nonce (0) is already used")
+
+	// A future nonce is queued without checking it against the state.
+	future := newVotingTx(t, privKey, 5, VoteTx{ElectionID: "unknown", VoterPublicKey: privKey.PublicKey()})
+	assert.Nil(t, bc.CheckTransaction(future, nil))
+
+	// But not too far in the future.
+	far := newVotingTx(t, privKey, 1+MaxNonceGap+1, VoteTx{ElectionID: "unknown", VoterPublicKey: privKey.PublicKey()})
+	err = bc.CheckTransaction(far, nil)
+	assert.True(t, errors.Is(err, ErrTxRejected))
+	assert.Contains(t, err.Error(), "too far ahead")
+	assert.Nil(t, bc.CheckTransaction(newVotingTx(t, privKey, 1+MaxNonceGap, VoteTx{ElectionID: "unknown", VoterPublicKey: privKey.PublicKey()}), nil))
+}
+
+func TestCheckTransactionLimitsQueuedPerSender(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	privKey := crypto.GeneratePrivateKey()
+
+	queued := []*Transaction{}
+	for i := 0; i < MaxQueuedTxsPerSender; i++ {
+		tx := newVotingTx(t, privKey, uint64(i+2), VoteTx{ElectionID: "unknown", VoterPublicKey: privKey.PublicKey()})
+		assert.Nil(t, bc.CheckTransaction(tx, queued))
+		queued = append(queued, tx)
+	}
+
+	tx := newVotingTx(t, privKey, uint64(MaxQueuedTxsPerSender+2), VoteTx{ElectionID: "unknown", VoterPublicKey: privKey.PublicKey()})
+	err := bc.CheckTransaction(tx, queued)
+	assert.True(t, errors.Is(err, ErrTxRejected))
+	assert.Contains(t, err.Error(), "queued transactions")
+
+	// Other senders and executable transactions are not limited.
+	other := crypto.GeneratePrivateKey()
+	assert.Nil(t, bc.CheckTransaction(newVotingTx(t, other, 2, VoteTx{ElectionID: "unknown", VoterPublicKey: other.PublicKey()}), queued))
+	assert.Nil(t, bc.CheckTransaction(newVotingTx(t, privKey, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: privKey.PublicKey()}), queued))
+}
+
+func TestPendingDependencies(t *testing.T) {
+	sender := crypto.GeneratePrivateKey()
+	other := crypto.GeneratePrivateKey()
+
+	own := newVotingTx(t, sender, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: sender.PublicKey()})
+	election := newVotingTx(t, other, 0, ElectionCreationTx{ElectionID: "e2", AdminPublicKey: other.PublicKey()})
+	transfer := NewTransaction([]byte{0x01})
+	transfer.ChainID = testChainID
+	assert.Nil(t, transfer.Sign(other))
+	pending := []*Transaction{own, transfer, election}
+
+	// Voting transactions depend on the native state of all senders.
+	vote := newVotingTx(t, sender, 1, VoteTx{ElectionID: "e2", VoterPublicKey: sender.PublicKey()})
+	assert.Equal(t, []*Transaction{own, election}, pendingDependencies(vote, pending))
+
+	// Transfers only on the transactions of their sender.
+	plain := NewTransaction(nil)
+	plain.Nonce = 1
+	plain.ChainID = testChainID
+	assert.Nil(t, plain.Sign(sender))
+	assert.Equal(t, []*Transaction{own}, pendingDependencies(plain, pending))
+}
+
+func TestBlockTransactionNonces(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	privKey := crypto.GeneratePrivateKey()
+
+	txx := []*Transaction{}
+	for i := 0; i < 3; i++ {
+		txx = append(txx, newVotingTx(t, privKey, uint64(i), ElectionCreationTx{ElectionID: fmt.Sprintf("e%d", i), AdminPublicKey: privKey.PublicKey()}))
+	}
+
+	// Out of order nonces reject the block.
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], txx[1], txx[0])))
+	assert.Equal(t, uint64(0), bc.NextNonce(privKey.PublicKey().Address()))
+
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], txx[0], txx[1])))
+	assert.Equal(t, uint64(2), bc.NextNonce(privKey.PublicKey().Address()))
+
+	// A gap in the nonces rejects the block.
+	gap := newVotingTx(t, privKey, 3, ElectionCreationTx{ElectionID: "gap", AdminPublicKey: privKey.PublicKey()})
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], gap)))
+
+	// The proposer skips transactions until their nonce is next.
+	assert.Equal(t, []*Transaction{txx[2]}, bc.ValidTransactions([]*Transaction{gap, txx[1], txx[2]}, time.Now().UnixNano()))
+	assert.Equal(t, []*Transaction{txx[2], gap}, bc.ValidTransactions([]*Transaction{txx[2], gap}, time.Now().UnixNano()))
+}
@@ -3,7 +3,7 @@ package core
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
+	"encoding/binary"
 	"fmt"
 	"time"
 
@@ -17,14 +17,57 @@ type Header struct {
 	PrevBlockHash types.Hash
 	Height        uint32
 	Timestamp     int64
+	// ChainID is the network the block belongs to, it is covered by the
+	// block hash.
+	ChainID string
 }
 
+// HeaderSize is the size of the canonical encoding of a header without the
+// chain ID.
+const HeaderSize = 4 + 32 + 32 + 4 + 8 + 1
+
+// MaxChainIDLength is the maximum length of a chain ID in bytes.
+const MaxChainIDLength = 64
+
+// Bytes returns the canonical encoding of the header that is used for
+// hashing and signing. The layout is fixed, all integers are little endian:
+//
+//	version (4) | data hash (32) | prev block hash (32) | height (4) | timestamp (8) | len(chain ID) (1) | chain ID
+//
+// Chain IDs that are longer than MaxChainIDLength are invalid, their
+// headers are never accepted.
 func (h *Header) Bytes() []byte {
-	buf := &bytes.Buffer{}
-	enc := gob.NewEncoder(buf)
-	enc.Encode(h)
+	b := make([]byte, 0, HeaderSize+len(h.ChainID))
+	b = binary.LittleEndian.AppendUint32(b, h.Version)
+	b = append(b, h.DataHash[:]...)
+	b = append(b, h.PrevBlockHash[:]...)
+	b = binary.LittleEndian.AppendUint32(b, h.Height)
+	b = binary.LittleEndian.AppendUint64(b, uint64(h.Timestamp))
+	b = append(b, byte(len(h.ChainID)))
+	b = append(b, h.ChainID...)
+
+	return b
+}
+
+// HeaderFromBytes decodes a header from its canonical encoding.
+func HeaderFromBytes(b []byte) (*Header, error) {
+	if len(b) < HeaderSize {
+		return nil, fmt.Errorf("invalid header length %d, expected at least %d", len(b), HeaderSize)
+	}
 
-	return buf.Bytes()
+	n := int(b[HeaderSize-1])
+	if n > MaxChainIDLength || len(b) != HeaderSize+n {
+		return nil, fmt.Errorf("invalid header length %d with chain ID length %d", len(b), n)
+	}
+
+	return &Header{
+		Version:       binary.LittleEndian.Uint32(b[0:4]),
+		DataHash:      types.HashFromBytes(b[4:36]),
+		PrevBlockHash: types.HashFromBytes(b[36:68]),
+		Height:        binary.LittleEndian.Uint32(b[68:72]),
+		Timestamp:     int64(binary.LittleEndian.Uint64(b[72:80])),
+		ChainID:       string(b[HeaderSize:]),
+	}, nil
 }
 
 type Block struct {
@@ -33,6 +76,9 @@ type Block struct {
 	Transactions []*Transaction
 	Validator    crypto.PublicKey
 	Signature    *crypto.Signature
+	// Commit certificate of the validators, it is attached after consensus
+	// is reached and therefore not covered by the block hash.
+	Commit *Commit
 
 	// Cached version of the header hash
 	hash types.Hash
@@ -57,19 +103,31 @@ func NewBlockFromPrevHeader(prevHeader *Header, txx []*Transaction) (*Block, err
 		DataHash:      dataHash,
 		PrevBlockHash: BlockHasher{}.Hash(prevHeader),
 		Timestamp:     time.Now().UnixNano(),
+		ChainID:       prevHeader.ChainID,
 	}
 
 	return NewBlock(header, txx)
 }
 
+// Unix returns the timestamp of the header in Unix seconds, the unit of the
+// voting windows of elections.
+func (h *Header) Unix() int64 {
+	return time.Unix(0, h.Timestamp).Unix()
+}
+
 func (b *Block) AddTransaction(tx *Transaction) {
 	b.Transactions = append(b.Transactions, tx)
 	hash, _ := CalculateDataHash(b.Transactions)
 	b.DataHash = hash
 }
 
+// Sign signs the hash of the canonical header encoding together with the
+// chain ID. ECDSA only uses as many bytes of the input as the curve order is
+// long, so signing the raw header bytes would leave most of the header
+// unsigned.
 func (b *Block) Sign(privKey crypto.PrivateKey) error {
-	sig, err := privKey.Sign(b.Header.Bytes())
+	hash := BlockHasher{}.Hash(b.Header)
+	sig, err := privKey.Sign(SigningDigest(SigningDomainBlock, b.ChainID, hash))
 	if err != nil {
 		return err
 	}
@@ -85,7 +143,8 @@ func (b *Block) Verify() error {
 		return fmt.Errorf("block has no signature")
 	}
 
-	if !b.Signature.Verify(b.Validator, b.Header.Bytes()) {
+	hash := BlockHasher{}.Hash(b.Header)
+	if !verifySignature(b.Signature, b.Validator, SigningDigest(SigningDomainBlock, b.ChainID, hash)) {
 		return fmt.Errorf("block has invalid signature")
 	}
 
@@ -126,8 +185,10 @@ func (b *Block) Hash(hasher Hasher[*Header]) types.Hash {
 func CalculateDataHash(txx []*Transaction) (hash types.Hash, err error) {
 	buf := &bytes.Buffer{}
 
+	// The protobuf encoding is canonical, gob output is not guaranteed to be
+	// stable which would make the data hash differ between implementations.
 	for _, tx := range txx {
-		if err = tx.Encode(NewGobTxEncoder(buf)); err != nil {
+		if err = tx.Encode(NewProtoTxEncoder(buf)); err != nil {
 			return
 		}
 	}
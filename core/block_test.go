@@ -2,6 +2,10 @@ package core
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
 	"testing"
 	"time"
 
@@ -14,6 +18,7 @@ func TestSignBlock(t *testing.T) {
 	privKey := crypto.GeneratePrivateKey()
 	b := randomBlock(t, 0, types.Hash{})
 
+	b.ChainID = testChainID
 	assert.Nil(t, b.Sign(privKey))
 	assert.NotNil(t, b.Signature)
 }
@@ -22,6 +27,7 @@ func TestVerifyBlock(t *testing.T) {
 	privKey := crypto.GeneratePrivateKey()
 	b := randomBlock(t, 0, types.Hash{})
 
+	b.ChainID = testChainID
 	assert.Nil(t, b.Sign(privKey))
 	assert.Nil(t, b.Verify())
 
@@ -52,6 +58,103 @@ func TestDecodeEncodeBlock(t *testing.T) {
 	assert.Equal(t, b.Signature, bDecode.Signature)
 }
 
+type headerVector struct {
+	Name          string
+	ChainID       string
+	Version       uint32
+	DataHash      string
+	PrevBlockHash string
+	Height        uint32
+	Timestamp     int64
+	Encoded       string
+	Hash          string
+	Validator     string
+	SignatureR    string
+	SignatureS    string
+}
+
+// The vectors in testdata/header_vectors.json can be used to verify other
+// implementations of the canonical header encoding and of the block signing
+// digest.
+func TestHeaderGoldenVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/header_vectors.json")
+	assert.Nil(t, err)
+
+	vectors := []headerVector{}
+	assert.Nil(t, json.Unmarshal(data, &vectors))
+	assert.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		header := &Header{
+			Version:       v.Version,
+			DataHash:      mustDecodeHash(t, v.DataHash),
+			PrevBlockHash: mustDecodeHash(t, v.PrevBlockHash),
+			Height:        v.Height,
+			Timestamp:     v.Timestamp,
+			ChainID:       v.ChainID,
+		}
+
+		assert.Equal(t, v.Encoded, hex.EncodeToString(header.Bytes()), v.Name)
+		assert.Equal(t, v.Hash, BlockHasher{}.Hash(header).String(), v.Name)
+
+		decoded, err := HeaderFromBytes(header.Bytes())
+		assert.Nil(t, err)
+		assert.Equal(t, header, decoded, v.Name)
+
+		validator, err := hex.DecodeString(v.Validator)
+		assert.Nil(t, err)
+		sig := crypto.Signature{
+			R: new(big.Int).SetBytes(mustDecodeHex(t, v.SignatureR)),
+			S: new(big.Int).SetBytes(mustDecodeHex(t, v.SignatureS)),
+		}
+		hash := BlockHasher{}.Hash(header)
+		assert.True(t, sig.Verify(validator, SigningDigest(SigningDomainBlock, v.ChainID, hash)), v.Name)
+		assert.False(t, sig.Verify(validator, hash.ToSlice()), v.Name)
+	}
+}
+
+func TestHeaderFromBytesInvalidLength(t *testing.T) {
+	_, err := HeaderFromBytes(make([]byte, HeaderSize-1))
+	assert.NotNil(t, err)
+
+	// The chain ID is shorter than its length says.
+	b := (&Header{ChainID: testChainID}).Bytes()
+	_, err = HeaderFromBytes(b[:len(b)-1])
+	assert.NotNil(t, err)
+}
+
+func TestHeaderHashCoversChainID(t *testing.T) {
+	header := &Header{Version: 1, Height: 1, ChainID: "testnet"}
+	other := *header
+	other.ChainID = "mainnet"
+	assert.NotEqual(t, BlockHasher{}.Hash(header), BlockHasher{}.Hash(&other))
+
+	// Child blocks inherit the chain ID of their parent.
+	b, err := NewBlockFromPrevHeader(header, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "testnet", b.ChainID)
+}
+
+func TestVerifyBlockTamperHeader(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	b := randomBlock(t, 10, types.Hash{})
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(privKey))
+
+	b.Timestamp++
+	assert.NotNil(t, b.Verify())
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	return b
+}
+
+func mustDecodeHash(t *testing.T, s string) types.Hash {
+	return types.HashFromBytes(mustDecodeHex(t, s))
+}
+
 func randomBlock(t *testing.T, height uint32, prevBlockHash types.Hash) *Block {
 	privKey := crypto.GeneratePrivateKey()
 	tx := randomTxWithSignature(t)
@@ -67,6 +170,7 @@ func randomBlock(t *testing.T, height uint32, prevBlockHash types.Hash) *Block {
 	dataHash, err := CalculateDataHash(b.Transactions)
 	assert.Nil(t, err)
 	b.Header.DataHash = dataHash
+	b.ChainID = testChainID
 	assert.Nil(t, b.Sign(privKey))
 
 	return b
@@ -3,21 +3,27 @@ package core
 import (
 	"fmt"
 	"sync"
+	"time"
 
-	"github.com/anthdm/projectx/crypto"
 	"github.com/anthdm/projectx/types"
 	"github.com/go-kit/log"
 )
 
 type Blockchain struct {
-	logger log.Logger
-	store  Storage
+	logger  log.Logger
+	store   Storage
+	genesis *Genesis
 	// TODO: double check this!
 	lock       sync.RWMutex
 	headers    []*Header
 	blocks     []*Block
 	txStore    map[types.Hash]*Transaction
+	receipts   map[types.Hash]*Receipt
 	blockStore map[types.Hash]*Block
+	// All known blocks, including side branches, indexed by their hash.
+	tree      map[types.Hash]*blockNode
+	head      *blockNode
+	finalized *blockNode
 
 	accountState *AccountState
 
@@ -26,47 +32,134 @@ type Blockchain struct {
 	mintState       map[types.Hash]*MintTx
 	votingState     *VotingState
 	validator       Validator
+	// The validator sets sorted by the height they are active from. If the
+	// set is empty, any signer is accepted.
+	validatorSets []validatorSetEntry
+	// Sponsorship budgets indexed by the election ID.
+	budgets map[string]*SponsorBudget
 	// TODO: make this an interface.
 	contractState *State
+	// Undo log of the transaction that is being executed.
+	journal *Journal
+
+	blockHandlers []BlockHandler
 }
 
-func NewBlockchain(l log.Logger, genesis *Block) (*Blockchain, error) {
-	// We should create all states inside the scope of the newblockchain.
+// BlockHandler is called for every block that is added to the chain.
+type BlockHandler func(*Block)
 
-	// TODO: read this from disk later on
-	accountState := NewAccountState()
-
-	coinbase := crypto.PublicKey{}
-	accountState.CreateAccount(coinbase.Address())
+// NewBlockchain creates a chain that starts with the genesis block derived
+// from the given genesis.
+func NewBlockchain(l log.Logger, genesis *Genesis) (*Blockchain, error) {
+	// We should create all states inside the scope of the newblockchain.
+	if len(genesis.ChainID) > MaxChainIDLength {
+		return nil, fmt.Errorf("chain ID is longer than %d bytes", MaxChainIDLength)
+	}
 
 	bc := &Blockchain{
-		contractState:   NewState(),
-		headers:         []*Header{},
-		store:           NewMemorystore(),
-		logger:          l,
-		accountState:    accountState,
-		collectionState: make(map[types.Hash]*CollectionTx),
-		mintState:       make(map[types.Hash]*MintTx),
-		votingState:     NewVotingState(),
-		blockStore:      make(map[types.Hash]*Block),
-		txStore:         make(map[types.Hash]*Transaction),
+		headers:       []*Header{},
+		store:         NewMemorystore(),
+		logger:        l,
+		genesis:       genesis,
+		votingState:   NewVotingState(),
+		journal:       NewJournal(),
+		blockStore:    make(map[types.Hash]*Block),
+		txStore:       make(map[types.Hash]*Transaction),
+		receipts:      make(map[types.Hash]*Receipt),
+		tree:          make(map[types.Hash]*blockNode),
+		validatorSets: []validatorSetEntry{{height: 0, set: NewValidatorSet(genesis.Validators...)}},
 	}
 	bc.validator = NewBlockValidator(bc)
-	err := bc.addBlockWithoutValidation(genesis)
+	bc.votingState.SetRegistrars(genesis.Registrars)
+	// TODO: read this from disk later on
+	bc.resetState()
+	err := bc.addBlockWithoutValidation(genesis.Block())
 
 	return bc, err
 }
 
+// resetState replaces the state with the state before genesis.
+func (bc *Blockchain) resetState() {
+	bc.lock.Lock()
+	bc.accountState = bc.newAccountState()
+	bc.accountState.journal = bc.journal
+	bc.budgets = make(map[string]*SponsorBudget)
+	bc.lock.Unlock()
+
+	bc.contractState = NewState()
+	bc.contractState.journal = bc.journal
+	bc.collectionState = make(map[types.Hash]*CollectionTx)
+	bc.mintState = make(map[types.Hash]*MintTx)
+	bc.votingState.journal = bc.journal
+}
+
 func (bc *Blockchain) SetValidator(v Validator) {
 	bc.validator = v
 }
 
+// SetValidatorSet sets the genesis validator set that takes turns in
+// producing blocks.
+func (bc *Blockchain) SetValidatorSet(vs *ValidatorSet) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.validatorSets = []validatorSetEntry{{height: 0, set: vs}}
+	bc.head.validatorSets = bc.validatorSets
+}
+
+// EpochLength returns the number of blocks in an epoch of the chain,
+// validator set changes take effect at the start of an epoch.
+func (bc *Blockchain) EpochLength() uint32 {
+	return bc.genesis.epochLength()
+}
+
+// ValidatorSet returns the validator set of the next block.
+func (bc *Blockchain) ValidatorSet() *ValidatorSet {
+	return bc.ValidatorSetAt(bc.Height() + 1)
+}
+
+// ValidatorSetAt returns the validator set that is active at the given
+// height, including changes that are scheduled for future heights.
+func (bc *Blockchain) ValidatorSetAt(height uint32) *ValidatorSet {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.validatorSetAt(height)
+}
+
+func (bc *Blockchain) validatorSetAt(height uint32) *ValidatorSet {
+	return validatorSetIn(bc.validatorSets, height)
+}
+
+// validatorSetIn returns the validator set of the schedule that is active at
+// the given height.
+func validatorSetIn(entries []validatorSetEntry, height uint32) *ValidatorSet {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].height <= height {
+			return entries[i].set
+		}
+	}
+
+	return NewValidatorSet()
+}
+
+// OnBlock registers a handler that is called after a block has been added.
+func (bc *Blockchain) OnBlock(h BlockHandler) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.blockHandlers = append(bc.blockHandlers, h)
+}
+
 func (bc *Blockchain) AddBlock(b *Block) error {
+	bc.stateLock.Lock()
+	defer bc.stateLock.Unlock()
+
 	if err := bc.validator.ValidateBlock(b); err != nil {
 		return err
 	}
 
-	return bc.addBlockWithoutValidation(b)
+	return bc.insertBlock(b)
 }
 
 func (bc *Blockchain) handleNativeTransfer(tx *Transaction) error {
@@ -79,12 +172,13 @@ func (bc *Blockchain) handleNativeTransfer(tx *Transaction) error {
 	return bc.accountState.Transfer(tx.From.Address(), tx.To.Address(), tx.Value)
 }
 
-func (bc *Blockchain) handleNativeNFT(tx *Transaction) error {
+func (bc *Blockchain) handleNativeNFT(tx *Transaction, height uint32, blockTime int64) error {
 	hash := tx.Hash(TxHasher{})
 
 	switch t := tx.TxInner.(type) {
 	case CollectionTx:
 		bc.collectionState[hash] = &t
+		bc.journal.record(func() { delete(bc.collectionState, hash) })
 		bc.logger.Log("msg", "created new NFT collection", "hash", hash)
 	case MintTx:
 		_, ok := bc.collectionState[t.Collection]
@@ -92,28 +186,57 @@ func (bc *Blockchain) handleNativeNFT(tx *Transaction) error {
 			return fmt.Errorf("collection (%s) does not exist on the blockchain", t.Collection)
 		}
 		bc.mintState[hash] = &t
+		bc.journal.record(func() { delete(bc.mintState, hash) })
 
 		bc.logger.Log("msg", "created new NFT mint", "NFT", t.NFT, "collection", t.Collection)
 	case VoterRegistrationTx:
-		if err := bc.votingState.RegisterVoter(&t); err != nil {
+		if err := bc.votingState.RegisterVoter(&t, blockTime); err != nil {
 			return err
 		}
 		bc.logger.Log("msg", "registered new voter", "voterID", t.VoterID)
 	case CandidateRegistrationTx:
-		if err := bc.votingState.RegisterCandidate(&t); err != nil {
+		if err := bc.votingState.RegisterCandidate(&t, blockTime); err != nil {
 			return err
 		}
 		bc.logger.Log("msg", "registered new candidate", "candidateID", t.CandidateID, "electionID", t.ElectionID)
 	case VoteTx:
-		if err := bc.votingState.CastVote(&t); err != nil {
+		if err := bc.votingState.CastVote(&t, blockTime); err != nil {
 			return err
 		}
 		bc.logger.Log("msg", "cast vote", "electionID", t.ElectionID, "candidateID", t.CandidateID)
 	case ElectionCreationTx:
-		if err := bc.votingState.CreateElection(&t); err != nil {
+		if err := bc.votingState.CreateElection(&t, blockTime); err != nil {
 			return err
 		}
 		bc.logger.Log("msg", "created new election", "electionID", t.ElectionID, "title", t.Title)
+	case ValidatorSetChangeTx:
+		if err := bc.handleValidatorSetChange(&t, height); err != nil {
+			return err
+		}
+		bc.logger.Log("msg", "scheduled validator set change", "validator", t.Validator, "change", t.Change, "effectiveHeight", t.EffectiveHeight)
+	case ElectionBudgetTx:
+		if err := bc.handleElectionBudget(tx.From, &t); err != nil {
+			return err
+		}
+		bc.logger.Log("msg", "funded election budget", "electionID", t.ElectionID, "amount", t.Amount)
+	case VoterApprovalTx:
+		setStatus := bc.votingState.RejectVoter
+		if t.Approve {
+			setStatus = bc.votingState.ApproveVoter
+		}
+		if err := setStatus(t.VoterID, tx.From); err != nil {
+			return err
+		}
+		bc.logger.Log("msg", "set voter approval", "voterID", t.VoterID, "approve", t.Approve)
+	case CandidateApprovalTx:
+		setStatus := bc.votingState.RejectCandidate
+		if t.Approve {
+			setStatus = bc.votingState.ApproveCandidate
+		}
+		if err := setStatus(t.ElectionID, t.CandidateID, tx.From); err != nil {
+			return err
+		}
+		bc.logger.Log("msg", "set candidate approval", "candidateID", t.CandidateID, "electionID", t.ElectionID, "approve", t.Approve)
 	default:
 		return fmt.Errorf("unsupported tx type %v", t)
 	}
@@ -180,7 +303,136 @@ func (bc *Blockchain) Height() uint32 {
 	return uint32(len(bc.headers) - 1)
 }
 
-func (bc *Blockchain) handleTransaction(tx *Transaction) error {
+// applyTransaction executes the transaction on a snapshot of the state. If
+// it fails all of its changes are reverted, the failure is recorded in the
+// receipt. The block time is in Unix seconds, execution never depends on
+// the clock of the node.
+func (bc *Blockchain) applyTransaction(tx *Transaction, height uint32, blockTime int64) *Receipt {
+	receipt := &Receipt{
+		TxHash:      tx.Hash(TxHasher{}),
+		BlockHeight: height,
+		Status:      ReceiptStatusSuccess,
+		GasUsed:     intrinsicGas(tx),
+	}
+
+	// The nonce and the fee are used up even if the transaction fails.
+	// checkTransaction made sure the fee can be paid.
+	bc.accountState.incrementNonce(tx.From.Address())
+	if err := bc.chargeFee(tx); err == nil {
+		receipt.Fee = tx.Fee
+	}
+
+	snapshot := bc.journal.Snapshot()
+	if err := bc.handleTransaction(tx, height, blockTime); err != nil {
+		bc.logger.Log("msg", "transaction failed", "hash", receipt.TxHash, "error", err)
+
+		bc.journal.RevertTo(snapshot)
+		receipt.Status = ReceiptStatusFailed
+		receipt.Error = err.Error()
+	}
+	receipt.Events = bc.votingState.takeEvents(receipt.Status == ReceiptStatusSuccess)
+
+	return receipt
+}
+
+// checkTransaction checks that the transaction can be included in a block,
+// seen holds the transactions that come before it in the same block.
+func (bc *Blockchain) checkTransaction(tx *Transaction, seen map[types.Hash]bool) error {
+	hash := tx.Hash(TxHasher{})
+	if tx.ChainID != bc.ChainID() {
+		return fmt.Errorf("transaction (%s) belongs to chain (%s)", hash, tx.ChainID)
+	}
+
+	if seen[hash] {
+		return fmt.Errorf("transaction (%s) is included twice", hash)
+	}
+
+	if bc.hasTransaction(hash) {
+		return fmt.Errorf("transaction (%s) is already on the chain", hash)
+	}
+
+	if next := bc.accountState.Nonce(tx.From.Address()); tx.Nonce != next {
+		return fmt.Errorf("transaction (%s) has nonce (%d), expected (%d)", hash, tx.Nonce, next)
+	}
+
+	if min := bc.MinFee(tx); tx.Fee < min {
+		return fmt.Errorf("transaction (%s) has fee (%d), minimum is (%d)", hash, tx.Fee, min)
+	}
+
+	if tx.Sponsorship != nil {
+		if err := bc.checkSponsorship(tx); err != nil {
+			return fmt.Errorf("transaction (%s) has an invalid sponsorship: %w", hash, err)
+		}
+	} else if !bc.canPayFee(tx.From.Address(), tx.Fee) {
+		return fmt.Errorf("sender of transaction (%s) cannot pay the fee (%d)", hash, tx.Fee)
+	}
+
+	return nil
+}
+
+func (bc *Blockchain) hasTransaction(hash types.Hash) bool {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	_, ok := bc.txStore[hash]
+	return ok
+}
+
+// NextNonce returns the nonce the next transaction of the address has to
+// use.
+func (bc *Blockchain) NextNonce(address types.Address) uint64 {
+	bc.lock.RLock()
+	accountState := bc.accountState
+	bc.lock.RUnlock()
+
+	return accountState.Nonce(address)
+}
+
+// ValidTransactions returns the transactions that can be included in the
+// next block with the given header timestamp, in order. Transactions that
+// fail during execution are still valid, they are included with a failed
+// receipt.
+func (bc *Blockchain) ValidTransactions(txx []*Transaction, timestamp int64) []*Transaction {
+	bc.stateLock.Lock()
+	defer bc.stateLock.Unlock()
+
+	snapshot := bc.journal.Snapshot()
+	defer func() {
+		bc.journal.RevertTo(snapshot)
+		bc.journal.Commit()
+	}()
+
+	valid, _ := bc.applyTransactions(txx, time.Unix(0, timestamp).Unix())
+
+	return valid
+}
+
+// applyTransactions executes the valid transactions in a block on top of
+// the head with the given block time in Unix seconds and returns them
+// together with the set of their hashes. Transactions with a future nonce
+// are skipped until the gap is filled. The caller has to revert the changes.
+// The state lock has to be held.
+func (bc *Blockchain) applyTransactions(txx []*Transaction, blockTime int64) ([]*Transaction, map[types.Hash]bool) {
+	var (
+		height = bc.Height() + 1
+		valid  = []*Transaction{}
+		seen   = make(map[types.Hash]bool, len(txx))
+	)
+	for _, tx := range txx {
+		if err := bc.checkTransaction(tx, seen); err != nil {
+			bc.logger.Log("msg", "skipping transaction", "error", err)
+			continue
+		}
+
+		seen[tx.Hash(TxHasher{})] = true
+		bc.applyTransaction(tx, height, blockTime)
+		valid = append(valid, tx)
+	}
+
+	return valid, seen
+}
+
+func (bc *Blockchain) handleTransaction(tx *Transaction, height uint32, blockTime int64) error {
 	// If we have data inside execute that data on the VM.
 	if len(tx.Data) > 0 {
 		bc.logger.Log("msg", "executing code", "len", len(tx.Data), "hash", tx.Hash(&TxHasher{}))
@@ -194,7 +446,7 @@ func (bc *Blockchain) handleTransaction(tx *Transaction) error {
 	// If the txInner of the transaction is not nil we need to handle
 	// the native NFT implementation or voting transactions.
 	if tx.TxInner != nil {
-		if err := bc.handleNativeNFT(tx); err != nil {
+		if err := bc.handleNativeNFT(tx, height, blockTime); err != nil {
 			return err
 		}
 	}
@@ -211,30 +463,47 @@ func (bc *Blockchain) handleTransaction(tx *Transaction) error {
 
 func (bc *Blockchain) addBlockWithoutValidation(b *Block) error {
 	bc.stateLock.Lock()
-	for i := 0; i < len(b.Transactions); i++ {
-		if err := bc.handleTransaction(b.Transactions[i]); err != nil {
-			bc.logger.Log("error", err.Error())
+	defer bc.stateLock.Unlock()
 
-			b.Transactions[i] = b.Transactions[len(b.Transactions)-1]
-			b.Transactions = b.Transactions[:len(b.Transactions)-1]
+	node := &blockNode{block: b}
 
-			continue
-		}
+	bc.lock.Lock()
+	hash := b.Hash(BlockHasher{})
+	bc.tree[hash] = node
+	bc.blockStore[hash] = b
+	bc.finalized = node
+	bc.lock.Unlock()
+
+	return bc.connectBlock(node, true)
+}
+
+// connectBlock executes the block of the node on top of the current head
+// and makes it the new head. The block handlers are only called if announce
+// is true, blocks that are connected again after a failed reorg were
+// announced before. The state lock has to be held.
+func (bc *Blockchain) connectBlock(node *blockNode, announce bool) error {
+	b := node.block
+	receipts, undo, err := bc.executeBlock(b)
+	if err != nil {
+		return err
 	}
-	bc.stateLock.Unlock()
 
 	// fmt.Println("========ACCOUNT STATE==============")
 	// fmt.Printf("%+v\n", bc.accountState.accounts)
 	// fmt.Println("========ACCOUNT STATE==============")
 
-	bc.lock.Lock()
-	bc.headers = append(bc.headers, b.Header)
-	bc.blocks = append(bc.blocks, b)
-	bc.blockStore[b.Hash(BlockHasher{})] = b
+	bc.appendBlock(b, receipts)
 
-	for _, tx := range b.Transactions {
-		bc.txStore[tx.Hash(TxHasher{})] = tx
+	bc.lock.Lock()
+	node.undo = undo
+	node.validatorSets = bc.validatorSets
+	bc.head = node
+	if b.Commit != nil {
+		bc.finalized = node
+	} else if b.Height >= bc.finalized.block.Height+MaxReorgDepth {
+		bc.finalized = ancestor(node, b.Height-MaxReorgDepth)
 	}
+	handlers := bc.blockHandlers
 	bc.lock.Unlock()
 
 	bc.logger.Log(
@@ -244,12 +513,56 @@ func (bc *Blockchain) addBlockWithoutValidation(b *Block) error {
 		"transactions", len(b.Transactions),
 	)
 
-	// Update election statuses after each block
-	bc.votingState.UpdateElectionStatuses()
+	if announce {
+		for _, h := range handlers {
+			h(b)
+		}
+	}
 
 	return bc.store.Put(b)
 }
 
+// executeBlock executes the transactions of the block as a whole. A
+// transaction that fails is reverted and gets a failed receipt, an invalid
+// transaction reverts the entire block. The returned function undoes the
+// changes of the block.
+func (bc *Blockchain) executeBlock(b *Block) ([]*Receipt, func(), error) {
+	snapshot := bc.journal.Snapshot()
+
+	receipts := make([]*Receipt, 0, len(b.Transactions))
+	seen := make(map[types.Hash]bool, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		if err := bc.checkTransaction(tx, seen); err != nil {
+			bc.journal.RevertTo(snapshot)
+			bc.journal.Commit()
+
+			return nil, nil, fmt.Errorf("block (%s) is invalid: %w", b.Hash(BlockHasher{}), err)
+		}
+
+		seen[tx.Hash(TxHasher{})] = true
+		receipts = append(receipts, bc.applyTransaction(tx, b.Height, b.Unix()))
+	}
+	bc.rewardValidator(b, receipts)
+	bc.votingState.UpdateElectionStatuses(b.Unix())
+
+	return receipts, bc.journal.CommitUndo(), nil
+}
+
+func (bc *Blockchain) appendBlock(b *Block, receipts []*Receipt) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.headers = append(bc.headers, b.Header)
+	bc.blocks = append(bc.blocks, b)
+
+	for _, tx := range b.Transactions {
+		bc.txStore[tx.Hash(TxHasher{})] = tx
+	}
+	for _, r := range receipts {
+		bc.receipts[r.TxHash] = r
+	}
+}
+
 // GetVotingState returns the voting state
 func (bc *Blockchain) GetVotingState() *VotingState {
 	return bc.votingState
@@ -15,6 +15,7 @@ func TestSendNativeTransferTamper(t *testing.T) {
 	signer := crypto.GeneratePrivateKey()
 
 	block := randomBlock(t, uint32(1), getPrevBlockHash(t, bc, uint32(1)))
+	block.ChainID = testChainID
 	assert.Nil(t, block.Sign(signer))
 
 	privKeyBob := crypto.GeneratePrivateKey()
@@ -28,6 +29,7 @@ func TestSendNativeTransferTamper(t *testing.T) {
 	tx.From = privKeyBob.PublicKey()
 	tx.To = privKeyAlice.PublicKey()
 	tx.Value = amount
+	tx.ChainID = testChainID
 	tx.Sign(privKeyBob)
 	tx.hash = types.Hash{}
 
@@ -46,8 +48,6 @@ func TestSendNativeTransferInsuffientBalance(t *testing.T) {
 	signer := crypto.GeneratePrivateKey()
 
 	block := randomBlock(t, uint32(1), getPrevBlockHash(t, bc, uint32(1)))
-	assert.Nil(t, block.Sign(signer))
-
 	privKeyBob := crypto.GeneratePrivateKey()
 	privKeyAlice := crypto.GeneratePrivateKey()
 	amount := uint64(100)
@@ -59,6 +59,7 @@ func TestSendNativeTransferInsuffientBalance(t *testing.T) {
 	tx.From = privKeyBob.PublicKey()
 	tx.To = privKeyAlice.PublicKey()
 	tx.Value = amount
+	tx.ChainID = testChainID
 	tx.Sign(privKeyBob)
 	tx.hash = types.Hash{}
 
@@ -66,14 +67,25 @@ func TestSendNativeTransferInsuffientBalance(t *testing.T) {
 	fmt.Printf("bob => %s\n", privKeyBob.PublicKey().Address())
 
 	block.AddTransaction(tx)
+	block.ChainID = testChainID
+	assert.Nil(t, block.Sign(signer))
 	assert.Nil(t, bc.AddBlock(block))
 
 	_, err := bc.accountState.GetAccount(privKeyAlice.PublicKey().Address())
 	assert.NotNil(t, err)
 
+	// The failed transaction stays in the block, the receipt records why it
+	// failed.
 	hash := tx.Hash(TxHasher{})
 	_, err = bc.GetTxByHash(hash)
-	assert.NotNil(t, err)
+	assert.Nil(t, err)
+	assert.Len(t, block.Transactions, 2)
+
+	receipt, err := bc.GetReceipt(hash)
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, ErrInsufficientBalance.Error(), receipt.Error)
+	assert.Equal(t, uint32(1), receipt.BlockHeight)
 }
 
 func TestSendNativeTransferSuccess(t *testing.T) {
@@ -82,8 +94,6 @@ func TestSendNativeTransferSuccess(t *testing.T) {
 	signer := crypto.GeneratePrivateKey()
 
 	block := randomBlock(t, uint32(1), getPrevBlockHash(t, bc, uint32(1)))
-	assert.Nil(t, block.Sign(signer))
-
 	privKeyBob := crypto.GeneratePrivateKey()
 	privKeyAlice := crypto.GeneratePrivateKey()
 	amount := uint64(100)
@@ -95,8 +105,11 @@ func TestSendNativeTransferSuccess(t *testing.T) {
 	tx.From = privKeyBob.PublicKey()
 	tx.To = privKeyAlice.PublicKey()
 	tx.Value = amount
+	tx.ChainID = testChainID
 	tx.Sign(privKeyBob)
 	block.AddTransaction(tx)
+	block.ChainID = testChainID
+	assert.Nil(t, block.Sign(signer))
 
 	assert.Nil(t, bc.AddBlock(block))
 
@@ -166,8 +179,10 @@ func TestAddBlockToHigh(t *testing.T) {
 	assert.NotNil(t, bc.AddBlock(randomBlock(t, 3, types.Hash{})))
 }
 
+const testChainID = "test"
+
 func newBlockchainWithGenesis(t *testing.T) *Blockchain {
-	bc, err := NewBlockchain(log.NewNopLogger(), randomBlock(t, 0, types.Hash{}))
+	bc, err := NewBlockchain(log.NewNopLogger(), &Genesis{ChainID: testChainID})
 	assert.Nil(t, err)
 
 	return bc
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+var (
+	ErrMissingCommit = errors.New("block has no commit")
+	ErrNoQuorum      = errors.New("commit has not enough validator signatures")
+)
+
+type VoteType byte
+
+const (
+	VoteTypePrevote   VoteType = 0x1
+	VoteTypePrecommit VoteType = 0x2
+)
+
+func (t VoteType) String() string {
+	switch t {
+	case VoteTypePrevote:
+		return "prevote"
+	case VoteTypePrecommit:
+		return "precommit"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(t))
+	}
+}
+
+// Vote is a signed prevote or precommit of a validator for a block in a
+// consensus round. A zero BlockHash is a vote for nil.
+type Vote struct {
+	Type      VoteType
+	Height    uint32
+	Round     uint32
+	BlockHash types.Hash
+	Validator crypto.PublicKey
+	Signature *crypto.Signature
+}
+
+// voteSize is the size of the canonical vote encoding.
+const voteSize = 1 + 4 + 4 + 32
+
+// Bytes returns the canonical encoding of the vote that is signed:
+//
+//	type (1) | height (4) | round (4) | block hash (32)
+func (v *Vote) Bytes() []byte {
+	b := make([]byte, 0, voteSize)
+	b = append(b, byte(v.Type))
+	b = binary.LittleEndian.AppendUint32(b, v.Height)
+	b = binary.LittleEndian.AppendUint32(b, v.Round)
+	b = append(b, v.BlockHash[:]...)
+
+	return b
+}
+
+func (v *Vote) hash() types.Hash {
+	return types.Hash(sha256.Sum256(v.Bytes()))
+}
+
+// Sign signs the vote for the given chain.
+func (v *Vote) Sign(privKey crypto.PrivateKey, chainID string) error {
+	sig, err := privKey.Sign(SigningDigest(SigningDomainVote, chainID, v.hash()))
+	if err != nil {
+		return err
+	}
+
+	v.Validator = privKey.PublicKey()
+	v.Signature = sig
+
+	return nil
+}
+
+// Verify checks the signature of the vote for the given chain.
+func (v *Vote) Verify(chainID string) error {
+	if v.Signature == nil {
+		return fmt.Errorf("%s has no signature", v.Type)
+	}
+
+	if !verifySignature(v.Signature, v.Validator, SigningDigest(SigningDomainVote, chainID, v.hash())) {
+		return fmt.Errorf("%s has invalid signature", v.Type)
+	}
+
+	return nil
+}
+
+// CommitSig is the precommit signature of a single validator.
+type CommitSig struct {
+	Validator crypto.PublicKey
+	Signature *crypto.Signature
+}
+
+// Commit is the certificate that 2/3+ of the validators precommitted a
+// block in the given round.
+type Commit struct {
+	Height     uint32
+	Round      uint32
+	BlockHash  types.Hash
+	Signatures []CommitSig
+}
+
+// NewCommit creates a commit from the given precommits, which all have to
+// be for the same block and round.
+func NewCommit(precommits []*Vote) *Commit {
+	if len(precommits) == 0 {
+		return &Commit{}
+	}
+
+	c := &Commit{
+		Height:    precommits[0].Height,
+		Round:     precommits[0].Round,
+		BlockHash: precommits[0].BlockHash,
+	}
+	for _, v := range precommits {
+		c.Signatures = append(c.Signatures, CommitSig{
+			Validator: v.Validator,
+			Signature: v.Signature,
+		})
+	}
+
+	return c
+}
+
+// Verify checks that 2/3+ of the given validator set signed the commit on
+// the given chain.
+func (c *Commit) Verify(vs *ValidatorSet, chainID string) error {
+	seen := make(map[string]bool, len(c.Signatures))
+
+	for _, sig := range c.Signatures {
+		if !vs.Contains(sig.Validator) {
+			return fmt.Errorf("commit signed by unknown validator (%s)", sig.Validator)
+		}
+		if seen[string(sig.Validator)] {
+			return fmt.Errorf("commit contains duplicate signature of validator (%s)", sig.Validator)
+		}
+		seen[string(sig.Validator)] = true
+
+		vote := &Vote{
+			Type:      VoteTypePrecommit,
+			Height:    c.Height,
+			Round:     c.Round,
+			BlockHash: c.BlockHash,
+			Validator: sig.Validator,
+			Signature: sig.Signature,
+		}
+		if err := vote.Verify(chainID); err != nil {
+			return err
+		}
+	}
+
+	if len(seen) < vs.QuorumSize() {
+		return ErrNoQuorum
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVoteSignVerify(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	v := &Vote{
+		Type:      VoteTypePrevote,
+		Height:    10,
+		Round:     2,
+		BlockHash: types.Hash{0x1},
+	}
+	assert.Nil(t, v.Sign(privKey, testChainID))
+	assert.Nil(t, v.Verify(testChainID))
+
+	// A vote of another chain is not valid on this one.
+	assert.NotNil(t, v.Verify("other"))
+
+	v.Type = VoteTypePrecommit
+	assert.NotNil(t, v.Verify(testChainID))
+}
+
+func TestCommitVerify(t *testing.T) {
+	keys := []crypto.PrivateKey{
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+	}
+	vs := NewValidatorSet(keys[0].PublicKey(), keys[1].PublicKey(), keys[2].PublicKey(), keys[3].PublicKey())
+	b := randomBlock(t, 1, types.Hash{})
+
+	assert.Nil(t, newTestCommit(t, b, 0, keys[:3]...).Verify(vs, testChainID))
+	assert.Equal(t, ErrNoQuorum, newTestCommit(t, b, 0, keys[:2]...).Verify(vs, testChainID))
+
+	// Signing the same commit twice does not count.
+	c := newTestCommit(t, b, 0, keys[0], keys[1], keys[1])
+	assert.NotNil(t, c.Verify(vs, testChainID))
+
+	// Signatures of validators outside the set do not count.
+	c = newTestCommit(t, b, 0, keys[0], keys[1], crypto.GeneratePrivateKey())
+	assert.NotNil(t, c.Verify(vs, testChainID))
+
+	// The signatures have to be for the round of the commit.
+	c = newTestCommit(t, b, 0, keys[:3]...)
+	c.Round = 1
+	assert.NotNil(t, c.Verify(vs, testChainID))
+}
+
+func TestValidateBlockMissingCommit(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	privKey := crypto.GeneratePrivateKey()
+	bc.SetValidatorSet(NewValidatorSet(privKey.PublicKey()))
+
+	b := randomBlock(t, 1, getPrevBlockHash(t, bc, 1))
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(privKey))
+	assert.Equal(t, ErrMissingCommit, bc.AddBlock(b))
+
+	// A commit for another block.
+	other := randomBlock(t, 1, getPrevBlockHash(t, bc, 1))
+	b.Commit = newTestCommit(t, other, 0, privKey)
+	assert.NotNil(t, bc.AddBlock(b))
+
+	b.Commit = newTestCommit(t, b, 0, privKey)
+	assert.Nil(t, bc.AddBlock(b))
+}
@@ -6,8 +6,9 @@ import (
 )
 
 //
-// For now we GOB encoding is used for fast bootstrapping of the project
-// in a later phase I'm considering using Protobuffers as default encoding / decoding.
+// GOB encoding is used for fast bootstrapping of the project. The protobuf
+// encoding in proto_encoding.go is canonical and language independent, the
+// codec that is used on the wire can be selected with a Codec.
 //
 
 type Encoder[T any] interface {
@@ -18,6 +19,28 @@ type Decoder[T any] interface {
 	Decode(T) error
 }
 
+// Codec creates the encoders and decoders for transactions and blocks.
+type Codec interface {
+	NewTxEncoder(io.Writer) Encoder[*Transaction]
+	NewTxDecoder(io.Reader) Decoder[*Transaction]
+	NewBlockEncoder(io.Writer) Encoder[*Block]
+	NewBlockDecoder(io.Reader) Decoder[*Block]
+}
+
+type GobCodec struct{}
+
+func (GobCodec) NewTxEncoder(w io.Writer) Encoder[*Transaction] { return NewGobTxEncoder(w) }
+func (GobCodec) NewTxDecoder(r io.Reader) Decoder[*Transaction] { return NewGobTxDecoder(r) }
+func (GobCodec) NewBlockEncoder(w io.Writer) Encoder[*Block]    { return NewGobBlockEncoder(w) }
+func (GobCodec) NewBlockDecoder(r io.Reader) Decoder[*Block]    { return NewGobBlockDecoder(r) }
+
+type ProtoCodec struct{}
+
+func (ProtoCodec) NewTxEncoder(w io.Writer) Encoder[*Transaction] { return NewProtoTxEncoder(w) }
+func (ProtoCodec) NewTxDecoder(r io.Reader) Decoder[*Transaction] { return NewProtoTxDecoder(r) }
+func (ProtoCodec) NewBlockEncoder(w io.Writer) Encoder[*Block]    { return NewProtoBlockEncoder(w) }
+func (ProtoCodec) NewBlockDecoder(r io.Reader) Decoder[*Block]    { return NewProtoBlockDecoder(r) }
+
 type GobTxEncoder struct {
 	w io.Writer
 }
@@ -0,0 +1,66 @@
+package core
+
+import "github.com/anthdm/projectx/types"
+
+// FeeConfig is the fee schedule of the chain, it is part of the genesis. The
+// zero value charges no fees and mints no block rewards.
+type FeeConfig struct {
+	// MinGasPrice is the lowest price per unit of gas the fee of a
+	// transaction has to cover.
+	MinGasPrice uint64
+	// BlockReward is minted to the validator of every block.
+	BlockReward uint64
+}
+
+// FeeConfig returns the fee schedule of the genesis.
+func (bc *Blockchain) FeeConfig() FeeConfig {
+	return bc.genesis.Fees
+}
+
+// MinFee returns the lowest fee the transaction has to pay to be included
+// in a block.
+func (bc *Blockchain) MinFee(tx *Transaction) uint64 {
+	return intrinsicGas(tx) * bc.FeeConfig().MinGasPrice
+}
+
+// rewardValidator credits the block reward and the fees of the block to its
+// validator. The genesis block has no validator to reward.
+func (bc *Blockchain) rewardValidator(b *Block, receipts []*Receipt) {
+	if b.Height == 0 {
+		return
+	}
+
+	amount := bc.FeeConfig().BlockReward
+	for _, r := range receipts {
+		amount += r.Fee
+	}
+	if amount == 0 {
+		return
+	}
+
+	bc.accountState.addBalance(b.Validator.Address(), amount)
+}
+
+// chargeFee takes the fee of the transaction from the balance of the
+// sender or the budget of the sponsor. The state lock has to be held.
+func (bc *Blockchain) chargeFee(tx *Transaction) error {
+	if tx.Fee == 0 {
+		return nil
+	}
+
+	if tx.Sponsorship != nil {
+		return bc.spendBudget(tx.Sponsorship.ElectionID, tx.Fee)
+	}
+
+	return bc.accountState.subBalance(tx.From.Address(), tx.Fee)
+}
+
+// canPayFee reports whether the sender of the transaction can pay its fee.
+func (bc *Blockchain) canPayFee(from types.Address, fee uint64) bool {
+	if fee == 0 {
+		return true
+	}
+
+	balance, err := bc.accountState.GetBalance(from)
+	return err == nil && balance >= fee
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBlockchainWithFees(t *testing.T, fees FeeConfig) *Blockchain {
+	bc, err := NewBlockchain(log.NewNopLogger(), &Genesis{ChainID: testChainID, Fees: fees})
+	assert.Nil(t, err)
+
+	return bc
+}
+
+func TestFeesAndBlockReward(t *testing.T) {
+	bc := newBlockchainWithFees(t, FeeConfig{MinGasPrice: 1, BlockReward: 50})
+
+	sender := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(sender.PublicKey().Address()).Balance = 5000
+
+	tx := newVotingTx(t, sender, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: sender.PublicKey()})
+	assert.Equal(t, TxBaseGas, bc.MinFee(tx))
+	tx.Fee = 1500
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(sender))
+
+	// The transfer fails, but its fee is paid anyway.
+	failing := NewTransaction(nil)
+	failing.To = crypto.GeneratePrivateKey().PublicKey()
+	failing.Value = 1_000_000
+	failing.Nonce = 1
+	failing.Fee = 1000
+	failing.ChainID = testChainID
+	assert.Nil(t, failing.Sign(sender))
+
+	validator := crypto.GeneratePrivateKey()
+	b, err := NewBlockFromPrevHeader(bc.headers[0], []*Transaction{tx, failing})
+	assert.Nil(t, err)
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(validator))
+	assert.Nil(t, bc.AddBlock(b))
+
+	balance, err := bc.accountState.GetBalance(sender.PublicKey().Address())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2500), balance)
+
+	balance, err = bc.accountState.GetBalance(validator.PublicKey().Address())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2550), balance)
+
+	receipt, err := bc.GetReceipt(failing.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, uint64(1000), receipt.Fee)
+}
+
+func TestTransactionFeeValidation(t *testing.T) {
+	bc := newBlockchainWithFees(t, FeeConfig{MinGasPrice: 1})
+
+	sender := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(sender.PublicKey().Address()).Balance = 1500
+
+	// A fee below the minimum or above the balance invalidates the block.
+	cheap := newVotingTx(t, sender, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: sender.PublicKey()})
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], cheap)))
+
+	expensive := NewTransaction(nil)
+	expensive.TxInner = ElectionCreationTx{ElectionID: "e1", AdminPublicKey: sender.PublicKey()}
+	expensive.Fee = 2000
+	expensive.ChainID = testChainID
+	assert.Nil(t, expensive.Sign(sender))
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], expensive)))
+	assert.Empty(t, bc.ValidTransactions([]*Transaction{cheap, expensive}, time.Now().UnixNano()))
+
+	err := bc.CheckTransaction(cheap, nil)
+	assert.ErrorIs(t, err, ErrTxRejected)
+	assert.Contains(t, err.Error(), "below the minimum fee")
+	assert.ErrorIs(t, bc.CheckTransaction(expensive, nil), ErrTxRejected)
+}
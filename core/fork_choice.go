@@ -0,0 +1,294 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthdm/projectx/types"
+)
+
+var ErrBelowFinalized = errors.New("block conflicts with a finalized block")
+
+// MaxReorgDepth is the number of blocks below the head that can be
+// reorganized. Chains without a validator set have no commits, their blocks
+// are final once they are this deep.
+const MaxReorgDepth uint32 = 128
+
+// blockNode is a block in the block tree. The weight of a node is the
+// number of validator signatures on its branch, the branch with the most
+// weight is the canonical chain.
+type blockNode struct {
+	block  *Block
+	parent *blockNode
+	weight uint64
+	// undo rolls back the changes of the block while it is on the
+	// canonical chain.
+	undo func()
+	// validatorSets is the validator set schedule after the block was
+	// executed, nil if the block was never executed.
+	validatorSets []validatorSetEntry
+}
+
+// blockWeight returns the number of validators that signed the block. Blocks
+// without a commit only carry the signature of their proposer.
+func blockWeight(b *Block) uint64 {
+	if b.Commit != nil && len(b.Commit.Signatures) > 0 {
+		return uint64(len(b.Commit.Signatures))
+	}
+
+	return 1
+}
+
+// ancestor returns the block of the branch of node at the given height.
+func ancestor(node *blockNode, height uint32) *blockNode {
+	for node.block.Height > height {
+		node = node.parent
+	}
+
+	return node
+}
+
+// findForkPoint returns the last block that both branches have in common.
+func findForkPoint(a, b *blockNode) *blockNode {
+	for a.block.Height > b.block.Height {
+		a = a.parent
+	}
+	for b.block.Height > a.block.Height {
+		b = b.parent
+	}
+	for a != b {
+		a = a.parent
+		b = b.parent
+	}
+
+	return a
+}
+
+// HasBlockHash returns true if the block is known, either on the canonical
+// chain or on a side branch.
+func (bc *Blockchain) HasBlockHash(hash types.Hash) bool {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	_, ok := bc.tree[hash]
+	return ok
+}
+
+// GetHeaderByHash returns the header of a known block, the block does not
+// have to be on the canonical chain.
+func (bc *Blockchain) GetHeaderByHash(hash types.Hash) (*Header, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	node, ok := bc.tree[hash]
+	if !ok {
+		return nil, fmt.Errorf("block with hash (%s) not found", hash)
+	}
+
+	return node.block.Header, nil
+}
+
+// FinalizedHeight returns the height of the last block with a commit
+// certificate or MaxReorgDepth blocks below the head, whichever is higher.
+// The chain will never be reorganized below it.
+func (bc *Blockchain) FinalizedHeight() uint32 {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.finalized.block.Height
+}
+
+// insertBlock adds a validated block to the block tree. If the block
+// extends the head it is executed, if it makes a side branch heavier than
+// the canonical chain the chain is reorganized. The state lock has to be
+// held.
+func (bc *Blockchain) insertBlock(b *Block) error {
+	hash := b.Hash(BlockHasher{})
+
+	bc.lock.Lock()
+	parent, ok := bc.tree[b.PrevBlockHash]
+	if !ok {
+		bc.lock.Unlock()
+		return fmt.Errorf("parent (%s) of block (%s) is unknown", b.PrevBlockHash, hash)
+	}
+
+	// Branches that fork below the finalized block can never become the
+	// canonical chain, their blocks are not kept.
+	head := bc.head
+	fork := findForkPoint(head, parent)
+	if fork.block.Height < bc.finalized.block.Height {
+		bc.lock.Unlock()
+		return ErrBelowFinalized
+	}
+
+	node := &blockNode{
+		block:  b,
+		parent: parent,
+		weight: parent.weight + blockWeight(b),
+	}
+	bc.tree[hash] = node
+	bc.blockStore[hash] = b
+	bc.lock.Unlock()
+
+	if parent == head {
+		if err := bc.connectBlock(node, true); err != nil {
+			bc.removeBranch(node, node)
+			return err
+		}
+		bc.pruneUndo()
+		return nil
+	}
+
+	if node.weight <= head.weight {
+		bc.logger.Log("msg", "stored block on side branch", "hash", hash, "height", b.Height)
+		return nil
+	}
+
+	if err := bc.reorg(fork, node); err != nil {
+		return err
+	}
+	bc.pruneUndo()
+
+	return nil
+}
+
+// reorg makes the branch of the given node, which forks from the canonical
+// chain at fork, the canonical chain. If a block of the branch is invalid,
+// the previous head is restored.
+func (bc *Blockchain) reorg(fork, node *blockNode) error {
+	bc.lock.RLock()
+	head, finalized := bc.head, bc.finalized
+	bc.lock.RUnlock()
+
+	bc.logger.Log(
+		"msg", "reorganizing chain",
+		"forkHeight", fork.block.Height,
+		"oldHead", head.block.Hash(BlockHasher{}),
+		"newHead", node.block.Hash(BlockHasher{}),
+	)
+
+	if err := bc.switchBranch(fork, node, true); err != nil {
+		// The blocks of the old branch and their voting events were
+		// announced before, they are only restored.
+		handlers := bc.votingState.setHandlers(nil)
+		defer bc.votingState.setHandlers(handlers)
+
+		if err := bc.switchBranch(fork, head, false); err != nil {
+			return fmt.Errorf("failed to restore head after invalid reorg: %w", err)
+		}
+
+		bc.lock.Lock()
+		bc.finalized = finalized
+		bc.lock.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// switchBranch rolls the canonical chain back to the fork point, after
+// which the blocks of the branch up to node are executed. Invalid blocks are
+// removed from the tree.
+//
+// When a block of a side branch is added, only the validator set schedule
+// of its last executed ancestor is known. The commits of a new branch are
+// therefore checked again while it is executed, against the validator sets
+// of the branch itself.
+func (bc *Blockchain) switchBranch(fork, node *blockNode, announce bool) error {
+	branch := []*blockNode{}
+	for n := node; n != fork; n = n.parent {
+		branch = append([]*blockNode{n}, branch...)
+	}
+
+	bc.rewind(fork)
+
+	for _, n := range branch {
+		err := error(nil)
+		if announce {
+			err = checkCommit(n.block, bc.ValidatorSetAt(n.block.Height))
+		}
+		if err == nil {
+			err = bc.connectBlock(n, announce)
+		}
+		if err != nil {
+			bc.removeBranch(n, node)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewind rolls the chain back to the given block of the canonical chain by
+// undoing the blocks above it, newest first.
+func (bc *Blockchain) rewind(to *blockNode) {
+	for {
+		bc.lock.Lock()
+		node := bc.head
+		if node == to {
+			bc.lock.Unlock()
+			return
+		}
+
+		b := node.block
+		bc.headers = bc.headers[:len(bc.headers)-1]
+		bc.blocks = bc.blocks[:len(bc.blocks)-1]
+		for _, tx := range b.Transactions {
+			hash := tx.Hash(TxHasher{})
+			delete(bc.txStore, hash)
+			delete(bc.receipts, hash)
+		}
+		bc.head = node.parent
+		undo := node.undo
+		node.undo = nil
+		bc.lock.Unlock()
+
+		// The undo functions take the locks of the states themselves.
+		undo()
+	}
+}
+
+// pruneUndo drops the undo functions of the finalized block and its
+// ancestors, they are never rolled back. As blocks are final at most
+// MaxReorgDepth blocks below the head, this bounds the number of undo
+// functions that are kept.
+func (bc *Blockchain) pruneUndo() {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	for n := bc.finalized; n != nil && n.undo != nil; n = n.parent {
+		n.undo = nil
+	}
+}
+
+// validatorSetOnBranch returns the validator set at the given height on the
+// branch that ends with the given block. The schedule of the last executed
+// block of the branch is used.
+func (bc *Blockchain) validatorSetOnBranch(hash types.Hash, height uint32) *ValidatorSet {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	n, ok := bc.tree[hash]
+	for ok && n != nil && n.validatorSets == nil {
+		n = n.parent
+	}
+	if !ok || n == nil {
+		return bc.validatorSetAt(height)
+	}
+
+	return validatorSetIn(n.validatorSets, height)
+}
+
+// removeBranch removes the blocks from "from" up to and including last from
+// the tree.
+func (bc *Blockchain) removeBranch(from, last *blockNode) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	for n := last; n != from.parent; n = n.parent {
+		hash := n.block.Hash(BlockHasher{})
+		delete(bc.tree, hash)
+		delete(bc.blockStore, hash)
+	}
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newChildBlock(t *testing.T, parent *Header, txx ...*Transaction) *Block {
+	b, err := NewBlockFromPrevHeader(parent, txx)
+	assert.Nil(t, err)
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+
+	return b
+}
+
+func newElectionTx(t *testing.T, electionID string) *Transaction {
+	privKey := crypto.GeneratePrivateKey()
+
+	tx := NewTransaction(nil)
+	tx.TxInner = ElectionCreationTx{
+		ElectionID:     electionID,
+		Title:          electionID,
+		StartTime:      0,
+		EndTime:        1,
+		AdminPublicKey: privKey.PublicKey(),
+	}
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+
+	return tx
+}
+
+func TestForkChoiceReorgToHeavierBranch(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	a1 := newChildBlock(t, genesis, newElectionTx(t, "a"))
+	assert.Nil(t, bc.AddBlock(a1))
+	_, err = bc.GetVotingState().GetElection("a")
+	assert.Nil(t, err)
+
+	// A side branch of the same weight does not replace the head.
+	b1 := newChildBlock(t, genesis, newElectionTx(t, "b"))
+	assert.Nil(t, bc.AddBlock(b1))
+	assert.Equal(t, uint32(1), bc.Height())
+	assert.True(t, bc.HasBlockHash(b1.Hash(BlockHasher{})))
+
+	head, err := bc.GetBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, a1.Hash(BlockHasher{}), head.Hash(BlockHasher{}))
+
+	_, err = bc.GetVotingState().GetElection("b")
+	assert.NotNil(t, err)
+
+	// Extending the side branch makes it heavier.
+	b2 := newChildBlock(t, b1.Header)
+	assert.Nil(t, bc.AddBlock(b2))
+	assert.Equal(t, uint32(2), bc.Height())
+
+	for h, b := range []*Block{b1, b2} {
+		block, err := bc.GetBlock(uint32(h + 1))
+		assert.Nil(t, err)
+		assert.Equal(t, b.Hash(BlockHasher{}), block.Hash(BlockHasher{}))
+	}
+
+	// The state only contains the transactions of the new branch.
+	_, err = bc.GetVotingState().GetElection("a")
+	assert.NotNil(t, err)
+	_, err = bc.GetVotingState().GetElection("b")
+	assert.Nil(t, err)
+
+	_, err = bc.GetTxByHash(a1.Transactions[0].Hash(TxHasher{}))
+	assert.NotNil(t, err)
+	_, err = bc.GetTxByHash(b1.Transactions[0].Hash(TxHasher{}))
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrBlockKnown, bc.AddBlock(b2))
+}
+
+func TestForkChoiceRejectsFinalizedConflict(t *testing.T) {
+	key := crypto.GeneratePrivateKey()
+
+	bc := newBlockchainWithGenesis(t)
+	bc.SetValidatorSet(NewValidatorSet(key.PublicKey()))
+
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	addCommittedBlock(t, bc, []crypto.PrivateKey{key})
+	assert.Equal(t, uint32(1), bc.FinalizedHeight())
+
+	// A competing block at the finalized height is rejected, even with a
+	// valid commit.
+	b, err := NewBlockFromPrevHeader(genesis, nil)
+	assert.Nil(t, err)
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(key))
+	b.Commit = newTestCommit(t, b, 0, key)
+	assert.Equal(t, ErrBelowFinalized, bc.AddBlock(b))
+	assert.Equal(t, uint32(1), bc.Height())
+}
+
+func TestFindForkPoint(t *testing.T) {
+	root := &blockNode{block: randomBlock(t, 0, types.Hash{})}
+	a := &blockNode{block: randomBlock(t, 1, types.Hash{}), parent: root}
+	b := &blockNode{block: randomBlock(t, 1, types.Hash{}), parent: root}
+	b2 := &blockNode{block: randomBlock(t, 2, types.Hash{}), parent: b}
+
+	assert.Equal(t, root, findForkPoint(a, b2))
+	assert.Equal(t, b, findForkPoint(b, b2))
+	assert.Equal(t, root, findForkPoint(root, root))
+}
+
+func TestForkChoiceKeepsHeadOnInvalidBranch(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	a1 := newChildBlock(t, genesis, newElectionTx(t, "a"))
+	assert.Nil(t, bc.AddBlock(a1))
+
+	// The second block of the branch repeats a transaction of the first.
+	tx := newElectionTx(t, "b")
+	b1 := newChildBlock(t, genesis, tx)
+	assert.Nil(t, bc.AddBlock(b1))
+	b2 := newChildBlock(t, b1.Header, tx)
+	assert.NotNil(t, bc.AddBlock(b2))
+
+	assert.Equal(t, uint32(1), bc.Height())
+	head, err := bc.GetBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, a1.Hash(BlockHasher{}), head.Hash(BlockHasher{}))
+	assert.False(t, bc.HasBlockHash(b2.Hash(BlockHasher{})))
+
+	_, err = bc.GetVotingState().GetElection("a")
+	assert.Nil(t, err)
+	_, err = bc.GetVotingState().GetElection("b")
+	assert.NotNil(t, err)
+}
+
+func TestForkChoiceReorgKeepsStateBelowForkPoint(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+
+	// The election ended by the clock of this node, but the blocks were
+	// produced while it was open.
+	end := time.Now().Unix() - 60
+	a1 := newChildBlockAt(t, bc.headers[0], end-30,
+		newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "e1", StartTime: end - 100, EndTime: end, AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}),
+		newVotingTx(t, admin, 1, CandidateRegistrationTx{CandidateID: "c1", ElectionID: "e1"}),
+	)
+	assert.Nil(t, bc.AddBlock(a1))
+
+	vs := bc.GetVotingState()
+	assert.Nil(t, vs.ApproveVoter("v1", admin.PublicKey()))
+	assert.Nil(t, vs.ApproveCandidate("e1", "c1", admin.PublicKey()))
+
+	vote := newVotingTx(t, voter, 1, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	a2 := newChildBlockAt(t, a1.Header, end-20)
+	assert.Nil(t, bc.AddBlock(a2))
+
+	// The heavier branch carries the vote, the approvals above the fork
+	// point are kept and the vote is checked against the block time.
+	b2 := newChildBlockAt(t, a1.Header, end-20, vote)
+	assert.Nil(t, bc.AddBlock(b2))
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, b2.Header, end-10)))
+	assert.Equal(t, uint32(3), bc.Height())
+
+	receipt, err := bc.GetReceipt(vote.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status, receipt.Error)
+
+	results, err := vs.GetElectionResults("e1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), results["c1"])
+
+	v, err := vs.GetVoter("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, VoterStatusApproved, v.Status)
+}
+
+func TestForkChoiceDoesNotAnnounceRestoredBlocks(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	announced := map[types.Hash]int{}
+	bc.OnBlock(func(b *Block) {
+		announced[b.Hash(BlockHasher{})]++
+	})
+
+	a1 := newChildBlock(t, genesis, newElectionTx(t, "a"))
+	assert.Nil(t, bc.AddBlock(a1))
+
+	tx := newElectionTx(t, "b")
+	b1 := newChildBlock(t, genesis, tx)
+	assert.Nil(t, bc.AddBlock(b1))
+	b2 := newChildBlock(t, b1.Header, tx)
+	assert.NotNil(t, bc.AddBlock(b2))
+
+	// b1 was announced while the chain switched to its branch, a1 is only
+	// restored after b2 turned out to be invalid.
+	assert.Equal(t, 1, announced[a1.Hash(BlockHasher{})])
+	assert.Equal(t, 1, announced[b1.Hash(BlockHasher{})])
+	assert.Equal(t, 0, announced[b2.Hash(BlockHasher{})])
+
+	head, err := bc.GetBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, a1.Hash(BlockHasher{}), head.Hash(BlockHasher{}))
+}
+
+func TestForkChoiceFinalizesDeepBlocks(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	a1 := newChildBlock(t, genesis)
+	assert.Nil(t, bc.AddBlock(a1))
+	b1 := newChildBlock(t, genesis)
+	assert.Nil(t, bc.AddBlock(b1))
+
+	for i := uint32(1); i <= MaxReorgDepth; i++ {
+		assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[i])))
+	}
+
+	// Without commits blocks are final once they are MaxReorgDepth deep,
+	// their undo functions are dropped.
+	assert.Equal(t, MaxReorgDepth+1, bc.Height())
+	assert.Equal(t, uint32(1), bc.FinalizedHeight())
+	for n := bc.finalized; n != nil; n = n.parent {
+		assert.Nil(t, n.undo)
+	}
+	assert.NotNil(t, bc.head.undo)
+
+	// Blocks of branches that fork below the finalized block are rejected
+	// and not kept.
+	b2 := newChildBlock(t, b1.Header)
+	assert.Equal(t, ErrBelowFinalized, bc.AddBlock(b2))
+	assert.False(t, bc.HasBlockHash(b2.Hash(BlockHasher{})))
+}
+
+func TestForkChoiceValidatesSideBranchWithItsValidatorSet(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	genesis, err := bc.GetHeader(0)
+	assert.Nil(t, err)
+
+	a1 := newChildBlock(t, genesis)
+	assert.Nil(t, bc.AddBlock(a1))
+	b1 := newChildBlock(t, genesis)
+	assert.Nil(t, bc.AddBlock(b1))
+
+	// The validator set only exists on the branch of a1.
+	key := crypto.GeneratePrivateKey()
+	bc.SetValidatorSet(NewValidatorSet(key.PublicKey()))
+	assert.Equal(t, 1, bc.validatorSetOnBranch(a1.Hash(BlockHasher{}), 2).Len())
+	assert.Equal(t, 0, bc.validatorSetOnBranch(b1.Hash(BlockHasher{}), 2).Len())
+
+	v := NewBlockValidator(bc)
+	assert.Equal(t, ErrMissingCommit, v.ValidateBlock(newChildBlock(t, a1.Header)))
+	assert.Nil(t, v.ValidateBlock(newChildBlock(t, b1.Header)))
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+// Genesis is the configuration every node of a network has to share. The
+// genesis block is derived from it, so nodes with the same genesis agree
+// on the genesis hash.
+type Genesis struct {
+	ChainID   string
+	Timestamp int64
+	// BlockTime is the time between two blocks.
+	BlockTime time.Duration
+	// The genesis validator set. If empty, any signer is accepted.
+	Validators []crypto.PublicKey
+	// Registrars can approve and reject voter registrations. If empty, any
+	// admin can.
+	Registrars []crypto.PublicKey
+	// Alloc holds the initial balances of accounts.
+	Alloc map[types.Address]uint64
+	// Fees is the fee schedule and block reward of the chain, by default
+	// transactions are free.
+	Fees FeeConfig
+	// EpochLength is the number of blocks in an epoch, validator set
+	// changes take effect at the start of an epoch. If zero,
+	// DefaultEpochLength is used.
+	EpochLength uint32
+}
+
+// genesisFile is the JSON representation of the genesis, keys and addresses
+// are hex encoded:
+//
+//	{
+//	  "chainId": "projectx-local",
+//	  "timestamp": 0,
+//	  "blockTime": "5s",
+//	  "validators": ["02ab..."],
+//	  "registrars": ["03cd..."],
+//	  "alloc": {"996f...": 10000000},
+//	  "fees": {"minGasPrice": 1, "blockReward": 50},
+//	  "epochLength": 100
+//	}
+type genesisFile struct {
+	ChainID    string            `json:"chainId"`
+	Timestamp  int64             `json:"timestamp"`
+	BlockTime  string            `json:"blockTime,omitempty"`
+	Validators []string          `json:"validators,omitempty"`
+	Registrars []string          `json:"registrars,omitempty"`
+	Alloc      map[string]uint64 `json:"alloc,omitempty"`
+	Fees       struct {
+		MinGasPrice uint64 `json:"minGasPrice"`
+		BlockReward uint64 `json:"blockReward"`
+	} `json:"fees"`
+	EpochLength uint32 `json:"epochLength,omitempty"`
+}
+
+// LoadGenesis reads the genesis from a JSON file.
+func LoadGenesis(path string) (*Genesis, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseGenesis(b)
+}
+
+// ParseGenesis decodes the JSON representation of the genesis.
+func ParseGenesis(b []byte) (*Genesis, error) {
+	var f genesisFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	if f.ChainID == "" {
+		return nil, fmt.Errorf("genesis has no chain ID")
+	}
+	if len(f.ChainID) > MaxChainIDLength {
+		return nil, fmt.Errorf("genesis chain ID is longer than %d bytes", MaxChainIDLength)
+	}
+
+	g := &Genesis{
+		ChainID:   f.ChainID,
+		Timestamp: f.Timestamp,
+		Alloc:     make(map[types.Address]uint64, len(f.Alloc)),
+		Fees: FeeConfig{
+			MinGasPrice: f.Fees.MinGasPrice,
+			BlockReward: f.Fees.BlockReward,
+		},
+		EpochLength: f.EpochLength,
+	}
+
+	if f.BlockTime != "" {
+		d, err := time.ParseDuration(f.BlockTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis block time: %w", err)
+		}
+		g.BlockTime = d
+	}
+
+	var err error
+	if g.Validators, err = decodePublicKeys(f.Validators); err != nil {
+		return nil, fmt.Errorf("invalid genesis validator: %w", err)
+	}
+	if g.Registrars, err = decodePublicKeys(f.Registrars); err != nil {
+		return nil, fmt.Errorf("invalid genesis registrar: %w", err)
+	}
+
+	for s, balance := range f.Alloc {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != 20 {
+			return nil, fmt.Errorf("invalid genesis alloc address (%s)", s)
+		}
+		g.Alloc[types.AddressFromBytes(b)] = balance
+	}
+
+	return g, nil
+}
+
+func decodePublicKeys(ss []string) ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, len(ss))
+	for i, s := range ss {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 33 {
+			return nil, fmt.Errorf("public key (%s) has invalid length %d", s, len(b))
+		}
+		keys[i] = b
+	}
+
+	return keys, nil
+}
+
+// Bytes returns the canonical encoding of the genesis, the accounts of the
+// allocation are sorted by address.
+func (g *Genesis) Bytes() []byte {
+	buf := new(bytes.Buffer)
+
+	writeBytes := func(b []byte) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+
+	writeBytes([]byte(g.ChainID))
+	binary.Write(buf, binary.LittleEndian, g.Timestamp)
+	binary.Write(buf, binary.LittleEndian, int64(g.BlockTime))
+	binary.Write(buf, binary.LittleEndian, g.Fees.MinGasPrice)
+	binary.Write(buf, binary.LittleEndian, g.Fees.BlockReward)
+	binary.Write(buf, binary.LittleEndian, g.epochLength())
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(g.Validators)))
+	for _, key := range g.Validators {
+		writeBytes(key)
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(len(g.Registrars)))
+	for _, key := range g.Registrars {
+		writeBytes(key)
+	}
+
+	addrs := make([]types.Address, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(addrs)))
+	for _, addr := range addrs {
+		buf.Write(addr[:])
+		binary.Write(buf, binary.LittleEndian, g.Alloc[addr])
+	}
+
+	return buf.Bytes()
+}
+
+// epochLength returns the epoch length of the chain, the default if none
+// is set.
+func (g *Genesis) epochLength() uint32 {
+	if g.EpochLength == 0 {
+		return DefaultEpochLength
+	}
+
+	return g.EpochLength
+}
+
+// Hash returns the hash of the canonical encoding of the genesis.
+func (g *Genesis) Hash() types.Hash {
+	return types.Hash(sha256.Sum256(g.Bytes()))
+}
+
+// Block returns the genesis block. It has no transactions and is not
+// signed, its data hash commits to the whole genesis so a different
+// allocation or validator set leads to a different genesis hash.
+func (g *Genesis) Block() *Block {
+	header := &Header{
+		Version:   1,
+		DataHash:  g.Hash(),
+		Height:    0,
+		Timestamp: g.Timestamp,
+		ChainID:   g.ChainID,
+	}
+
+	return &Block{
+		Header:       header,
+		Transactions: []*Transaction{},
+	}
+}
+
+// Genesis returns the genesis the chain was created from.
+func (bc *Blockchain) Genesis() *Genesis {
+	return bc.genesis
+}
+
+// ChainID returns the ID of the network the chain belongs to.
+func (bc *Blockchain) ChainID() string {
+	return bc.genesis.ChainID
+}
+
+// GenesisHash returns the hash of the genesis block.
+func (bc *Blockchain) GenesisHash() types.Hash {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return BlockHasher{}.Hash(bc.headers[0])
+}
+
+// newAccountState returns the account state of the genesis allocation.
+func (bc *Blockchain) newAccountState() *AccountState {
+	accountState := NewAccountState()
+	for addr, balance := range bc.genesis.Alloc {
+		accountState.CreateAccount(addr).Balance = balance
+	}
+
+	return accountState
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGenesis(t *testing.T) {
+	validator := crypto.GeneratePrivateKey().PublicKey()
+	registrar := crypto.GeneratePrivateKey().PublicKey()
+	addr := crypto.GeneratePrivateKey().PublicKey().Address()
+
+	g, err := ParseGenesis([]byte(fmt.Sprintf(`{
+		"chainId": "test",
+		"timestamp": 42,
+		"blockTime": "2s",
+		"validators": ["%s"],
+		"registrars": ["%s"],
+		"alloc": {"%s": 1000},
+		"fees": {"minGasPrice": 2, "blockReward": 50},
+		"epochLength": 10
+	}`, validator, registrar, addr)))
+	assert.Nil(t, err)
+	assert.Equal(t, "test", g.ChainID)
+	assert.Equal(t, int64(42), g.Timestamp)
+	assert.Equal(t, 2*time.Second, g.BlockTime)
+	assert.Equal(t, []crypto.PublicKey{validator}, g.Validators)
+	assert.Equal(t, []crypto.PublicKey{registrar}, g.Registrars)
+	assert.Equal(t, uint64(1000), g.Alloc[addr])
+	assert.Equal(t, FeeConfig{MinGasPrice: 2, BlockReward: 50}, g.Fees)
+	assert.Equal(t, uint32(10), g.EpochLength)
+
+	_, err = ParseGenesis([]byte(`{"blockTime": "2s"}`))
+	assert.NotNil(t, err)
+	_, err = ParseGenesis([]byte(`{"chainId": "test", "validators": ["abcd"]}`))
+	assert.NotNil(t, err)
+	_, err = ParseGenesis([]byte(`{"chainId": "test", "alloc": {"foo": 1}}`))
+	assert.NotNil(t, err)
+}
+
+func TestGenesisIsDeterministic(t *testing.T) {
+	alice := crypto.GeneratePrivateKey()
+	bob := crypto.GeneratePrivateKey().PublicKey().Address()
+	newGenesis := func() *Genesis {
+		return &Genesis{
+			ChainID: "test",
+			Alloc: map[types.Address]uint64{
+				alice.PublicKey().Address(): 1000,
+				bob:                         500,
+			},
+		}
+	}
+
+	a, err := NewBlockchain(log.NewNopLogger(), newGenesis())
+	assert.Nil(t, err)
+	b, err := NewBlockchain(log.NewNopLogger(), newGenesis())
+	assert.Nil(t, err)
+
+	headerA, _ := a.GetHeader(0)
+	headerB, _ := b.GetHeader(0)
+	assert.Equal(t, BlockHasher{}.Hash(headerA), BlockHasher{}.Hash(headerB))
+
+	// A different allocation leads to a different genesis.
+	other := newGenesis()
+	other.Alloc[bob] = 501
+	assert.NotEqual(t, newGenesis().Hash(), other.Hash())
+	other = newGenesis()
+	other.Fees.BlockReward = 1
+	assert.NotEqual(t, newGenesis().Hash(), other.Hash())
+	other = newGenesis()
+	other.EpochLength = 10
+	assert.NotEqual(t, newGenesis().Hash(), other.Hash())
+	other.EpochLength = DefaultEpochLength
+	assert.Equal(t, newGenesis().Hash(), other.Hash())
+	other = newGenesis()
+	other.ChainID = "other"
+	assert.NotEqual(t, newGenesis().Block().Hash(BlockHasher{}), other.Block().Hash(BlockHasher{}))
+
+	balance, err := a.accountState.GetBalance(bob)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(500), balance)
+
+	// The allocation is spendable, there is no special coinbase account.
+	tx := NewTransaction(nil)
+	tx.To = crypto.GeneratePrivateKey().PublicKey()
+	tx.Value = 1001
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(alice))
+	assert.Nil(t, a.AddBlock(newChildBlock(t, headerA, tx)))
+	receipt, err := a.GetReceipt(tx.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInsufficientBalance.Error(), receipt.Error)
+
+	_, err = a.accountState.GetAccount(crypto.PublicKey{}.Address())
+	assert.Equal(t, ErrAccountNotFound, err)
+}
+
+func TestGenesisRegistrars(t *testing.T) {
+	registrar := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+	bc, err := NewBlockchain(log.NewNopLogger(), &Genesis{ChainID: testChainID, Registrars: []crypto.PublicKey{registrar.PublicKey()}})
+	assert.Nil(t, err)
+
+	registration := newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()})
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], registration)))
+
+	vs := bc.GetVotingState()
+	assert.NotNil(t, vs.ApproveVoter("v1", voter.PublicKey()))
+	assert.NotNil(t, vs.RejectVoter("v1", voter.PublicKey()))
+	assert.Nil(t, vs.ApproveVoter("v1", registrar.PublicKey()))
+
+	v, err := vs.GetVoter("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, VoterStatusApproved, v.Status)
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 
@@ -21,15 +20,55 @@ func (BlockHasher) Hash(b *Header) types.Hash {
 
 type TxHasher struct{}
 
-// Hash will hash the whole bytes of the TX no exception.
+// Hash returns the hash of the canonical encoding of the transaction.
+// Transactions with an inner type that cannot be encoded have the zero hash,
+// they can neither be signed nor verified.
 func (TxHasher) Hash(tx *Transaction) types.Hash {
-	buf := new(bytes.Buffer)
+	b, err := txHashBytes(tx)
+	if err != nil {
+		return types.Hash{}
+	}
 
-	binary.Write(buf, binary.LittleEndian, tx.Data)
-	binary.Write(buf, binary.LittleEndian, tx.To)
-	binary.Write(buf, binary.LittleEndian, tx.Value)
-	binary.Write(buf, binary.LittleEndian, tx.From)
-	binary.Write(buf, binary.LittleEndian, tx.Nonce)
+	return types.Hash(sha256.Sum256(b))
+}
+
+// txHashBytes returns the encoding of the transaction that is hashed, it
+// covers every field except the signatures. Variable length fields are
+// prefixed with their length, all integers are little endian:
+//
+//	len(data) (4) | data | len(to) (4) | to | value (8) | len(from) (4) | from |
+//	nonce (8) | fee (8) | sponsored (1) | [len(election ID) (4) | election ID |
+//	len(sponsor) (4) | sponsor] | len(chain ID) (4) | chain ID | len(inner) (4) | inner
+//
+// The sponsorship fields are only present if sponsored is 1. The inner
+// transaction is hashed in its protobuf encoding, which is deterministic.
+func txHashBytes(tx *Transaction) ([]byte, error) {
+	inner, err := marshalTxInner(nil, tx.TxInner)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte{}
+	b = appendLengthPrefixed(b, tx.Data)
+	b = appendLengthPrefixed(b, tx.To)
+	b = binary.LittleEndian.AppendUint64(b, tx.Value)
+	b = appendLengthPrefixed(b, tx.From)
+	b = binary.LittleEndian.AppendUint64(b, tx.Nonce)
+	b = binary.LittleEndian.AppendUint64(b, tx.Fee)
+	if tx.Sponsorship != nil {
+		b = append(b, 1)
+		b = appendLengthPrefixed(b, []byte(tx.Sponsorship.ElectionID))
+		b = appendLengthPrefixed(b, tx.Sponsorship.Sponsor)
+	} else {
+		b = append(b, 0)
+	}
+	b = appendLengthPrefixed(b, []byte(tx.ChainID))
+	b = appendLengthPrefixed(b, inner)
+
+	return b, nil
+}
 
-	return types.Hash(sha256.Sum256(buf.Bytes()))
+func appendLengthPrefixed(b, data []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
 }
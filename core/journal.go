@@ -0,0 +1,120 @@
+package core
+
+import "sync"
+
+// Journal is an undo log for state changes. After a snapshot has been taken
+// every change records how to undo it, until the journal is committed.
+// Changes made while no snapshot is open are not recorded.
+type Journal struct {
+	mu        sync.Mutex
+	recording bool
+	entries   []journalEntry
+}
+
+// journalEntry either undoes a change, or is run once the change it
+// belongs to is committed.
+type journalEntry struct {
+	undo     func()
+	onCommit func()
+}
+
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Snapshot starts recording changes and returns an id that the state can
+// be reverted to.
+func (j *Journal) Snapshot() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.recording = true
+	return len(j.entries)
+}
+
+// RevertTo undoes all changes made after the given snapshot, newest first.
+func (j *Journal) RevertTo(id int) {
+	j.mu.Lock()
+	if id < 0 || id > len(j.entries) {
+		j.mu.Unlock()
+		return
+	}
+	reverted := j.entries[id:]
+	j.entries = j.entries[:id]
+	j.mu.Unlock()
+
+	for i := len(reverted) - 1; i >= 0; i-- {
+		if reverted[i].undo != nil {
+			reverted[i].undo()
+		}
+	}
+}
+
+// Commit makes all recorded changes permanent and stops recording.
+func (j *Journal) Commit() {
+	j.commit()
+}
+
+// CommitUndo commits the recorded changes like Commit, but returns a
+// function that undoes them later on, newest first. It is used to roll back
+// the blocks of a branch when the chain is reorganized.
+func (j *Journal) CommitUndo() func() {
+	committed := j.commit()
+
+	return func() {
+		for i := len(committed) - 1; i >= 0; i-- {
+			if committed[i].undo != nil {
+				committed[i].undo()
+			}
+		}
+	}
+}
+
+func (j *Journal) commit() []journalEntry {
+	j.mu.Lock()
+	committed := j.entries
+	j.entries = nil
+	j.recording = false
+	j.mu.Unlock()
+
+	for _, e := range committed {
+		if e.onCommit != nil {
+			e.onCommit()
+		}
+	}
+
+	return committed
+}
+
+// record adds the undo function of a change that was just made.
+func (j *Journal) record(undo func()) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.recording {
+		j.entries = append(j.entries, journalEntry{undo: undo})
+	}
+}
+
+// onCommit delays fn until the current changes are committed, it is
+// dropped if they are reverted. It returns false without keeping fn if no
+// snapshot is open.
+func (j *Journal) onCommit(fn func()) bool {
+	if j == nil {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.recording {
+		return false
+	}
+	j.entries = append(j.entries, journalEntry{onCommit: fn})
+
+	return true
+}
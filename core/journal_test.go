@@ -0,0 +1,103 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalRevertTo(t *testing.T) {
+	j := NewJournal()
+	accounts := NewAccountState()
+	accounts.journal = j
+	state := NewState()
+	state.journal = j
+
+	alice := crypto.GeneratePrivateKey().PublicKey().Address()
+	bob := crypto.GeneratePrivateKey().PublicKey().Address()
+
+	// Changes without an open snapshot are not recorded.
+	accounts.CreateAccount(alice).Balance = 100
+	assert.Nil(t, state.Put([]byte("foo"), []byte("bar")))
+
+	snapshot := j.Snapshot()
+	assert.Nil(t, accounts.Transfer(alice, bob, 40))
+	assert.Nil(t, state.Put([]byte("foo"), []byte("baz")))
+
+	inner := j.Snapshot()
+	assert.Nil(t, state.Put([]byte("new"), []byte("value")))
+	j.RevertTo(inner)
+
+	_, err := state.Get([]byte("new"))
+	assert.NotNil(t, err)
+	value, err := state.Get([]byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("baz"), value)
+
+	j.RevertTo(snapshot)
+	j.Commit()
+
+	balance, err := accounts.GetBalance(alice)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), balance)
+	_, err = accounts.GetAccount(bob)
+	assert.Equal(t, ErrAccountNotFound, err)
+	value, err = state.Get([]byte("foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bar"), value)
+}
+
+func TestFailedTransactionLeavesNoEffects(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	events := []VotingEvent{}
+	bc.GetVotingState().OnEvent(func(ev VotingEvent) {
+		events = append(events, ev)
+	})
+
+	privKey := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(privKey.PublicKey().Address())
+
+	// The election is created before the transfer of the tx fails.
+	now := time.Now().Unix()
+	tx := NewTransaction(nil)
+	tx.To = crypto.GeneratePrivateKey().PublicKey()
+	tx.Value = 10
+	tx.TxInner = ElectionCreationTx{
+		ElectionID:     "e1",
+		StartTime:      now - 10,
+		EndTime:        now + 100,
+		AdminPublicKey: privKey.PublicKey(),
+	}
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], tx)))
+	assert.Equal(t, uint32(1), bc.Height())
+
+	_, err := bc.GetVotingState().GetElection("e1")
+	assert.NotNil(t, err)
+	_, err = bc.accountState.GetAccount(tx.To.Address())
+	assert.Equal(t, ErrAccountNotFound, err)
+	assert.Empty(t, events)
+
+	// Without the failing transfer the election is created and announced.
+	// The failed transaction used up the first nonce.
+	tx = NewTransaction(nil)
+	tx.TxInner = ElectionCreationTx{
+		ElectionID:     "e1",
+		StartTime:      now - 10,
+		EndTime:        now + 100,
+		AdminPublicKey: privKey.PublicKey(),
+	}
+	tx.Nonce = 1
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], tx)))
+	_, err = bc.GetVotingState().GetElection("e1")
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, VotingEventElectionOpened, events[0].Type)
+}
@@ -0,0 +1,771 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtoEncodingVersion is written in front of every protobuf encoded
+// transaction and block so the wire format can evolve.
+const ProtoEncodingVersion byte = 0x01
+
+// Maximum size of a single protobuf encoded transaction or block.
+const maxProtoMessageSize = 32 << 20
+
+//
+// Protobuf wire encoding of transactions and blocks as defined in
+// grpcapi/bockvote.proto. Field numbers MUST stay in sync with the schema,
+// proto_schema_test.go round-trips the encoding through the schema.
+// Zero values are omitted and fields are always written in field number
+// order, which makes the encoding canonical.
+//
+// On the wire every message is framed as:
+//
+//	[version byte][uvarint length][protobuf message]
+//
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	return appendVarint(b, num, protowire.EncodeBool(v))
+}
+
+// appendMessage always writes the field, even if the message is empty, so
+// the presence of the field survives the round trip.
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// decodeFields calls fn for every field in b. Unknown fields are skipped
+// by fn simply ignoring them.
+func decodeFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+func decodeHash(b []byte) (types.Hash, error) {
+	if len(b) != 32 {
+		return types.Hash{}, fmt.Errorf("invalid hash length %d", len(b))
+	}
+	return types.HashFromBytes(b), nil
+}
+
+func marshalSignature(sig *crypto.Signature) []byte {
+	b := []byte{}
+	b = appendBytes(b, 1, sig.R.Bytes())
+	b = appendBytes(b, 2, sig.S.Bytes())
+	return b
+}
+
+func unmarshalSignature(b []byte) (*crypto.Signature, error) {
+	sig := &crypto.Signature{
+		R: new(big.Int),
+		S: new(big.Int),
+	}
+
+	err := decodeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			sig.R.SetBytes(f.bytes)
+		case 2:
+			sig.S.SetBytes(f.bytes)
+		}
+		return nil
+	})
+
+	return sig, err
+}
+
+func marshalTxInner(b []byte, inner any) ([]byte, error) {
+	switch t := inner.(type) {
+	case nil:
+		return b, nil
+	case CollectionTx:
+		m := appendVarint(nil, 1, uint64(t.Fee))
+		m = appendBytes(m, 2, t.MetaData)
+		return appendMessage(b, 10, m), nil
+	case MintTx:
+		m := appendVarint(nil, 1, uint64(t.Fee))
+		m = appendBytes(m, 2, t.NFT.ToSlice())
+		m = appendBytes(m, 3, t.Collection.ToSlice())
+		m = appendBytes(m, 4, t.MetaData)
+		m = appendBytes(m, 5, t.CollectionOwner)
+		m = appendSignature(m, 6, t.Signature)
+		return appendMessage(b, 11, m), nil
+	case VoterRegistrationTx:
+		m := appendString(nil, 1, t.VoterID)
+		m = appendString(m, 2, t.IPFSDocHash)
+		m = appendBytes(m, 3, t.VoterPublicKey)
+		m = appendSignature(m, 4, t.Signature)
+		m = appendVarint(m, 5, uint64(t.Timestamp))
+		return appendMessage(b, 12, m), nil
+	case CandidateRegistrationTx:
+		m := appendString(nil, 1, t.CandidateID)
+		m = appendString(m, 2, t.ElectionID)
+		m = appendString(m, 3, t.IPFSProfileHash)
+		m = appendBytes(m, 4, t.CandidatePublicKey)
+		m = appendSignature(m, 5, t.Signature)
+		m = appendVarint(m, 6, uint64(t.Timestamp))
+		return appendMessage(b, 13, m), nil
+	case VoteTx:
+		m := appendString(nil, 1, t.ElectionID)
+		m = appendString(m, 2, t.CandidateID)
+		m = appendBytes(m, 3, t.VoterPublicKey)
+		m = appendSignature(m, 4, t.Signature)
+		m = appendVarint(m, 5, uint64(t.Timestamp))
+		return appendMessage(b, 14, m), nil
+	case ElectionCreationTx:
+		m := appendString(nil, 1, t.ElectionID)
+		m = appendString(m, 2, t.Title)
+		m = appendString(m, 3, t.Description)
+		m = appendVarint(m, 4, uint64(t.StartTime))
+		m = appendVarint(m, 5, uint64(t.EndTime))
+		m = appendBytes(m, 6, t.AdminPublicKey)
+		m = appendSignature(m, 7, t.Signature)
+		m = appendVarint(m, 8, uint64(t.Timestamp))
+		return appendMessage(b, 15, m), nil
+	case ValidatorSetChangeTx:
+		m := appendVarint(nil, 1, uint64(t.Change))
+		m = appendBytes(m, 2, t.Validator)
+		m = appendVarint(m, 3, uint64(t.EffectiveHeight))
+		for _, a := range t.Approvals {
+			am := appendBytes(nil, 1, a.Validator)
+			am = appendSignature(am, 2, a.Signature)
+			m = appendMessage(m, 4, am)
+		}
+		return appendMessage(b, 16, m), nil
+	case ElectionBudgetTx:
+		m := appendString(nil, 1, t.ElectionID)
+		m = appendVarint(m, 2, t.Amount)
+		return appendMessage(b, 17, m), nil
+	case VoterApprovalTx:
+		m := appendString(nil, 1, t.VoterID)
+		m = appendBool(m, 2, t.Approve)
+		return appendMessage(b, 18, m), nil
+	case CandidateApprovalTx:
+		m := appendString(nil, 1, t.ElectionID)
+		m = appendString(m, 2, t.CandidateID)
+		m = appendBool(m, 3, t.Approve)
+		return appendMessage(b, 19, m), nil
+	default:
+		return nil, fmt.Errorf("unsupported tx inner type %T", inner)
+	}
+}
+
+// appendSignature writes the embedded (non pointer) signatures of the tx
+// inner types. Signatures that were never set are omitted.
+func appendSignature(b []byte, num protowire.Number, sig crypto.Signature) []byte {
+	if sig.R == nil || sig.S == nil {
+		return b
+	}
+	return appendMessage(b, num, marshalSignature(&sig))
+}
+
+func unmarshalTxInner(num protowire.Number, b []byte) (any, error) {
+	var (
+		inner any
+		err   error
+	)
+
+	switch num {
+	case 10:
+		t := CollectionTx{}
+		err = decodeFields(b, func(f field) error {
+			switch f.num {
+			case 1:
+				t.Fee = int64(f.varint)
+			case 2:
+				t.MetaData = copyBytes(f.bytes)
+			}
+			return nil
+		})
+		inner = t
+	case 11:
+		t := MintTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.Fee = int64(f.varint)
+			case 2:
+				t.NFT, err = decodeHash(f.bytes)
+			case 3:
+				t.Collection, err = decodeHash(f.bytes)
+			case 4:
+				t.MetaData = copyBytes(f.bytes)
+			case 5:
+				t.CollectionOwner = copyBytes(f.bytes)
+			case 6:
+				t.Signature, err = unmarshalInnerSignature(f.bytes)
+			}
+			return
+		})
+		inner = t
+	case 12:
+		t := VoterRegistrationTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.VoterID = string(f.bytes)
+			case 2:
+				t.IPFSDocHash = string(f.bytes)
+			case 3:
+				t.VoterPublicKey = copyBytes(f.bytes)
+			case 4:
+				t.Signature, err = unmarshalInnerSignature(f.bytes)
+			case 5:
+				t.Timestamp = int64(f.varint)
+			}
+			return
+		})
+		inner = t
+	case 13:
+		t := CandidateRegistrationTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.CandidateID = string(f.bytes)
+			case 2:
+				t.ElectionID = string(f.bytes)
+			case 3:
+				t.IPFSProfileHash = string(f.bytes)
+			case 4:
+				t.CandidatePublicKey = copyBytes(f.bytes)
+			case 5:
+				t.Signature, err = unmarshalInnerSignature(f.bytes)
+			case 6:
+				t.Timestamp = int64(f.varint)
+			}
+			return
+		})
+		inner = t
+	case 14:
+		t := VoteTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.ElectionID = string(f.bytes)
+			case 2:
+				t.CandidateID = string(f.bytes)
+			case 3:
+				t.VoterPublicKey = copyBytes(f.bytes)
+			case 4:
+				t.Signature, err = unmarshalInnerSignature(f.bytes)
+			case 5:
+				t.Timestamp = int64(f.varint)
+			}
+			return
+		})
+		inner = t
+	case 15:
+		t := ElectionCreationTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.ElectionID = string(f.bytes)
+			case 2:
+				t.Title = string(f.bytes)
+			case 3:
+				t.Description = string(f.bytes)
+			case 4:
+				t.StartTime = int64(f.varint)
+			case 5:
+				t.EndTime = int64(f.varint)
+			case 6:
+				t.AdminPublicKey = copyBytes(f.bytes)
+			case 7:
+				t.Signature, err = unmarshalInnerSignature(f.bytes)
+			case 8:
+				t.Timestamp = int64(f.varint)
+			}
+			return
+		})
+		inner = t
+	case 16:
+		t := ValidatorSetChangeTx{}
+		err = decodeFields(b, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				t.Change = ValidatorChangeType(f.varint)
+			case 2:
+				t.Validator = copyBytes(f.bytes)
+			case 3:
+				t.EffectiveHeight = uint32(f.varint)
+			case 4:
+				a := ValidatorApproval{}
+				err = decodeFields(f.bytes, func(f field) (err error) {
+					switch f.num {
+					case 1:
+						a.Validator = copyBytes(f.bytes)
+					case 2:
+						a.Signature, err = unmarshalInnerSignature(f.bytes)
+					}
+					return
+				})
+				t.Approvals = append(t.Approvals, a)
+			}
+			return
+		})
+		inner = t
+	case 17:
+		t := ElectionBudgetTx{}
+		err = decodeFields(b, func(f field) error {
+			switch f.num {
+			case 1:
+				t.ElectionID = string(f.bytes)
+			case 2:
+				t.Amount = f.varint
+			}
+			return nil
+		})
+		inner = t
+	case 18:
+		t := VoterApprovalTx{}
+		err = decodeFields(b, func(f field) error {
+			switch f.num {
+			case 1:
+				t.VoterID = string(f.bytes)
+			case 2:
+				t.Approve = f.varint != 0
+			}
+			return nil
+		})
+		inner = t
+	case 19:
+		t := CandidateApprovalTx{}
+		err = decodeFields(b, func(f field) error {
+			switch f.num {
+			case 1:
+				t.ElectionID = string(f.bytes)
+			case 2:
+				t.CandidateID = string(f.bytes)
+			case 3:
+				t.Approve = f.varint != 0
+			}
+			return nil
+		})
+		inner = t
+	}
+
+	return inner, err
+}
+
+func unmarshalInnerSignature(b []byte) (crypto.Signature, error) {
+	sig, err := unmarshalSignature(b)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	return *sig, nil
+}
+
+func marshalTransaction(tx *Transaction) ([]byte, error) {
+	b := []byte{}
+	b = appendBytes(b, 1, tx.Data)
+	b = appendBytes(b, 2, tx.To)
+	b = appendVarint(b, 3, tx.Value)
+	b = appendBytes(b, 4, tx.From)
+	if tx.Signature != nil {
+		b = appendMessage(b, 5, marshalSignature(tx.Signature))
+	}
+	b = appendVarint(b, 6, tx.Nonce)
+	b = appendVarint(b, 7, tx.Fee)
+	if s := tx.Sponsorship; s != nil {
+		m := appendString(nil, 1, s.ElectionID)
+		m = appendBytes(m, 2, s.Sponsor)
+		if s.Signature != nil {
+			m = appendMessage(m, 3, marshalSignature(s.Signature))
+		}
+		b = appendMessage(b, 8, m)
+	}
+	b = appendString(b, 9, tx.ChainID)
+
+	return marshalTxInner(b, tx.TxInner)
+}
+
+func unmarshalTransaction(b []byte, tx *Transaction) error {
+	return decodeFields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			tx.Data = copyBytes(f.bytes)
+		case 2:
+			tx.To = copyBytes(f.bytes)
+		case 3:
+			tx.Value = f.varint
+		case 4:
+			tx.From = copyBytes(f.bytes)
+		case 5:
+			tx.Signature, err = unmarshalSignature(f.bytes)
+		case 6:
+			tx.Nonce = f.varint
+		case 7:
+			tx.Fee = f.varint
+		case 8:
+			tx.Sponsorship, err = unmarshalSponsorship(f.bytes)
+		case 9:
+			tx.ChainID = string(f.bytes)
+		case 10, 11, 12, 13, 14, 15, 16, 17, 18, 19:
+			tx.TxInner, err = unmarshalTxInner(f.num, f.bytes)
+		}
+		return
+	})
+}
+
+func unmarshalSponsorship(b []byte) (*Sponsorship, error) {
+	s := &Sponsorship{}
+	err := decodeFields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			s.ElectionID = string(f.bytes)
+		case 2:
+			s.Sponsor = copyBytes(f.bytes)
+		case 3:
+			s.Signature, err = unmarshalSignature(f.bytes)
+		}
+		return
+	})
+
+	return s, err
+}
+
+func marshalHeader(h *Header) []byte {
+	b := []byte{}
+	b = appendVarint(b, 1, uint64(h.Version))
+	b = appendBytes(b, 2, h.DataHash.ToSlice())
+	b = appendBytes(b, 3, h.PrevBlockHash.ToSlice())
+	b = appendVarint(b, 4, uint64(h.Height))
+	b = appendVarint(b, 5, uint64(h.Timestamp))
+	b = appendString(b, 6, h.ChainID)
+	return b
+}
+
+func unmarshalHeader(b []byte) (*Header, error) {
+	h := &Header{}
+	err := decodeFields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			h.Version = uint32(f.varint)
+		case 2:
+			h.DataHash, err = decodeHash(f.bytes)
+		case 3:
+			h.PrevBlockHash, err = decodeHash(f.bytes)
+		case 4:
+			h.Height = uint32(f.varint)
+		case 5:
+			h.Timestamp = int64(f.varint)
+		case 6:
+			h.ChainID = string(f.bytes)
+		}
+		return
+	})
+
+	return h, err
+}
+
+func marshalBlock(block *Block) ([]byte, error) {
+	b := []byte{}
+	if block.Header != nil {
+		b = appendMessage(b, 1, marshalHeader(block.Header))
+	}
+	for _, tx := range block.Transactions {
+		m, err := marshalTransaction(tx)
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 2, m)
+	}
+	b = appendBytes(b, 3, block.Validator)
+	if block.Signature != nil {
+		b = appendMessage(b, 4, marshalSignature(block.Signature))
+	}
+	if block.Commit != nil {
+		b = appendMessage(b, 5, marshalCommit(block.Commit))
+	}
+
+	return b, nil
+}
+
+func unmarshalBlock(b []byte, block *Block) error {
+	return decodeFields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			block.Header, err = unmarshalHeader(f.bytes)
+		case 2:
+			tx := new(Transaction)
+			if err := unmarshalTransaction(f.bytes, tx); err != nil {
+				return err
+			}
+			block.Transactions = append(block.Transactions, tx)
+		case 3:
+			block.Validator = copyBytes(f.bytes)
+		case 4:
+			block.Signature, err = unmarshalSignature(f.bytes)
+		case 5:
+			block.Commit, err = unmarshalCommit(f.bytes)
+		}
+		return
+	})
+}
+
+func marshalCommit(c *Commit) []byte {
+	b := []byte{}
+	b = appendVarint(b, 1, uint64(c.Height))
+	b = appendVarint(b, 2, uint64(c.Round))
+	b = appendBytes(b, 3, c.BlockHash.ToSlice())
+	for _, sig := range c.Signatures {
+		m := appendBytes(nil, 1, sig.Validator)
+		if sig.Signature != nil {
+			m = appendMessage(m, 2, marshalSignature(sig.Signature))
+		}
+		b = appendMessage(b, 4, m)
+	}
+	return b
+}
+
+func unmarshalCommit(b []byte) (*Commit, error) {
+	c := &Commit{}
+	err := decodeFields(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			c.Height = uint32(f.varint)
+		case 2:
+			c.Round = uint32(f.varint)
+		case 3:
+			c.BlockHash, err = decodeHash(f.bytes)
+		case 4:
+			sig := CommitSig{}
+			err = decodeFields(f.bytes, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					sig.Validator = copyBytes(f.bytes)
+				case 2:
+					sig.Signature, err = unmarshalSignature(f.bytes)
+				}
+				return
+			})
+			c.Signatures = append(c.Signatures, sig)
+		}
+		return
+	})
+
+	return c, err
+}
+
+// MarshalProtoTx returns the bare protobuf encoding of tx, without the
+// version byte and length prefix.
+func MarshalProtoTx(tx *Transaction) ([]byte, error) {
+	return marshalTransaction(tx)
+}
+
+func UnmarshalProtoTx(b []byte, tx *Transaction) error {
+	return unmarshalTransaction(b, tx)
+}
+
+// MarshalProtoBlock returns the bare protobuf encoding of b, without the
+// version byte and length prefix.
+func MarshalProtoBlock(b *Block) ([]byte, error) {
+	return marshalBlock(b)
+}
+
+func UnmarshalProtoBlock(data []byte, b *Block) error {
+	return unmarshalBlock(data, b)
+}
+
+func writeProtoFrame(w io.Writer, msg []byte) error {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(msg))
+	buf = append(buf, ProtoEncodingVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(msg)))
+	buf = append(buf, msg...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readProtoFrame(r protoReader) ([]byte, error) {
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != ProtoEncodingVersion {
+		return nil, fmt.Errorf("unsupported proto encoding version %d", version)
+	}
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxProtoMessageSize {
+		return nil, fmt.Errorf("proto message size %d exceeds maximum of %d", size, maxProtoMessageSize)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+type protoReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// newProtoReader makes sure we never read past the end of a frame, so
+// multiple frames can be decoded from the same reader.
+func newProtoReader(r io.Reader) protoReader {
+	if pr, ok := r.(protoReader); ok {
+		return pr
+	}
+	return singleByteReader{r}
+}
+
+type singleByteReader struct {
+	io.Reader
+}
+
+func (r singleByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
+
+type ProtoTxEncoder struct {
+	w io.Writer
+}
+
+func NewProtoTxEncoder(w io.Writer) *ProtoTxEncoder {
+	return &ProtoTxEncoder{
+		w: w,
+	}
+}
+
+func (e *ProtoTxEncoder) Encode(tx *Transaction) error {
+	msg, err := marshalTransaction(tx)
+	if err != nil {
+		return err
+	}
+	return writeProtoFrame(e.w, msg)
+}
+
+type ProtoTxDecoder struct {
+	r protoReader
+}
+
+func NewProtoTxDecoder(r io.Reader) *ProtoTxDecoder {
+	return &ProtoTxDecoder{
+		r: newProtoReader(r),
+	}
+}
+
+func (d *ProtoTxDecoder) Decode(tx *Transaction) error {
+	msg, err := readProtoFrame(d.r)
+	if err != nil {
+		return err
+	}
+	return unmarshalTransaction(msg, tx)
+}
+
+type ProtoBlockEncoder struct {
+	w io.Writer
+}
+
+func NewProtoBlockEncoder(w io.Writer) *ProtoBlockEncoder {
+	return &ProtoBlockEncoder{
+		w: w,
+	}
+}
+
+func (e *ProtoBlockEncoder) Encode(b *Block) error {
+	msg, err := marshalBlock(b)
+	if err != nil {
+		return err
+	}
+	return writeProtoFrame(e.w, msg)
+}
+
+type ProtoBlockDecoder struct {
+	r protoReader
+}
+
+func NewProtoBlockDecoder(r io.Reader) *ProtoBlockDecoder {
+	return &ProtoBlockDecoder{
+		r: newProtoReader(r),
+	}
+}
+
+func (d *ProtoBlockDecoder) Decode(b *Block) error {
+	msg, err := readProtoFrame(d.r)
+	if err != nil {
+		return err
+	}
+	return unmarshalBlock(msg, b)
+}
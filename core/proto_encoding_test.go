@@ -0,0 +1,104 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtoTxEncodeDecode(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := NewTransaction([]byte("foo"))
+	tx.From = privKey.PublicKey()
+	tx.TxInner = ElectionCreationTx{
+		ElectionID:     "e1",
+		Title:          "title",
+		StartTime:      -10,
+		EndTime:        100,
+		AdminPublicKey: privKey.PublicKey(),
+	}
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(NewProtoTxEncoder(buf)))
+	assert.Equal(t, ProtoEncodingVersion, buf.Bytes()[0])
+
+	txDecoded := new(Transaction)
+	assert.Nil(t, txDecoded.Decode(NewProtoTxDecoder(buf)))
+	assert.Nil(t, txDecoded.Verify())
+	assert.Equal(t, tx, txDecoded)
+}
+
+func TestProtoApprovalTxEncodeDecode(t *testing.T) {
+	for _, inner := range []any{
+		VoterApprovalTx{VoterID: "v1", Approve: true},
+		VoterApprovalTx{VoterID: "v1"},
+		CandidateApprovalTx{ElectionID: "e1", CandidateID: "c1", Approve: true},
+	} {
+		tx := NewTransaction(nil)
+		tx.TxInner = inner
+		tx.ChainID = testChainID
+		assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, tx.Encode(NewProtoTxEncoder(buf)))
+
+		txDecoded := new(Transaction)
+		assert.Nil(t, txDecoded.Decode(NewProtoTxDecoder(buf)))
+		assert.Nil(t, txDecoded.Verify())
+		assert.Equal(t, tx, txDecoded)
+	}
+}
+
+func TestProtoBlockEncodeDecode(t *testing.T) {
+	b := randomBlock(t, 1, types.Hash{})
+	b.Commit = newTestCommit(t, b, 2, crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey())
+	buf := &bytes.Buffer{}
+	assert.Nil(t, b.Encode(NewProtoBlockEncoder(buf)))
+
+	bDecode := new(Block)
+	assert.Nil(t, bDecode.Decode(NewProtoBlockDecoder(buf)))
+	assert.Equal(t, b.Header, bDecode.Header)
+	assert.Equal(t, b.Validator, bDecode.Validator)
+	assert.Equal(t, b.Signature, bDecode.Signature)
+	assert.Equal(t, b.Commit, bDecode.Commit)
+	assert.Nil(t, bDecode.Verify())
+}
+
+func TestProtoDecodeMultipleFrames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	txx := []*Transaction{randomTxWithSignature(t), randomTxWithSignature(t)}
+	for _, tx := range txx {
+		assert.Nil(t, tx.Encode(NewProtoTxEncoder(buf)))
+	}
+
+	// Use a reader that does not implement io.ByteReader.
+	r := struct{ *bytes.Buffer }{buf}
+	for _, tx := range txx {
+		txDecoded := new(Transaction)
+		assert.Nil(t, txDecoded.Decode(NewProtoTxDecoder(r)))
+		assert.Equal(t, tx.Signature, txDecoded.Signature)
+	}
+}
+
+func TestProtoDecodeInvalidVersion(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, randomTxWithSignature(t).Encode(NewProtoTxEncoder(buf)))
+	buf.Bytes()[0] = 0xff
+
+	assert.NotNil(t, new(Transaction).Decode(NewProtoTxDecoder(buf)))
+}
+
+func TestProtoEncodingIsDeterministic(t *testing.T) {
+	tx := randomTxWithSignature(t)
+
+	a, err := MarshalProtoTx(tx)
+	assert.Nil(t, err)
+	b, err := MarshalProtoTx(tx)
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+}
@@ -0,0 +1,490 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The tests in this file keep the hand written encoding of
+// proto_encoding.go in sync with grpcapi/bockvote.proto. Fully populated
+// values are encoded by core, decoded with the schema through dynamicpb and
+// encoded again. The schema must know every field core writes, core must
+// write every field of the schema and both encodings must be identical.
+
+func loadSchema(t *testing.T) protoreflect.FileDescriptor {
+	src, err := os.ReadFile("../grpcapi/bockvote.proto")
+	assert.Nil(t, err)
+
+	fdp, err := parseProtoSchema(string(src))
+	assert.Nil(t, err)
+
+	fd, err := protodesc.NewFile(fdp, nil)
+	assert.Nil(t, err)
+
+	return fd
+}
+
+func TestProtoSchemaTransaction(t *testing.T) {
+	schema := loadSchema(t).Messages().ByName("Transaction")
+	seen := map[protoreflect.FullName]bool{}
+
+	for _, inner := range fullTxInners(t) {
+		tx := NewTransaction([]byte("data"))
+		tx.To = crypto.GeneratePrivateKey().PublicKey()
+		tx.Value = 10
+		tx.Nonce = 3
+		tx.Fee = 7
+		tx.ChainID = testChainID
+		tx.TxInner = inner
+		sponsor := crypto.GeneratePrivateKey()
+		tx.SetSponsor("e1", sponsor.PublicKey())
+		assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+		assert.Nil(t, tx.SignSponsorship(sponsor))
+
+		b, err := marshalTransaction(tx)
+		assert.Nil(t, err)
+
+		b = roundTripSchema(t, schema, b, seen)
+		decoded := new(Transaction)
+		assert.Nil(t, unmarshalTransaction(b, decoded))
+		decoded.Hash(TxHasher{})
+		assert.Equal(t, tx, decoded, "%T", inner)
+	}
+
+	assertAllFieldsSeen(t, schema, seen)
+}
+
+func TestProtoSchemaBlock(t *testing.T) {
+	schema := loadSchema(t).Messages().ByName("Block")
+	seen := map[protoreflect.FullName]bool{}
+
+	tx := NewTransaction(nil)
+	tx.TxInner = VoteTx{ElectionID: "e1", CandidateID: "c1"}
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+	b := &Block{
+		Header: &Header{
+			Version:       1,
+			DataHash:      types.Hash{1},
+			PrevBlockHash: types.Hash{2},
+			Height:        3,
+			Timestamp:     4,
+			ChainID:       testChainID,
+		},
+		Transactions: []*Transaction{tx},
+	}
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	b.Commit = newTestCommit(t, b, 1, crypto.GeneratePrivateKey())
+
+	encoded, err := marshalBlock(b)
+	assert.Nil(t, err)
+
+	encoded = roundTripSchema(t, schema, encoded, seen)
+	decoded := new(Block)
+	assert.Nil(t, unmarshalBlock(encoded, decoded))
+	assert.Equal(t, b.Header, decoded.Header)
+	assert.Equal(t, b.Transactions[0].Hash(TxHasher{}), decoded.Transactions[0].Hash(TxHasher{}))
+	assert.Equal(t, b.Validator, decoded.Validator)
+	assert.Equal(t, b.Signature, decoded.Signature)
+	assert.Equal(t, b.Commit, decoded.Commit)
+
+	// The transactions are covered by TestProtoSchemaTransaction.
+	assertAllFieldsSeen(t, schema, seen, "bockvote.v1.Transaction")
+}
+
+func TestProtoSchemaCommit(t *testing.T) {
+	schema := loadSchema(t).Messages().ByName("Commit")
+	seen := map[protoreflect.FullName]bool{}
+
+	c := newTestCommit(t, randomBlock(t, 1, types.Hash{}), 2, crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey())
+
+	b := roundTripSchema(t, schema, marshalCommit(c), seen)
+	decoded, err := unmarshalCommit(b)
+	assert.Nil(t, err)
+	assert.Equal(t, c, decoded)
+
+	assertAllFieldsSeen(t, schema, seen)
+}
+
+// fullTxInners returns a value of every inner transaction type with all
+// fields set.
+func fullTxInners(t *testing.T) []any {
+	key := crypto.GeneratePrivateKey()
+	sig, err := key.Sign([]byte("inner"))
+	assert.Nil(t, err)
+
+	return []any{
+		CollectionTx{Fee: 1, MetaData: []byte("meta")},
+		MintTx{Fee: 1, NFT: types.Hash{1}, Collection: types.Hash{2}, MetaData: []byte("meta"), CollectionOwner: key.PublicKey(), Signature: *sig},
+		VoterRegistrationTx{VoterID: "v1", IPFSDocHash: "doc", VoterPublicKey: key.PublicKey(), Signature: *sig, Timestamp: 1},
+		CandidateRegistrationTx{CandidateID: "c1", ElectionID: "e1", IPFSProfileHash: "profile", CandidatePublicKey: key.PublicKey(), Signature: *sig, Timestamp: 1},
+		VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: key.PublicKey(), Signature: *sig, Timestamp: 1},
+		ElectionCreationTx{ElectionID: "e1", Title: "title", Description: "description", StartTime: -1, EndTime: 1, AdminPublicKey: key.PublicKey(), Signature: *sig, Timestamp: 1},
+		ValidatorSetChangeTx{Change: ValidatorChangeRemove, Validator: key.PublicKey(), EffectiveHeight: 4, Approvals: []ValidatorApproval{{Validator: key.PublicKey(), Signature: *sig}}},
+		ElectionBudgetTx{ElectionID: "e1", Amount: 1},
+		VoterApprovalTx{VoterID: "v1", Approve: true},
+		CandidateApprovalTx{ElectionID: "e1", CandidateID: "c1", Approve: true},
+	}
+}
+
+// roundTripSchema decodes the core encoding with the schema and returns the
+// encoding of the schema, which has to be identical. The fields that are set
+// are added to seen.
+func roundTripSchema(t *testing.T, md protoreflect.MessageDescriptor, b []byte, seen map[protoreflect.FullName]bool) []byte {
+	msg := dynamicpb.NewMessage(md)
+	assert.Nil(t, proto.Unmarshal(b, msg))
+	collectFields(t, msg, seen)
+
+	out, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, b, out, md.FullName())
+
+	return out
+}
+
+func collectFields(t *testing.T, msg protoreflect.Message, seen map[protoreflect.FullName]bool) {
+	assert.Empty(t, msg.GetUnknown(), "%s has fields that are not in the schema", msg.Descriptor().FullName())
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		seen[fd.FullName()] = true
+
+		if fd.Message() == nil {
+			return true
+		}
+		if fd.IsList() {
+			for i := 0; i < v.List().Len(); i++ {
+				collectFields(t, v.List().Get(i).Message(), seen)
+			}
+			return true
+		}
+		collectFields(t, v.Message(), seen)
+
+		return true
+	})
+}
+
+// assertAllFieldsSeen checks that every field of the message and of the
+// messages it contains was set, except for the skipped messages.
+func assertAllFieldsSeen(t *testing.T, md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool, skip ...protoreflect.FullName) {
+	visited := map[protoreflect.FullName]bool{}
+	for _, name := range skip {
+		visited[name] = true
+	}
+
+	var walk func(md protoreflect.MessageDescriptor)
+	walk = func(md protoreflect.MessageDescriptor) {
+		if visited[md.FullName()] {
+			return
+		}
+		visited[md.FullName()] = true
+
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			assert.True(t, seen[fd.FullName()], "%s is never written by core", fd.FullName())
+			if fd.Message() != nil {
+				walk(fd.Message())
+			}
+		}
+	}
+	walk(md)
+}
+
+// parseProtoSchema parses the subset of the proto3 language that
+// bockvote.proto uses: messages with nested enums, repeated fields, oneofs
+// and reserved numbers. Options and services are skipped.
+func parseProtoSchema(src string) (*descriptorpb.FileDescriptorProto, error) {
+	p := &protoParser{tokens: tokenizeProto(src)}
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("bockvote.proto"),
+		Syntax: proto.String("proto3"),
+	}
+
+	for !p.done() {
+		switch tok := p.next(); tok {
+		case "syntax", "option":
+			p.skipStatement()
+		case "package":
+			fd.Package = proto.String(p.next())
+			p.expect(";")
+		case "message":
+			fd.MessageType = append(fd.MessageType, p.parseMessage())
+		case "service":
+			p.next()
+			p.skipBlock()
+		default:
+			p.fail("unexpected %q", tok)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	resolveProtoTypes(fd)
+
+	return fd, nil
+}
+
+var protoScalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+}
+
+type protoParser struct {
+	tokens []string
+	pos    int
+	err    error
+}
+
+func tokenizeProto(src string) []string {
+	tokens := []string{}
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		tok := ""
+		flush := func() {
+			if tok != "" {
+				tokens = append(tokens, tok)
+				tok = ""
+			}
+		}
+		for _, r := range line {
+			switch {
+			case unicode.IsSpace(r):
+				flush()
+			case strings.ContainsRune("{}=;()", r):
+				flush()
+				tokens = append(tokens, string(r))
+			default:
+				tok += string(r)
+			}
+		}
+		flush()
+	}
+
+	return tokens
+}
+
+func (p *protoParser) done() bool {
+	return p.err != nil || p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) next() string {
+	if p.done() {
+		p.fail("unexpected end of schema")
+		return ""
+	}
+	p.pos++
+
+	return p.tokens[p.pos-1]
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(tok string) {
+	if got := p.next(); got != tok && p.err == nil {
+		p.fail("expected %q, got %q", tok, got)
+	}
+}
+
+func (p *protoParser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("token %d: %s", p.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *protoParser) skipStatement() {
+	for !p.done() && p.next() != ";" {
+	}
+}
+
+func (p *protoParser) skipBlock() {
+	p.expect("{")
+	for depth := 1; depth > 0 && !p.done(); {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+func (p *protoParser) parseMessage() *descriptorpb.DescriptorProto {
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(p.next())}
+	p.expect("{")
+
+	for !p.done() {
+		switch tok := p.peek(); tok {
+		case "}":
+			p.next()
+			return msg
+		case "message":
+			p.next()
+			msg.NestedType = append(msg.NestedType, p.parseMessage())
+		case "enum":
+			p.next()
+			msg.EnumType = append(msg.EnumType, p.parseEnum())
+		case "reserved", "option":
+			p.skipStatement()
+		case "oneof":
+			p.next()
+			index := int32(len(msg.OneofDecl))
+			msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(p.next())})
+			p.expect("{")
+			for !p.done() && p.peek() != "}" {
+				field := p.parseField()
+				field.OneofIndex = proto.Int32(index)
+				msg.Field = append(msg.Field, field)
+			}
+			p.expect("}")
+		default:
+			msg.Field = append(msg.Field, p.parseField())
+		}
+	}
+
+	return msg
+}
+
+func (p *protoParser) parseEnum() *descriptorpb.EnumDescriptorProto {
+	enum := &descriptorpb.EnumDescriptorProto{Name: proto.String(p.next())}
+	p.expect("{")
+
+	for !p.done() && p.peek() != "}" {
+		name := p.next()
+		p.expect("=")
+		n, err := strconv.Atoi(p.next())
+		if err != nil {
+			p.fail("invalid enum value: %v", err)
+		}
+		p.expect(";")
+		enum.Value = append(enum.Value, &descriptorpb.EnumValueDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(int32(n)),
+		})
+	}
+	p.expect("}")
+
+	return enum
+}
+
+func (p *protoParser) parseField() *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if p.peek() == "repeated" {
+		p.next()
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+
+	typ, name := p.next(), p.next()
+	p.expect("=")
+	n, err := strconv.Atoi(p.next())
+	if err != nil {
+		p.fail("invalid field number: %v", err)
+	}
+	p.expect(";")
+
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(protoJSONName(name)),
+		Number:   proto.Int32(int32(n)),
+		Label:    label.Enum(),
+	}
+	if scalar, ok := protoScalarTypes[typ]; ok {
+		field.Type = scalar.Enum()
+	} else {
+		// Resolved once all messages and enums are known.
+		field.TypeName = proto.String(typ)
+	}
+
+	return field
+}
+
+func protoJSONName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// resolveProtoTypes replaces the type names of the fields with their fully
+// qualified names, names are looked up from the innermost scope outwards.
+func resolveProtoTypes(fd *descriptorpb.FileDescriptorProto) {
+	kinds := map[string]descriptorpb.FieldDescriptorProto_Type{}
+
+	var collect func(scope string, msgs []*descriptorpb.DescriptorProto)
+	collect = func(scope string, msgs []*descriptorpb.DescriptorProto) {
+		for _, m := range msgs {
+			name := scope + "." + m.GetName()
+			kinds[name] = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+			for _, e := range m.EnumType {
+				kinds[name+"."+e.GetName()] = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+			}
+			collect(name, m.NestedType)
+		}
+	}
+	root := "." + fd.GetPackage()
+	collect(root, fd.MessageType)
+
+	var resolve func(scope string, msgs []*descriptorpb.DescriptorProto)
+	resolve = func(scope string, msgs []*descriptorpb.DescriptorProto) {
+		for _, m := range msgs {
+			name := scope + "." + m.GetName()
+			for _, f := range m.Field {
+				if f.TypeName == nil {
+					continue
+				}
+				for s := name; ; s = s[:strings.LastIndex(s, ".")] {
+					if kind, ok := kinds[s+"."+f.GetTypeName()]; ok {
+						f.TypeName = proto.String(s + "." + f.GetTypeName())
+						f.Type = kind.Enum()
+						break
+					}
+					if s == "" {
+						break
+					}
+				}
+			}
+			resolve(name, m.NestedType)
+		}
+	}
+	resolve(root, fd.MessageType)
+}
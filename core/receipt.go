@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/anthdm/projectx/types"
+)
+
+type ReceiptStatus byte
+
+// The zero value is neither success nor failure, so an unset receipt is
+// not mistaken for a failed transaction.
+const (
+	ReceiptStatusUnknown ReceiptStatus = iota
+	ReceiptStatusSuccess
+	ReceiptStatusFailed
+)
+
+func (s ReceiptStatus) String() string {
+	switch s {
+	case ReceiptStatusSuccess:
+		return "success"
+	case ReceiptStatusFailed:
+		return "failed"
+	case ReceiptStatusUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("unknown (%d)", byte(s))
+	}
+}
+
+const (
+	// TxBaseGas is the gas used by every transaction.
+	TxBaseGas uint64 = 1000
+	// TxDataGas is the gas used per byte of contract code.
+	TxDataGas uint64 = 16
+)
+
+// Receipt is the result of executing a transaction that is included in a
+// block. Failed transactions stay in the block, but none of their changes
+// are applied.
+type Receipt struct {
+	TxHash      types.Hash
+	BlockHeight uint32
+	Status      ReceiptStatus
+	Error       string
+	GasUsed     uint64
+	// Fee is the fee the sender paid to the validator.
+	Fee uint64
+	// Events are the voting events that the transaction emitted.
+	Events []VotingEvent
+}
+
+func intrinsicGas(tx *Transaction) uint64 {
+	return TxBaseGas + uint64(len(tx.Data))*TxDataGas
+}
+
+// GetReceipt returns the receipt of a transaction on the canonical chain.
+func (bc *Blockchain) GetReceipt(hash types.Hash) (*Receipt, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	receipt, ok := bc.receipts[hash]
+	if !ok {
+		return nil, fmt.Errorf("could not find receipt of tx with hash (%s)", hash)
+	}
+
+	return receipt, nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailedTransactionKeepsBlockIntact(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	// Voting in an election that does not exist fails.
+	privKey := crypto.GeneratePrivateKey()
+	vote := NewTransaction(nil)
+	vote.TxInner = VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: privKey.PublicKey()}
+	vote.ChainID = testChainID
+	assert.Nil(t, vote.Sign(privKey))
+
+	election := newElectionTx(t, "e2")
+	b := newChildBlock(t, bc.headers[0], vote, election)
+	assert.Nil(t, bc.AddBlock(b))
+
+	// The block is not modified by the execution.
+	assert.Nil(t, b.Verify())
+	assert.Equal(t, vote, b.Transactions[0])
+
+	receipt, err := bc.GetReceipt(vote.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.NotEmpty(t, receipt.Error)
+	assert.Equal(t, intrinsicGas(vote), receipt.GasUsed)
+
+	receipt, err = bc.GetReceipt(election.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+	assert.Empty(t, receipt.Error)
+}
+
+func TestBlockWithInvalidTransactionIsRejected(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	tx := newElectionTx(t, "e1")
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], tx)))
+
+	// Including a transaction that is already on the chain rejects the
+	// block and reverts the transactions before it.
+	other := newElectionTx(t, "e2")
+	b := newChildBlock(t, bc.headers[1], other, tx)
+	assert.NotNil(t, bc.AddBlock(b))
+	assert.Equal(t, uint32(1), bc.Height())
+	assert.False(t, bc.HasBlockHash(b.Hash(BlockHasher{})))
+
+	_, err := bc.GetVotingState().GetElection("e2")
+	assert.NotNil(t, err)
+	_, err = bc.GetReceipt(other.Hash(TxHasher{}))
+	assert.NotNil(t, err)
+
+	// The same transaction twice in one block.
+	b = newChildBlock(t, bc.headers[1], other, other)
+	assert.NotNil(t, bc.AddBlock(b))
+	assert.Equal(t, uint32(1), bc.Height())
+}
+
+func TestValidTransactions(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	tx := newElectionTx(t, "e1")
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], tx)))
+
+	other := newElectionTx(t, "e1")
+	valid := bc.ValidTransactions([]*Transaction{tx, other, other}, time.Now().UnixNano())
+	assert.Equal(t, []*Transaction{other}, valid)
+
+	// Selecting transactions does not change the state.
+	_, err := bc.GetReceipt(other.Hash(TxHasher{}))
+	assert.NotNil(t, err)
+}
+
+func TestReceiptStatusZeroValueIsUnknown(t *testing.T) {
+	var receipt Receipt
+	assert.Equal(t, ReceiptStatusUnknown, receipt.Status)
+	assert.NotEqual(t, ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, "unknown", receipt.Status.String())
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+// Signing domains keep the signatures of different kinds of messages apart,
+// a signature of one kind is never valid for another kind.
+const (
+	SigningDomainTx          = "projectx/tx"
+	SigningDomainSponsorship = "projectx/sponsorship"
+	SigningDomainBlock       = "projectx/block"
+	SigningDomainVote        = "projectx/vote"
+	// Approvals of validator set changes.
+	SigningDomainValidatorSetChange = "projectx/validator-set-change"
+)
+
+// SigningDigest returns the digest that is signed for the hash of a message
+// of the given domain on the given chain:
+//
+//	sha256(len(domain) (4) | domain | len(chain ID) (4) | chain ID | hash (32))
+//
+// The chain ID makes signatures of a test network invalid on any other
+// network.
+func SigningDigest(domain, chainID string, hash types.Hash) []byte {
+	b := make([]byte, 0, 8+len(domain)+len(chainID)+32)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(domain)))
+	b = append(b, domain...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(chainID)))
+	b = append(b, chainID...)
+	b = append(b, hash[:]...)
+
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// verifySignature checks the signature of the digest by the given key.
+// Signatures and keys decoded from the network are checked for their shape
+// first, crypto.Signature.Verify panics on keys that are not on the curve
+// and on signatures without R or S.
+func verifySignature(sig *crypto.Signature, key crypto.PublicKey, digest []byte) bool {
+	if sig == nil || sig.R == nil || sig.S == nil {
+		return false
+	}
+	if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), key); x == nil {
+		return false
+	}
+
+	return sig.Verify(key, digest)
+}
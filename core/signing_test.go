@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningDigestSeparatesDomainsAndChains(t *testing.T) {
+	hash := types.Hash{1, 2, 3}
+
+	digest := SigningDigest(SigningDomainTx, "test", hash)
+	assert.Equal(t, digest, SigningDigest(SigningDomainTx, "test", hash))
+	assert.NotEqual(t, digest, SigningDigest(SigningDomainBlock, "test", hash))
+	assert.NotEqual(t, digest, SigningDigest(SigningDomainTx, "other", hash))
+	assert.NotEqual(t, digest, hash.ToSlice())
+
+	// The lengths keep the domain and the chain ID from running into each
+	// other.
+	assert.NotEqual(t,
+		SigningDigest("projectx/tx", "test", hash),
+		SigningDigest("projectx/txt", "est", hash),
+	)
+}
+
+func TestTransactionSignatureIsBoundToChain(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := NewTransaction(nil)
+	tx.To = crypto.GeneratePrivateKey().PublicKey()
+	tx.Value = 10
+	tx.ChainID = "testnet"
+	assert.Nil(t, tx.Sign(privKey))
+	assert.Nil(t, tx.Verify())
+
+	replayed := *tx
+	replayed.ChainID = "mainnet"
+	replayed.hash = types.Hash{}
+	assert.NotNil(t, replayed.Verify())
+
+	// A signature over the bare hash, as produced before chain IDs, does not
+	// verify either.
+	sig, err := privKey.Sign(tx.Hash(TxHasher{}).ToSlice())
+	assert.Nil(t, err)
+	legacy := *tx
+	legacy.Signature = sig
+	assert.NotNil(t, legacy.Verify())
+}
+
+func TestTransactionSignatureCoversInner(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := NewTransaction(nil)
+	tx.TxInner = VoteTx{ElectionID: "election", CandidateID: "alice"}
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(privKey))
+	assert.Nil(t, tx.Verify())
+
+	tx.TxInner = VoteTx{ElectionID: "election", CandidateID: "bob"}
+	tx.hash = types.Hash{}
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestBlockchainRejectsOtherChain(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	privKey := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(privKey.PublicKey().Address()).Balance = 100
+
+	tx := NewTransaction(nil)
+	tx.To = crypto.GeneratePrivateKey().PublicKey()
+	tx.Value = 10
+	tx.ChainID = "other"
+	assert.Nil(t, tx.Sign(privKey))
+	assert.ErrorIs(t, bc.CheckTransaction(tx, nil), ErrTxRejected)
+
+	b := newChildBlock(t, bc.headers[0], tx)
+	assert.NotNil(t, bc.AddBlock(b))
+	assert.Equal(t, uint32(0), bc.Height())
+
+	b, err := NewBlockFromPrevHeader(bc.headers[0], nil)
+	assert.Nil(t, err)
+	b.ChainID = "other"
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	assert.NotNil(t, bc.AddBlock(b))
+	assert.Equal(t, uint32(0), bc.Height())
+}
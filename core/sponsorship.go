@@ -0,0 +1,212 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+// Sponsorship lets the admin or a paymaster of an election pay the fee of a
+// vote or voter registration from the budget of the election, so voters do
+// not need to hold tokens. The sponsor co-signs the transaction.
+type Sponsorship struct {
+	ElectionID string
+	Sponsor    crypto.PublicKey
+	Signature  *crypto.Signature
+}
+
+// ElectionBudgetTx moves tokens of the sender into the sponsorship budget
+// of an election. The sender becomes a paymaster of the election.
+type ElectionBudgetTx struct {
+	ElectionID string
+	Amount     uint64
+}
+
+// SponsorBudget is what is left of the budget of an election to pay the
+// fees of sponsored transactions.
+type SponsorBudget struct {
+	ElectionID string
+	Balance    uint64
+	// Spent is the sum of the fees that were paid from the budget.
+	Spent      uint64
+	Paymasters []crypto.PublicKey
+}
+
+// SetSponsor lets the sponsor pay the fee of the transaction from the
+// budget of the election. The sponsorship is part of the signed hash, so it
+// has to be set before the sender signs. The sponsor signs afterwards with
+// SignSponsorship.
+func (tx *Transaction) SetSponsor(electionID string, sponsor crypto.PublicKey) {
+	tx.Sponsorship = &Sponsorship{
+		ElectionID: electionID,
+		Sponsor:    sponsor,
+	}
+	tx.hash = types.Hash{}
+}
+
+// SignSponsorship co-signs the signed transaction as its sponsor.
+func (tx *Transaction) SignSponsorship(privKey crypto.PrivateKey) error {
+	if tx.Sponsorship == nil || !bytes.Equal(tx.Sponsorship.Sponsor, privKey.PublicKey()) {
+		return fmt.Errorf("transaction is not sponsored by (%s)", privKey.PublicKey())
+	}
+
+	hash := tx.Hash(TxHasher{})
+	sig, err := privKey.Sign(SigningDigest(SigningDomainSponsorship, tx.ChainID, hash))
+	if err != nil {
+		return err
+	}
+
+	tx.Sponsorship.Signature = sig
+
+	return nil
+}
+
+func (s *Sponsorship) verify(chainID string, hash types.Hash) error {
+	if s.Signature == nil {
+		return fmt.Errorf("sponsorship has no signature")
+	}
+
+	if !verifySignature(s.Signature, s.Sponsor, SigningDigest(SigningDomainSponsorship, chainID, hash)) {
+		return fmt.Errorf("invalid sponsorship signature")
+	}
+
+	return nil
+}
+
+// GetSponsorBudget returns the sponsorship budget of the election.
+func (bc *Blockchain) GetSponsorBudget(electionID string) (*SponsorBudget, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	budget, ok := bc.budgets[electionID]
+	if !ok {
+		return nil, fmt.Errorf("election (%s) has no sponsorship budget", electionID)
+	}
+
+	b := *budget
+	b.Paymasters = append([]crypto.PublicKey{}, budget.Paymasters...)
+
+	return &b, nil
+}
+
+// checkSponsorship checks that the sponsor of the transaction is allowed to
+// pay its fee from the budget of the election and that the budget is big
+// enough.
+func (bc *Blockchain) checkSponsorship(tx *Transaction) error {
+	s := tx.Sponsorship
+
+	switch t := tx.TxInner.(type) {
+	case VoteTx:
+		if t.ElectionID != s.ElectionID {
+			return fmt.Errorf("vote in election (%s) cannot be sponsored by election (%s)", t.ElectionID, s.ElectionID)
+		}
+	case VoterRegistrationTx:
+	default:
+		return fmt.Errorf("only votes and voter registrations can be sponsored")
+	}
+
+	budget, err := bc.GetSponsorBudget(s.ElectionID)
+	if err != nil {
+		return err
+	}
+
+	if !bc.isPaymaster(budget, s.Sponsor) {
+		return fmt.Errorf("(%s) is no paymaster of election (%s)", s.Sponsor, s.ElectionID)
+	}
+
+	if budget.Balance < tx.Fee {
+		return fmt.Errorf("budget of election (%s) cannot pay the fee (%d)", s.ElectionID, tx.Fee)
+	}
+
+	return nil
+}
+
+// isPaymaster reports whether the key is the admin of the election or one
+// of its paymasters.
+func (bc *Blockchain) isPaymaster(budget *SponsorBudget, key crypto.PublicKey) bool {
+	for _, p := range budget.Paymasters {
+		if bytes.Equal(p, key) {
+			return true
+		}
+	}
+
+	election, err := bc.votingState.GetElection(budget.ElectionID)
+	return err == nil && bytes.Equal(election.AdminKey, key)
+}
+
+// handleElectionBudget moves the amount from the sender into the budget of
+// the election.
+func (bc *Blockchain) handleElectionBudget(from crypto.PublicKey, tx *ElectionBudgetTx) error {
+	if _, err := bc.votingState.GetElection(tx.ElectionID); err != nil {
+		return err
+	}
+
+	if tx.Amount == 0 {
+		return fmt.Errorf("budget amount has to be positive")
+	}
+
+	if err := bc.accountState.subBalance(from.Address(), tx.Amount); err != nil {
+		return err
+	}
+
+	bc.updateBudget(tx.ElectionID, func(b *SponsorBudget) {
+		b.Balance += tx.Amount
+		for _, p := range b.Paymasters {
+			if bytes.Equal(p, from) {
+				return
+			}
+		}
+		b.Paymasters = append(b.Paymasters, from)
+	})
+
+	return nil
+}
+
+// spendBudget pays the fee of the sponsored transaction from the budget of
+// the election.
+func (bc *Blockchain) spendBudget(electionID string, fee uint64) error {
+	budget, err := bc.GetSponsorBudget(electionID)
+	if err != nil {
+		return err
+	}
+
+	if budget.Balance < fee {
+		return fmt.Errorf("budget of election (%s) cannot pay the fee (%d)", electionID, fee)
+	}
+
+	bc.updateBudget(electionID, func(b *SponsorBudget) {
+		b.Balance -= fee
+		b.Spent += fee
+	})
+
+	return nil
+}
+
+// updateBudget applies the change to the budget of the election, the budget
+// is created if it does not exist. The change is journaled.
+func (bc *Blockchain) updateBudget(electionID string, update func(*SponsorBudget)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	prev, ok := bc.budgets[electionID]
+	budget := &SponsorBudget{ElectionID: electionID}
+	if ok {
+		*budget = *prev
+		budget.Paymasters = append([]crypto.PublicKey{}, prev.Paymasters...)
+	}
+	update(budget)
+	bc.budgets[electionID] = budget
+
+	bc.journal.record(func() {
+		bc.lock.Lock()
+		defer bc.lock.Unlock()
+
+		if !ok {
+			delete(bc.budgets, electionID)
+			return
+		}
+		bc.budgets[electionID] = prev
+	})
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSponsoredTx(t *testing.T, sender, sponsor crypto.PrivateKey, electionID string, fee uint64, inner any) *Transaction {
+	tx := NewTransaction(nil)
+	tx.TxInner = inner
+	tx.Fee = fee
+	tx.SetSponsor(electionID, sponsor.PublicKey())
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(sender))
+	assert.Nil(t, tx.SignSponsorship(sponsor))
+
+	return tx
+}
+
+func TestSponsoredTransactions(t *testing.T) {
+	bc := newBlockchainWithFees(t, FeeConfig{MinGasPrice: 1})
+
+	admin := crypto.GeneratePrivateKey()
+	paymaster := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(admin.PublicKey().Address()).Balance = 10_000
+	bc.accountState.CreateAccount(paymaster.PublicKey().Address()).Balance = 10_000
+
+	election := newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: admin.PublicKey()})
+	election.Fee = TxBaseGas
+	election.ChainID = testChainID
+	assert.Nil(t, election.Sign(admin))
+	funding := newVotingTx(t, paymaster, 0, ElectionBudgetTx{ElectionID: "e1", Amount: 2500})
+	funding.Fee = TxBaseGas
+	funding.ChainID = testChainID
+	assert.Nil(t, funding.Sign(paymaster))
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0], election, funding)))
+
+	budget, err := bc.GetSponsorBudget("e1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2500), budget.Balance)
+	assert.Equal(t, []crypto.PublicKey{paymaster.PublicKey()}, budget.Paymasters)
+
+	// The voter has no tokens, the admin and the paymaster pay for the
+	// registration and the vote.
+	registration := newSponsoredTx(t, voter, admin, "e1", TxBaseGas, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()})
+	assert.Nil(t, bc.CheckTransaction(registration, nil))
+	vote := newSponsoredTx(t, voter, paymaster, "e1", TxBaseGas, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+	vote.Nonce = 1
+	vote.ChainID = testChainID
+	assert.Nil(t, vote.Sign(voter))
+	assert.Nil(t, vote.SignSponsorship(paymaster))
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], registration, vote)))
+
+	receipt, err := bc.GetReceipt(vote.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, TxBaseGas, receipt.Fee)
+
+	budget, err = bc.GetSponsorBudget("e1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(500), budget.Balance)
+	assert.Equal(t, 2*TxBaseGas, budget.Spent)
+	_, err = bc.accountState.GetAccount(voter.PublicKey().Address())
+	assert.Nil(t, err)
+	balance, _ := bc.accountState.GetBalance(voter.PublicKey().Address())
+	assert.Equal(t, uint64(0), balance)
+
+	// The budget cannot pay for another vote.
+	other := crypto.GeneratePrivateKey()
+	vote = newSponsoredTx(t, other, admin, "e1", TxBaseGas, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: other.PublicKey()})
+	assert.ErrorIs(t, bc.CheckTransaction(vote, nil), ErrTxRejected)
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[2], vote)))
+}
+
+func TestInvalidSponsorship(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+	bc.accountState.CreateAccount(admin.PublicKey().Address()).Balance = 100
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[0],
+		newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "e1", AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, admin, 1, ElectionBudgetTx{ElectionID: "e1", Amount: 100}),
+	)))
+
+	// Only the admin or a paymaster can sponsor.
+	stranger := crypto.GeneratePrivateKey()
+	tx := newSponsoredTx(t, voter, stranger, "e1", 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()})
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], tx)))
+
+	// Votes can only be sponsored by the election they are cast in.
+	tx = newSponsoredTx(t, voter, admin, "e1", 0, VoteTx{ElectionID: "e2", VoterPublicKey: voter.PublicKey()})
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], tx)))
+
+	// Other transactions cannot be sponsored.
+	tx = newSponsoredTx(t, voter, admin, "e1", 0, ElectionCreationTx{ElectionID: "e2", AdminPublicKey: voter.PublicKey()})
+	assert.NotNil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], tx)))
+
+	// The sponsor has to sign the transaction.
+	tx = newSponsoredTx(t, voter, admin, "e1", 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()})
+	tx.Sponsorship.Signature = nil
+	assert.NotNil(t, tx.Verify())
+	assert.NotNil(t, tx.SignSponsorship(stranger))
+
+	// The sponsorship is part of the signed hash.
+	tx = newSponsoredTx(t, voter, admin, "e1", 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()})
+	tampered := *tx
+	tampered.Sponsorship = &Sponsorship{ElectionID: "e2", Sponsor: admin.PublicKey(), Signature: tx.Sponsorship.Signature}
+	tampered.hash = types.Hash{}
+	assert.NotNil(t, tampered.Verify())
+	assert.Nil(t, bc.AddBlock(newChildBlock(t, bc.headers[1], tx)))
+}
+
+func TestMalformedSponsorship(t *testing.T) {
+	sender := crypto.GeneratePrivateKey()
+	sponsor := crypto.GeneratePrivateKey()
+
+	// A sponsor key that is not a point on the curve.
+	tx := newSponsoredTx(t, sender, sponsor, "e1", 0, VoteTx{ElectionID: "e1"})
+	tx.Sponsorship.Sponsor = bytes.Repeat([]byte{0xff}, 33)
+	tx.hash = types.Hash{}
+	assert.Nil(t, tx.Sign(sender))
+	assert.NotNil(t, tx.Verify())
+
+	// A sponsor signature without R or S, as decoded from the network.
+	tx = newSponsoredTx(t, sender, sponsor, "e1", 0, VoteTx{ElectionID: "e1"})
+	tx.Sponsorship.Signature = &crypto.Signature{}
+	assert.NotNil(t, tx.Verify())
+
+	// The same for the sender.
+	tx = newSponsoredTx(t, sender, sponsor, "e1", 0, VoteTx{ElectionID: "e1"})
+	tx.Signature = &crypto.Signature{R: tx.Signature.R}
+	assert.NotNil(t, tx.Verify())
+
+	tx.From = []byte{0x02}
+	tx.hash = types.Hash{}
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestProtoSponsoredTxEncodeDecode(t *testing.T) {
+	sender := crypto.GeneratePrivateKey()
+	sponsor := crypto.GeneratePrivateKey()
+	tx := newSponsoredTx(t, sender, sponsor, "e1", 10, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: sender.PublicKey()})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, tx.Encode(NewProtoTxEncoder(buf)))
+
+	decoded := new(Transaction)
+	assert.Nil(t, decoded.Decode(NewProtoTxDecoder(buf)))
+	assert.Nil(t, decoded.Verify())
+	assert.Equal(t, tx, decoded)
+
+	funding := NewTransaction(nil)
+	funding.TxInner = ElectionBudgetTx{ElectionID: "e1", Amount: 100}
+	funding.ChainID = testChainID
+	assert.Nil(t, funding.Sign(sender))
+
+	buf.Reset()
+	assert.Nil(t, funding.Encode(NewProtoTxEncoder(buf)))
+	decoded = new(Transaction)
+	assert.Nil(t, decoded.Decode(NewProtoTxDecoder(buf)))
+	assert.Nil(t, decoded.Verify())
+	assert.Equal(t, funding, decoded)
+}
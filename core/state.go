@@ -5,7 +5,8 @@ import (
 )
 
 type State struct {
-	data map[string][]byte
+	data    map[string][]byte
+	journal *Journal
 }
 
 func NewState() *State {
@@ -15,12 +16,14 @@ func NewState() *State {
 }
 
 func (s *State) Put(k, v []byte) error {
+	s.journalKey(k)
 	s.data[string(k)] = v
 
 	return nil
 }
 
 func (s *State) Delete(k []byte) error {
+	s.journalKey(k)
 	delete(s.data, string(k))
 
 	return nil
@@ -36,3 +39,22 @@ func (s *State) Get(k []byte) ([]byte, error) {
 
 	return value, nil
 }
+
+// journalKey records the current value of the key, so a change to it can
+// be reverted.
+func (s *State) journalKey(k []byte) {
+	if s.journal == nil {
+		return
+	}
+
+	key := string(k)
+	prev, ok := s.data[key]
+
+	s.journal.record(func() {
+		if !ok {
+			delete(s.data, key)
+			return
+		}
+		s.data[key] = prev
+	})
+}
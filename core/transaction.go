@@ -3,7 +3,6 @@ package core
 import (
 	"encoding/gob"
 	"fmt"
-	"math/rand"
 
 	"github.com/anthdm/projectx/crypto"
 	"github.com/anthdm/projectx/types"
@@ -18,6 +17,10 @@ const (
 	TxTypeCandidateRegistration               // 0x03
 	TxTypeVote                                // 0x04
 	TxTypeElectionCreation                    // 0x05
+	TxTypeValidatorSetChange                  // 0x06
+	TxTypeElectionBudget                      // 0x07
+	TxTypeVoterApproval                       // 0x08
+	TxTypeCandidateApproval                   // 0x09
 )
 
 type CollectionTx struct {
@@ -74,6 +77,21 @@ type ElectionCreationTx struct {
 	Timestamp      int64
 }
 
+// VoterApprovalTx approves or rejects a voter registration, it has to be
+// sent by a registrar of the genesis.
+type VoterApprovalTx struct {
+	VoterID string
+	Approve bool
+}
+
+// CandidateApprovalTx approves or rejects a candidate registration, it has
+// to be sent by the admin of the election.
+type CandidateApprovalTx struct {
+	ElectionID  string
+	CandidateID string
+	Approve     bool
+}
+
 type Transaction struct {
 	// Only used for native NFT logic
 	TxInner any
@@ -83,7 +101,16 @@ type Transaction struct {
 	Value     uint64
 	From      crypto.PublicKey
 	Signature *crypto.Signature
-	Nonce     int64
+	// Nonce is the number of transactions the sender has sent before this
+	// one, every nonce can only be used once.
+	Nonce uint64
+	// Fee is paid by the sender to the validator of the block that includes
+	// the transaction, also if the transaction fails.
+	Fee uint64
+	// If set the fee is paid from the sponsorship budget of an election.
+	Sponsorship *Sponsorship
+	// ChainID is the network the transaction is valid on.
+	ChainID string
 
 	// cached version of the tx data hash
 	hash types.Hash
@@ -91,8 +118,7 @@ type Transaction struct {
 
 func NewTransaction(data []byte) *Transaction {
 	return &Transaction{
-		Data:  data,
-		Nonce: rand.Int63n(1000000000000000),
+		Data: data,
 	}
 }
 
@@ -104,13 +130,21 @@ func (tx *Transaction) Hash(hasher Hasher[*Transaction]) types.Hash {
 }
 
 func (tx *Transaction) Sign(privKey crypto.PrivateKey) error {
+	// The sender is part of the signed hash, so it has to be set before
+	// hashing. Otherwise the signature only verifies against the cached hash.
+	tx.From = privKey.PublicKey()
+	tx.hash = types.Hash{}
+
+	if _, err := txHashBytes(tx); err != nil {
+		return err
+	}
+
 	hash := tx.Hash(TxHasher{})
-	sig, err := privKey.Sign(hash.ToSlice())
+	sig, err := privKey.Sign(SigningDigest(SigningDomainTx, tx.ChainID, hash))
 	if err != nil {
 		return err
 	}
 
-	tx.From = privKey.PublicKey()
 	tx.Signature = sig
 
 	return nil
@@ -121,11 +155,19 @@ func (tx *Transaction) Verify() error {
 		return fmt.Errorf("transaction has no signature")
 	}
 
+	if _, err := txHashBytes(tx); err != nil {
+		return err
+	}
+
 	hash := tx.Hash(TxHasher{})
-	if !tx.Signature.Verify(tx.From, hash.ToSlice()) {
+	if !verifySignature(tx.Signature, tx.From, SigningDigest(SigningDomainTx, tx.ChainID, hash)) {
 		return fmt.Errorf("invalid transaction signature")
 	}
 
+	if tx.Sponsorship != nil {
+		return tx.Sponsorship.verify(tx.ChainID, hash)
+	}
+
 	return nil
 }
 
@@ -144,4 +186,8 @@ func init() {
 	gob.Register(CandidateRegistrationTx{})
 	gob.Register(VoteTx{})
 	gob.Register(ElectionCreationTx{})
+	gob.Register(ValidatorSetChangeTx{})
+	gob.Register(ElectionBudgetTx{})
+	gob.Register(VoterApprovalTx{})
+	gob.Register(CandidateApprovalTx{})
 }
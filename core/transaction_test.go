@@ -21,6 +21,7 @@ func TestVerifyTransactionWithTamper(t *testing.T) {
 	tx.To = toPrivKey.PublicKey()
 	tx.Value = 666
 
+	tx.ChainID = testChainID
 	assert.Nil(t, tx.Sign(fromPrivKey))
 	tx.hash = types.Hash{}
 
@@ -39,6 +40,7 @@ func TestNFTTransaction(t *testing.T) {
 	tx := &Transaction{
 		TxInner: collectionTx,
 	}
+	tx.ChainID = testChainID
 	tx.Sign(privKey)
 	tx.hash = types.Hash{}
 
@@ -58,6 +60,7 @@ func TestNativeTransferTransaction(t *testing.T) {
 		Value: 666,
 	}
 
+	tx.ChainID = testChainID
 	assert.Nil(t, tx.Sign(fromPrivKey))
 }
 
@@ -67,6 +70,7 @@ func TestSignTransaction(t *testing.T) {
 		Data: []byte("foo"),
 	}
 
+	tx.ChainID = testChainID
 	assert.Nil(t, tx.Sign(privKey))
 	assert.NotNil(t, tx.Signature)
 }
@@ -77,6 +81,7 @@ func TestVerifyTransaction(t *testing.T) {
 		Data: []byte("foo"),
 	}
 
+	tx.ChainID = testChainID
 	assert.Nil(t, tx.Sign(privKey))
 	assert.Nil(t, tx.Verify())
 
@@ -102,7 +107,38 @@ func randomTxWithSignature(t *testing.T) *Transaction {
 	tx := Transaction{
 		Data: []byte("foo"),
 	}
+	tx.ChainID = testChainID
 	assert.Nil(t, tx.Sign(privKey))
 
 	return &tx
 }
+
+func TestTxHashSeparatesFields(t *testing.T) {
+	// Moving bytes from one variable length field to the next changes the
+	// hash.
+	a := NewTransaction([]byte("ab"))
+	a.ChainID = "c"
+	b := NewTransaction([]byte("a"))
+	b.ChainID = "bc"
+	assert.NotEqual(t, a.Hash(TxHasher{}), b.Hash(TxHasher{}))
+
+	// An empty sponsorship is not the same as none.
+	sponsored := NewTransaction(nil)
+	sponsored.Sponsorship = &Sponsorship{}
+	assert.NotEqual(t, NewTransaction(nil).Hash(TxHasher{}), sponsored.Hash(TxHasher{}))
+
+	sponsored = NewTransaction(nil)
+	sponsored.SetSponsor("e1", []byte("sponsor"))
+	other := NewTransaction(nil)
+	other.SetSponsor("e1s", []byte("ponsor"))
+	assert.NotEqual(t, sponsored.Hash(TxHasher{}), other.Hash(TxHasher{}))
+}
+
+func TestSignTransactionUnsupportedInner(t *testing.T) {
+	tx := NewTransaction(nil)
+	tx.TxInner = struct{}{}
+	assert.NotNil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+	tx.Signature = &crypto.Signature{}
+	assert.NotNil(t, tx.Verify())
+}
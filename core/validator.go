@@ -5,7 +5,12 @@ import (
 	"fmt"
 )
 
-var ErrBlockKnown = errors.New("block already known")
+var (
+	ErrBlockKnown = errors.New("block already known")
+	// ErrUnknownParent is returned for blocks that arrive before their
+	// parent.
+	ErrUnknownParent = errors.New("unknown parent")
+)
 
 type Validator interface {
 	ValidateBlock(*Block) error
@@ -21,29 +26,85 @@ func NewBlockValidator(bc *Blockchain) *BlockValidator {
 	}
 }
 
+// ValidateBlock validates a block that is added to the chain. If its branch
+// has a validator set the block needs a valid commit certificate.
 func (v *BlockValidator) ValidateBlock(b *Block) error {
-	if v.bc.HasBlock(b.Height) {
-		// return fmt.Errorf("chain already contains block (%d) with hash (%s)", b.Height, b.Hash(BlockHasher{}))
-		return ErrBlockKnown
+	if err := v.validateHeader(b); err != nil {
+		return err
 	}
 
-	if b.Height != v.bc.Height()+1 {
-		return fmt.Errorf("block (%s) with height (%d) is too high => current height (%d)", b.Hash(BlockHasher{}), b.Height, v.bc.Height())
+	// The proposer and the commit are checked before the signature of the
+	// block, keys that are not in the validator set are never verified.
+	vs := v.bc.validatorSetOnBranch(b.PrevBlockHash, b.Height)
+	if err := checkCommit(b, vs); err != nil {
+		return err
 	}
 
-	prevHeader, err := v.bc.GetHeader(b.Height - 1)
-	if err != nil {
+	return b.Verify()
+}
+
+// ValidateProposal validates a block that is proposed in the given
+// consensus round and has no commit yet.
+func (v *BlockValidator) ValidateProposal(b *Block, round uint32) error {
+	if err := v.validateHeader(b); err != nil {
 		return err
 	}
 
-	hash := BlockHasher{}.Hash(prevHeader)
-	if hash != b.PrevBlockHash {
-		return fmt.Errorf("the hash of the previous block (%s) is invalid", b.PrevBlockHash)
+	vs := v.bc.validatorSetOnBranch(b.PrevBlockHash, b.Height)
+	if err := vs.ValidateProposer(b, round); err != nil {
+		return err
 	}
 
-	if err := b.Verify(); err != nil {
+	return b.Verify()
+}
+
+// checkCommit checks the proposer and the commit certificate of the block
+// against the validator set of its height. Without validators there is
+// nothing to check.
+func checkCommit(b *Block, vs *ValidatorSet) error {
+	if vs.Len() == 0 {
+		return nil
+	}
+
+	if b.Commit == nil {
+		return ErrMissingCommit
+	}
+	if err := vs.ValidateProposer(b, b.Commit.Round); err != nil {
 		return err
 	}
+	if b.Commit.Height != b.Height || b.Commit.BlockHash != b.Hash(BlockHasher{}) {
+		return fmt.Errorf("commit does not belong to block (%s)", b.Hash(BlockHasher{}))
+	}
+
+	return b.Commit.Verify(vs, b.ChainID)
+}
+
+// validateHeader checks the position of the block in the chain, it does not
+// verify any signature.
+func (v *BlockValidator) validateHeader(b *Block) error {
+	hash := b.Hash(BlockHasher{})
+	if v.bc.HasBlockHash(hash) {
+		return ErrBlockKnown
+	}
+
+	// The parent does not have to be the head, blocks on side branches are
+	// kept for fork choice.
+	parent, err := v.bc.GetHeaderByHash(b.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("block (%s) with height (%d) has %w (%s) => current height (%d)", hash, b.Height, ErrUnknownParent, b.PrevBlockHash, v.bc.Height())
+	}
+
+	if b.Height != parent.Height+1 {
+		return fmt.Errorf("block (%s) with height (%d) does not follow its parent with height (%d)", hash, b.Height, parent.Height)
+	}
+
+	if b.Height <= v.bc.FinalizedHeight() {
+		return ErrBelowFinalized
+	}
+
+	if b.ChainID != v.bc.ChainID() {
+		return fmt.Errorf("block (%s) belongs to chain (%s)", hash, b.ChainID)
+	}
 
 	return nil
 }
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/anthdm/projectx/crypto"
+)
+
+var (
+	ErrUnknownValidator = errors.New("block signed by unknown validator")
+	ErrNotProposer      = errors.New("block signed by validator out of turn")
+)
+
+// ValidatorSet is the set of validators that are allowed to produce blocks.
+// Proposers take turns in the order the validators are defined in genesis.
+type ValidatorSet struct {
+	validators []crypto.PublicKey
+}
+
+func NewValidatorSet(validators ...crypto.PublicKey) *ValidatorSet {
+	return &ValidatorSet{
+		validators: validators,
+	}
+}
+
+func (vs *ValidatorSet) Len() int {
+	return len(vs.validators)
+}
+
+func (vs *ValidatorSet) Validators() []crypto.PublicKey {
+	return vs.validators
+}
+
+func (vs *ValidatorSet) Contains(pubKey crypto.PublicKey) bool {
+	for _, v := range vs.validators {
+		if bytes.Equal(v, pubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QuorumSize returns the number of validators that make up more than 2/3
+// of the set.
+func (vs *ValidatorSet) QuorumSize() int {
+	return len(vs.validators)*2/3 + 1
+}
+
+// Proposer returns the validator whose turn it is to propose the block at
+// the given height and consensus round. Every round that fails moves the
+// turn to the next validator.
+func (vs *ValidatorSet) Proposer(height, round uint32) crypto.PublicKey {
+	if len(vs.validators) == 0 {
+		return nil
+	}
+
+	return vs.validators[(height+round)%uint32(len(vs.validators))]
+}
+
+func (vs *ValidatorSet) IsProposer(pubKey crypto.PublicKey, height, round uint32) bool {
+	return bytes.Equal(vs.Proposer(height, round), pubKey)
+}
+
+// ValidateProposer checks that the given block was signed by the validator
+// whose turn it is in the given round.
+func (vs *ValidatorSet) ValidateProposer(b *Block, round uint32) error {
+	if !vs.Contains(b.Validator) {
+		return ErrUnknownValidator
+	}
+	if !vs.IsProposer(b.Validator, b.Height, round) {
+		return ErrNotProposer
+	}
+
+	return nil
+}
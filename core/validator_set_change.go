@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+// DefaultEpochLength is the number of blocks in an epoch. Validator set
+// changes only take effect at the first block of an epoch.
+const DefaultEpochLength uint32 = 100
+
+type ValidatorChangeType byte
+
+const (
+	ValidatorChangeAdd ValidatorChangeType = iota
+	ValidatorChangeRemove
+)
+
+// ValidatorApproval is the signature of a current validator that approves
+// a validator set change.
+type ValidatorApproval struct {
+	Validator crypto.PublicKey
+	Signature crypto.Signature
+}
+
+// ValidatorSetChangeTx adds or removes a validator. It needs the approval
+// of 2/3+ of the validators at the height it is included and takes effect
+// at EffectiveHeight, which has to be the start of a future epoch.
+type ValidatorSetChangeTx struct {
+	Change          ValidatorChangeType
+	Validator       crypto.PublicKey
+	EffectiveHeight uint32
+	Approvals       []ValidatorApproval
+}
+
+// Bytes returns the encoding of the change that the approvals sign:
+//
+//	change (1) | effective height (4) | validator public key
+func (tx ValidatorSetChangeTx) Bytes() []byte {
+	b := make([]byte, 0, 5+len(tx.Validator))
+	b = append(b, byte(tx.Change))
+	b = binary.LittleEndian.AppendUint32(b, tx.EffectiveHeight)
+	b = append(b, tx.Validator...)
+
+	return b
+}
+
+func (tx ValidatorSetChangeTx) hash() types.Hash {
+	return types.Hash(sha256.Sum256(tx.Bytes()))
+}
+
+// Approve adds the approval of the given validator to the change on the
+// given chain.
+func (tx *ValidatorSetChangeTx) Approve(privKey crypto.PrivateKey, chainID string) error {
+	sig, err := privKey.Sign(SigningDigest(SigningDomainValidatorSetChange, chainID, tx.hash()))
+	if err != nil {
+		return err
+	}
+
+	tx.Approvals = append(tx.Approvals, ValidatorApproval{
+		Validator: privKey.PublicKey(),
+		Signature: *sig,
+	})
+
+	return nil
+}
+
+// VerifyApprovals checks that 2/3+ of the given validator set approved the
+// change on the given chain.
+func (tx ValidatorSetChangeTx) VerifyApprovals(vs *ValidatorSet, chainID string) error {
+	seen := make(map[string]bool, len(tx.Approvals))
+	digest := SigningDigest(SigningDomainValidatorSetChange, chainID, tx.hash())
+
+	for _, a := range tx.Approvals {
+		if !vs.Contains(a.Validator) {
+			return fmt.Errorf("validator set change approved by unknown validator (%s)", a.Validator)
+		}
+		if seen[string(a.Validator)] {
+			return fmt.Errorf("validator set change contains duplicate approval of (%s)", a.Validator)
+		}
+		if !verifySignature(&a.Signature, a.Validator, digest) {
+			return fmt.Errorf("validator set change has invalid approval of (%s)", a.Validator)
+		}
+		seen[string(a.Validator)] = true
+	}
+
+	if vs.Len() == 0 || len(seen) < vs.QuorumSize() {
+		return fmt.Errorf("validator set change has %d approvals, %d needed", len(seen), vs.QuorumSize())
+	}
+
+	return nil
+}
+
+// apply returns a new validator set with the change applied.
+func (vs *ValidatorSet) apply(tx *ValidatorSetChangeTx) (*ValidatorSet, error) {
+	switch tx.Change {
+	case ValidatorChangeAdd:
+		if vs.Contains(tx.Validator) {
+			return nil, fmt.Errorf("validator (%s) is already in the set", tx.Validator)
+		}
+
+		validators := make([]crypto.PublicKey, 0, vs.Len()+1)
+		validators = append(validators, vs.validators...)
+		validators = append(validators, tx.Validator)
+
+		return NewValidatorSet(validators...), nil
+
+	case ValidatorChangeRemove:
+		if !vs.Contains(tx.Validator) {
+			return nil, fmt.Errorf("validator (%s) is not in the set", tx.Validator)
+		}
+		if vs.Len() == 1 {
+			return nil, fmt.Errorf("cannot remove the last validator")
+		}
+
+		validators := make([]crypto.PublicKey, 0, vs.Len()-1)
+		for _, v := range vs.validators {
+			if !bytes.Equal(v, tx.Validator) {
+				validators = append(validators, v)
+			}
+		}
+
+		return NewValidatorSet(validators...), nil
+
+	default:
+		return nil, fmt.Errorf("invalid validator change type (%d)", tx.Change)
+	}
+}
+
+// validatorSetEntry is the validator set that is active from height on.
+type validatorSetEntry struct {
+	height uint32
+	set    *ValidatorSet
+}
+
+// handleValidatorSetChange schedules the change for its effective height.
+// Changes that are scheduled later than the effective height already
+// include the validators of the earlier set, so the change is applied to
+// them as well.
+func (bc *Blockchain) handleValidatorSetChange(tx *ValidatorSetChangeTx, height uint32) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if tx.EffectiveHeight <= height || tx.EffectiveHeight%bc.genesis.epochLength() != 0 {
+		return fmt.Errorf("validator set change effective height (%d) is not a future epoch", tx.EffectiveHeight)
+	}
+
+	if err := tx.VerifyApprovals(bc.validatorSetAt(height), bc.genesis.ChainID); err != nil {
+		return err
+	}
+
+	next, err := bc.validatorSetAt(tx.EffectiveHeight).apply(tx)
+	if err != nil {
+		return err
+	}
+
+	entries := []validatorSetEntry{}
+	inserted := false
+	for _, e := range bc.validatorSets {
+		switch {
+		case e.height < tx.EffectiveHeight:
+			entries = append(entries, e)
+			continue
+		case e.height == tx.EffectiveHeight:
+			e.set = next
+			inserted = true
+		default:
+			if !inserted {
+				entries = append(entries, validatorSetEntry{height: tx.EffectiveHeight, set: next})
+				inserted = true
+			}
+			if set, err := e.set.apply(tx); err == nil {
+				e.set = set
+			}
+		}
+		entries = append(entries, e)
+	}
+	if !inserted {
+		entries = append(entries, validatorSetEntry{height: tx.EffectiveHeight, set: next})
+	}
+	prev := bc.validatorSets
+	bc.validatorSets = entries
+	bc.journal.record(func() {
+		bc.lock.Lock()
+		defer bc.lock.Unlock()
+
+		bc.validatorSets = prev
+	})
+
+	return nil
+}
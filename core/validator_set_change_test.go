@@ -0,0 +1,160 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBlockchainWithEpochLength(t *testing.T, n uint32) *Blockchain {
+	bc, err := NewBlockchain(log.NewNopLogger(), &Genesis{ChainID: testChainID, EpochLength: n})
+	assert.Nil(t, err)
+
+	return bc
+}
+
+func newValidatorSetChangeTx(t *testing.T, change ValidatorChangeType, validator crypto.PublicKey, height uint32, approvers ...crypto.PrivateKey) *Transaction {
+	inner := ValidatorSetChangeTx{
+		Change:          change,
+		Validator:       validator,
+		EffectiveHeight: height,
+	}
+	for _, key := range approvers {
+		assert.Nil(t, inner.Approve(key, testChainID))
+	}
+
+	tx := NewTransaction(nil)
+	tx.TxInner = inner
+	tx.ChainID = testChainID
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+	return tx
+}
+
+// addCommittedBlock adds a block that is proposed and committed by the
+// validators that are active at its height.
+func addCommittedBlock(t *testing.T, bc *Blockchain, keys []crypto.PrivateKey, txx ...*Transaction) {
+	prevHeader, err := bc.GetHeader(bc.Height())
+	assert.Nil(t, err)
+
+	b, err := NewBlockFromPrevHeader(prevHeader, txx)
+	assert.Nil(t, err)
+
+	vs := bc.ValidatorSetAt(b.Height)
+	signers := []crypto.PrivateKey{}
+	for _, key := range keys {
+		if bytes.Equal(key.PublicKey(), vs.Proposer(b.Height, 0)) {
+			b.ChainID = testChainID
+			assert.Nil(t, b.Sign(key))
+		}
+		if vs.Contains(key.PublicKey()) {
+			signers = append(signers, key)
+		}
+	}
+
+	b.Commit = newTestCommit(t, b, 0, signers...)
+	assert.Nil(t, bc.AddBlock(b))
+}
+
+func TestValidatorSetChange(t *testing.T) {
+	keys := []crypto.PrivateKey{
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+	}
+
+	bc := newBlockchainWithEpochLength(t, 4)
+	bc.SetValidatorSet(NewValidatorSet(keys[0].PublicKey(), keys[1].PublicKey(), keys[2].PublicKey()))
+
+	// Not enough approvals, 3 of 3 are needed.
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeAdd, keys[3].PublicKey(), 4, keys[0], keys[1]))
+	assert.Equal(t, 3, bc.ValidatorSetAt(4).Len())
+
+	// Not the start of an epoch.
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeAdd, keys[3].PublicKey(), 5, keys[:3]...))
+	assert.Equal(t, 3, bc.ValidatorSetAt(5).Len())
+
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeAdd, keys[3].PublicKey(), 4, keys[:3]...))
+	assert.Equal(t, 3, bc.ValidatorSetAt(3).Len())
+	assert.Equal(t, 4, bc.ValidatorSetAt(4).Len())
+	assert.True(t, bc.ValidatorSetAt(4).Contains(keys[3].PublicKey()))
+
+	// The new validator is not allowed to sign blocks before the change
+	// takes effect.
+	prevHeader, err := bc.GetHeader(bc.Height())
+	assert.Nil(t, err)
+	b, err := NewBlockFromPrevHeader(prevHeader, nil)
+	assert.Nil(t, err)
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(keys[3]))
+	b.Commit = newTestCommit(t, b, 0, keys...)
+	assert.NotNil(t, bc.AddBlock(b))
+
+	addCommittedBlock(t, bc, keys)
+	addCommittedBlock(t, bc, keys)
+	addCommittedBlock(t, bc, keys)
+
+	// Remove the first validator again at the next epoch, this time 3 of 4
+	// approvals are enough. Height 7 is the turn of the new validator.
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeRemove, keys[0].PublicKey(), 8, keys[1:]...))
+	block, err := bc.GetBlock(7)
+	assert.Nil(t, err)
+	assert.Equal(t, keys[3].PublicKey(), block.Validator)
+
+	assert.Equal(t, 4, bc.ValidatorSetAt(7).Len())
+	assert.Equal(t, 3, bc.ValidatorSetAt(8).Len())
+	assert.False(t, bc.ValidatorSetAt(8).Contains(keys[0].PublicKey()))
+}
+
+func TestValidatorSetChangeApprovalIsBoundToChain(t *testing.T) {
+	key := crypto.GeneratePrivateKey()
+	vs := NewValidatorSet(key.PublicKey())
+
+	tx := ValidatorSetChangeTx{
+		Change:          ValidatorChangeAdd,
+		Validator:       crypto.GeneratePrivateKey().PublicKey(),
+		EffectiveHeight: 4,
+	}
+	assert.Nil(t, tx.Approve(key, testChainID))
+	assert.Nil(t, tx.VerifyApprovals(vs, testChainID))
+	assert.NotNil(t, tx.VerifyApprovals(vs, "other"))
+
+	// A precommit over the same bytes is no approval.
+	sig, err := key.Sign(SigningDigest(SigningDomainVote, testChainID, tx.hash()))
+	assert.Nil(t, err)
+	tx.Approvals[0].Signature = *sig
+	assert.NotNil(t, tx.VerifyApprovals(vs, testChainID))
+}
+
+func TestValidatorSetChangeAppliesToLaterEpochs(t *testing.T) {
+	keys := []crypto.PrivateKey{
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+	}
+
+	bc := newBlockchainWithEpochLength(t, 4)
+	bc.SetValidatorSet(NewValidatorSet(keys[0].PublicKey()))
+
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeAdd, keys[1].PublicKey(), 8, keys[0]))
+	addCommittedBlock(t, bc, keys, newValidatorSetChangeTx(t, ValidatorChangeAdd, keys[2].PublicKey(), 4, keys[0]))
+
+	assert.Equal(t, 1, bc.ValidatorSetAt(3).Len())
+	assert.Equal(t, 2, bc.ValidatorSetAt(4).Len())
+	assert.Equal(t, 3, bc.ValidatorSetAt(8).Len())
+}
+
+func TestValidatorSetChangeProtoEncodeDecode(t *testing.T) {
+	tx := newValidatorSetChangeTx(t, ValidatorChangeRemove, crypto.GeneratePrivateKey().PublicKey(), 100, crypto.GeneratePrivateKey(), crypto.GeneratePrivateKey())
+
+	b, err := MarshalProtoTx(tx)
+	assert.Nil(t, err)
+
+	decoded := new(Transaction)
+	assert.Nil(t, UnmarshalProtoTx(b, decoded))
+	assert.Equal(t, tx.TxInner, decoded.TxInner)
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorSetProposer(t *testing.T) {
+	keys := []crypto.PrivateKey{
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+	}
+	vs := NewValidatorSet(keys[0].PublicKey(), keys[1].PublicKey(), keys[2].PublicKey())
+
+	for height := uint32(0); height < 9; height++ {
+		proposer := keys[height%3].PublicKey()
+		assert.Equal(t, proposer, vs.Proposer(height, 0))
+		assert.True(t, vs.IsProposer(proposer, height, 0))
+
+		// A failed round moves the turn to the next validator.
+		assert.Equal(t, keys[(height+1)%3].PublicKey(), vs.Proposer(height, 1))
+	}
+
+	assert.False(t, vs.Contains(crypto.GeneratePrivateKey().PublicKey()))
+	assert.Nil(t, NewValidatorSet().Proposer(1, 0))
+}
+
+func TestValidatorSetQuorumSize(t *testing.T) {
+	for n, quorum := range map[int]int{1: 1, 2: 2, 3: 3, 4: 3, 7: 5, 10: 7} {
+		keys := make([]crypto.PublicKey, n)
+		assert.Equal(t, quorum, NewValidatorSet(keys...).QuorumSize())
+	}
+}
+
+func TestValidateBlockProposer(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+
+	keys := []crypto.PrivateKey{
+		crypto.GeneratePrivateKey(),
+		crypto.GeneratePrivateKey(),
+	}
+	bc.SetValidatorSet(NewValidatorSet(keys[0].PublicKey(), keys[1].PublicKey()))
+
+	// Signed by someone outside the validator set.
+	b := randomBlock(t, 1, getPrevBlockHash(t, bc, 1))
+	b.Commit = newTestCommit(t, b, 0, keys...)
+	assert.Equal(t, ErrUnknownValidator, bc.AddBlock(b))
+
+	// Signed by a validator whose turn it is not.
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(keys[0]))
+	assert.Equal(t, ErrNotProposer, bc.AddBlock(b))
+
+	// In the next round it is the turn of the other validator.
+	b.Commit = newTestCommit(t, b, 1, keys...)
+	assert.Nil(t, bc.AddBlock(b))
+
+	b = randomBlock(t, 2, getPrevBlockHash(t, bc, 2))
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(keys[0]))
+	b.Commit = newTestCommit(t, b, 0, keys...)
+	assert.Nil(t, bc.AddBlock(b))
+	assert.Equal(t, uint32(2), bc.Height())
+}
+
+func TestValidateBlockMalformedValidator(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	v := NewBlockValidator(bc)
+
+	b := randomBlock(t, 1, getPrevBlockHash(t, bc, 1))
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	b.Validator = bytes.Repeat([]byte{0xff}, 33)
+
+	// Without a validator set only the signature is checked.
+	assert.NotNil(t, v.ValidateBlock(b))
+	assert.NotNil(t, v.ValidateProposal(b, 0))
+
+	key := crypto.GeneratePrivateKey()
+	bc.SetValidatorSet(NewValidatorSet(key.PublicKey()))
+
+	b.Commit = newTestCommit(t, b, 0, key)
+	assert.Equal(t, ErrUnknownValidator, v.ValidateBlock(b))
+	assert.Equal(t, ErrUnknownValidator, v.ValidateProposal(b, 0))
+
+	b.Signature = &crypto.Signature{}
+	b.Validator = key.PublicKey()
+	assert.NotNil(t, v.ValidateProposal(b, 0))
+}
+
+func newTestCommit(t *testing.T, b *Block, round uint32, keys ...crypto.PrivateKey) *Commit {
+	precommits := []*Vote{}
+	for _, key := range keys {
+		v := &Vote{
+			Type:      VoteTypePrecommit,
+			Height:    b.Height,
+			Round:     round,
+			BlockHash: b.Hash(BlockHasher{}),
+		}
+		assert.Nil(t, v.Sign(key, testChainID))
+		precommits = append(precommits, v)
+	}
+
+	return NewCommit(precommits)
+}
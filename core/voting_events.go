@@ -0,0 +1,92 @@
+package core
+
+// VotingEventType identifies a lifecycle change in the voting state
+type VotingEventType string
+
+const (
+	VotingEventElectionOpened   VotingEventType = "election.opened"
+	VotingEventElectionClosed   VotingEventType = "election.closed"
+	VotingEventVoterPending     VotingEventType = "registration.voter.pending"
+	VotingEventCandidatePending VotingEventType = "registration.candidate.pending"
+)
+
+// VotingEvent describes a single change in the voting state. The timestamp
+// is the time of the block that caused the change.
+type VotingEvent struct {
+	Type        VotingEventType
+	ElectionID  string
+	VoterID     string
+	CandidateID string
+	Timestamp   int64
+}
+
+// VotingEventHandler is called for every event emitted by the voting state.
+// Handlers are invoked while the state lock is held, so they should hand the
+// event off quickly and must not call back into the VotingState.
+type VotingEventHandler func(VotingEvent)
+
+// OnEvent registers a handler that will receive all future voting events
+func (vs *VotingState) OnEvent(h VotingEventHandler) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.handlers = append(vs.handlers, h)
+}
+
+func (vs *VotingState) emit(ev VotingEvent) {
+	for _, h := range vs.handlers {
+		h(ev)
+	}
+}
+
+// emitOnCommit emits the event once the transaction that caused it is
+// committed, so reverted transactions leave no events behind. The lock has
+// to be held.
+func (vs *VotingState) emitOnCommit(ev VotingEvent) {
+	if vs.emitAfterCommit(ev) {
+		vs.txEvents = append(vs.txEvents, ev)
+	}
+}
+
+// emitAfterCommit emits the event once the current changes are committed,
+// or right away if no snapshot is open. It returns false in the latter case.
+// The lock has to be held.
+func (vs *VotingState) emitAfterCommit(ev VotingEvent) bool {
+	ok := vs.journal.onCommit(func() {
+		vs.mu.RLock()
+		defer vs.mu.RUnlock()
+
+		vs.emit(ev)
+	})
+	if !ok {
+		vs.emit(ev)
+	}
+
+	return ok
+}
+
+// takeEvents returns the events emitted by the current transaction if it
+// succeeded and starts collecting the events of the next one.
+func (vs *VotingState) takeEvents(succeeded bool) []VotingEvent {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	events := vs.txEvents
+	vs.txEvents = nil
+	if !succeeded {
+		return nil
+	}
+
+	return events
+}
+
+// setHandlers replaces the event handlers and returns the previous ones.
+func (vs *VotingState) setHandlers(handlers []VotingEventHandler) []VotingEventHandler {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	prev := vs.handlers
+	vs.handlers = handlers
+
+	return prev
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVotingStateEmitsEvents(t *testing.T) {
+	vs := NewVotingState()
+	events := []VotingEvent{}
+	vs.OnEvent(func(ev VotingEvent) {
+		events = append(events, ev)
+	})
+
+	// The events carry the given block time, not the wall clock.
+	now := int64(1700000000)
+	assert.Nil(t, vs.CreateElection(&ElectionCreationTx{
+		ElectionID:     "e1",
+		StartTime:      now - 10,
+		EndTime:        now + 100,
+		AdminPublicKey: crypto.GeneratePrivateKey().PublicKey(),
+	}, now))
+	assert.Nil(t, vs.RegisterVoter(&VoterRegistrationTx{VoterID: "v1"}, now))
+	assert.Nil(t, vs.RegisterCandidate(&CandidateRegistrationTx{CandidateID: "c1", ElectionID: "e1"}, now))
+
+	// move the election into the past so the status update closes it.
+	vs.elections["e1"].EndTime = now - 1
+	vs.UpdateElectionStatuses(now)
+	vs.UpdateElectionStatuses(now)
+
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, VotingEventElectionOpened, events[0].Type)
+	assert.Equal(t, VotingEventVoterPending, events[1].Type)
+	assert.Equal(t, "v1", events[1].VoterID)
+	assert.Equal(t, VotingEventCandidatePending, events[2].Type)
+	assert.Equal(t, "c1", events[2].CandidateID)
+	assert.Equal(t, VotingEventElectionClosed, events[3].Type)
+	for _, ev := range events {
+		assert.Equal(t, now, ev.Timestamp)
+	}
+}
+
+func TestVotingEventsCarryBlockTime(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	events := []VotingEvent{}
+	bc.GetVotingState().OnEvent(func(ev VotingEvent) {
+		events = append(events, ev)
+	})
+
+	voter := crypto.GeneratePrivateKey()
+	b := newChildBlockAt(t, bc.headers[0], 1700000000,
+		newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}),
+	)
+	assert.Nil(t, bc.AddBlock(b))
+
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, int64(1700000000), events[0].Timestamp)
+}
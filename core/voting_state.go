@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 	"time"
@@ -75,6 +76,13 @@ type VotingState struct {
 	voters    map[string]*Voter          // VoterID -> Voter
 	elections map[string]*Election       // ElectionID -> Election
 	hasVoted  map[string]map[string]bool // ElectionID -> VoterID -> has voted
+	handlers  []VotingEventHandler
+	// Keys that can approve and reject voters, if empty any key can.
+	registrars []crypto.PublicKey
+	// Undo log of the changes made by transactions, may be nil.
+	journal *Journal
+	// Events emitted by the transaction that is being executed.
+	txEvents []VotingEvent
 }
 
 // NewVotingState creates a new VotingState
@@ -86,8 +94,9 @@ func NewVotingState() *VotingState {
 	}
 }
 
-// RegisterVoter adds a new voter to the state
-func (vs *VotingState) RegisterVoter(tx *VoterRegistrationTx) error {
+// RegisterVoter adds a new voter to the state, now is the time of the
+// block the registration is included in.
+func (vs *VotingState) RegisterVoter(tx *VoterRegistrationTx, now int64) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -105,39 +114,82 @@ func (vs *VotingState) RegisterVoter(tx *VoterRegistrationTx) error {
 	}
 
 	vs.voters[tx.VoterID] = voter
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		delete(vs.voters, tx.VoterID)
+	})
+	vs.emitOnCommit(VotingEvent{
+		Type:      VotingEventVoterPending,
+		VoterID:   tx.VoterID,
+		Timestamp: now,
+	})
+
 	return nil
 }
 
-// ApproveVoter approves a voter registration
+// ApproveVoter approves a voter registration, the key has to be a
+// registrar.
 func (vs *VotingState) ApproveVoter(voterID string, adminKey crypto.PublicKey) error {
+	return vs.setVoterStatus(voterID, adminKey, VoterStatusApproved)
+}
+
+// RejectVoter rejects a voter registration, the key has to be a registrar.
+func (vs *VotingState) RejectVoter(voterID string, adminKey crypto.PublicKey) error {
+	return vs.setVoterStatus(voterID, adminKey, VoterStatusRejected)
+}
+
+func (vs *VotingState) setVoterStatus(voterID string, adminKey crypto.PublicKey, status VoterStatus) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
+	if !vs.isRegistrar(adminKey) {
+		return fmt.Errorf("%s is not a registrar", adminKey)
+	}
+
 	voter, exists := vs.voters[voterID]
 	if !exists {
 		return fmt.Errorf("voter with ID %s does not exist", voterID)
 	}
 
-	voter.Status = VoterStatusApproved
+	prev := voter.Status
+	voter.Status = status
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		voter.Status = prev
+	})
+
 	return nil
 }
 
-// RejectVoter rejects a voter registration
-func (vs *VotingState) RejectVoter(voterID string, adminKey crypto.PublicKey) error {
+// SetRegistrars sets the keys that can approve and reject voters.
+func (vs *VotingState) SetRegistrars(keys []crypto.PublicKey) {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
-	voter, exists := vs.voters[voterID]
-	if !exists {
-		return fmt.Errorf("voter with ID %s does not exist", voterID)
+	vs.registrars = keys
+}
+
+func (vs *VotingState) isRegistrar(key crypto.PublicKey) bool {
+	if len(vs.registrars) == 0 {
+		return true
 	}
 
-	voter.Status = VoterStatusRejected
-	return nil
+	for _, r := range vs.registrars {
+		if bytes.Equal(r, key) {
+			return true
+		}
+	}
+
+	return false
 }
 
-// CreateElection creates a new election
-func (vs *VotingState) CreateElection(tx *ElectionCreationTx) error {
+// CreateElection creates a new election, its status is derived from the
+// given time of the block it is created in.
+func (vs *VotingState) CreateElection(tx *ElectionCreationTx, now int64) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -161,20 +213,28 @@ func (vs *VotingState) CreateElection(tx *ElectionCreationTx) error {
 
 	vs.elections[tx.ElectionID] = election
 	vs.hasVoted[tx.ElectionID] = make(map[string]bool)
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		delete(vs.elections, tx.ElectionID)
+		delete(vs.hasVoted, tx.ElectionID)
+	})
 
-	// Update election status based on current time
-	now := time.Now().Unix()
 	if now >= election.StartTime && now < election.EndTime {
 		election.Status = ElectionStatusActive
+		vs.emitOnCommit(VotingEvent{Type: VotingEventElectionOpened, ElectionID: election.ID, Timestamp: now})
 	} else if now >= election.EndTime {
 		election.Status = ElectionStatusEnded
+		vs.emitOnCommit(VotingEvent{Type: VotingEventElectionClosed, ElectionID: election.ID, Timestamp: now})
 	}
 
 	return nil
 }
 
-// RegisterCandidate adds a new candidate to an election
-func (vs *VotingState) RegisterCandidate(tx *CandidateRegistrationTx) error {
+// RegisterCandidate adds a new candidate to an election, now is the time of
+// the block the registration is included in.
+func (vs *VotingState) RegisterCandidate(tx *CandidateRegistrationTx, now int64) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -201,35 +261,36 @@ func (vs *VotingState) RegisterCandidate(tx *CandidateRegistrationTx) error {
 
 	election.Candidates[tx.CandidateID] = candidate
 	election.VoteCounts[tx.CandidateID] = 0
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		delete(election.Candidates, tx.CandidateID)
+		delete(election.VoteCounts, tx.CandidateID)
+	})
+	vs.emitOnCommit(VotingEvent{
+		Type:        VotingEventCandidatePending,
+		ElectionID:  tx.ElectionID,
+		CandidateID: tx.CandidateID,
+		Timestamp:   now,
+	})
+
 	return nil
 }
 
-// ApproveCandidate approves a candidate registration
+// ApproveCandidate approves a candidate registration, the key has to be the
+// admin of the election.
 func (vs *VotingState) ApproveCandidate(electionID, candidateID string, adminKey crypto.PublicKey) error {
-	vs.mu.Lock()
-	defer vs.mu.Unlock()
-
-	election, exists := vs.elections[electionID]
-	if !exists {
-		return fmt.Errorf("election with ID %s does not exist", electionID)
-	}
-
-	// Verify admin key using string representation
-	if election.AdminKey.String() != adminKey.String() {
-		return fmt.Errorf("unauthorized: only the election admin can approve candidates")
-	}
-
-	candidate, exists := election.Candidates[candidateID]
-	if !exists {
-		return fmt.Errorf("candidate with ID %s does not exist in election %s", candidateID, electionID)
-	}
-
-	candidate.Status = CandidateStatusApproved
-	return nil
+	return vs.setCandidateStatus(electionID, candidateID, adminKey, CandidateStatusApproved)
 }
 
-// RejectCandidate rejects a candidate registration
+// RejectCandidate rejects a candidate registration, the key has to be the
+// admin of the election.
 func (vs *VotingState) RejectCandidate(electionID, candidateID string, adminKey crypto.PublicKey) error {
+	return vs.setCandidateStatus(electionID, candidateID, adminKey, CandidateStatusRejected)
+}
+
+func (vs *VotingState) setCandidateStatus(electionID, candidateID string, adminKey crypto.PublicKey, status CandidateStatus) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -238,9 +299,8 @@ func (vs *VotingState) RejectCandidate(electionID, candidateID string, adminKey
 		return fmt.Errorf("election with ID %s does not exist", electionID)
 	}
 
-	// Verify admin key using string representation
-	if election.AdminKey.String() != adminKey.String() {
-		return fmt.Errorf("unauthorized: only the election admin can reject candidates")
+	if !bytes.Equal(election.AdminKey, adminKey) {
+		return fmt.Errorf("unauthorized: only the election admin can approve or reject candidates")
 	}
 
 	candidate, exists := election.Candidates[candidateID]
@@ -248,12 +308,21 @@ func (vs *VotingState) RejectCandidate(electionID, candidateID string, adminKey
 		return fmt.Errorf("candidate with ID %s does not exist in election %s", candidateID, electionID)
 	}
 
-	candidate.Status = CandidateStatusRejected
+	prev := candidate.Status
+	candidate.Status = status
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		candidate.Status = prev
+	})
+
 	return nil
 }
 
-// CastVote records a vote for a candidate
-func (vs *VotingState) CastVote(tx *VoteTx) error {
+// CastVote records a vote for a candidate, the given time of the block the
+// vote is cast in has to be within the voting window of the election.
+func (vs *VotingState) CastVote(tx *VoteTx, now int64) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -264,7 +333,6 @@ func (vs *VotingState) CastVote(tx *VoteTx) error {
 	}
 
 	// Check if election is active
-	now := time.Now().Unix()
 	if now < election.StartTime {
 		return fmt.Errorf("election %s has not started yet", tx.ElectionID)
 	}
@@ -309,6 +377,14 @@ func (vs *VotingState) CastVote(tx *VoteTx) error {
 	election.VoteCounts[tx.CandidateID]++
 	candidate.VoteCount++
 	vs.hasVoted[tx.ElectionID][voterID] = true
+	vs.journal.record(func() {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+
+		election.VoteCounts[tx.CandidateID]--
+		candidate.VoteCount--
+		delete(vs.hasVoted[tx.ElectionID], voterID)
+	})
 
 	return nil
 }
@@ -382,17 +458,30 @@ func (vs *VotingState) GetCandidate(electionID, candidateID string) (*Candidate,
 	return candidate, nil
 }
 
-// UpdateElectionStatuses updates the status of all elections based on current time
-func (vs *VotingState) UpdateElectionStatuses() {
+// UpdateElectionStatuses updates the status of all elections based on the
+// given time of the latest block.
+func (vs *VotingState) UpdateElectionStatuses(now int64) {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
-	now := time.Now().Unix()
 	for _, election := range vs.elections {
+		prev := election.Status
 		if now >= election.StartTime && now < election.EndTime && election.Status != ElectionStatusActive {
 			election.Status = ElectionStatusActive
+			vs.emitAfterCommit(VotingEvent{Type: VotingEventElectionOpened, ElectionID: election.ID, Timestamp: now})
 		} else if now >= election.EndTime && election.Status != ElectionStatusEnded {
 			election.Status = ElectionStatusEnded
+			vs.emitAfterCommit(VotingEvent{Type: VotingEventElectionClosed, ElectionID: election.ID, Timestamp: now})
+		} else {
+			continue
 		}
+
+		election := election
+		vs.journal.record(func() {
+			vs.mu.Lock()
+			defer vs.mu.Unlock()
+
+			election.Status = prev
+		})
 	}
 }
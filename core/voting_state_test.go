@@ -0,0 +1,121 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// newChildBlockAt creates a signed child block with a timestamp in Unix
+// seconds.
+func newChildBlockAt(t *testing.T, parent *Header, unix int64, txx ...*Transaction) *Block {
+	b, err := NewBlockFromPrevHeader(parent, txx)
+	assert.Nil(t, err)
+	b.Timestamp = time.Unix(unix, 0).UnixNano()
+	b.ChainID = testChainID
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+
+	return b
+}
+
+func TestVotingUsesBlockTime(t *testing.T) {
+	bc := newBlockchainWithGenesis(t)
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+
+	// The election ended a minute ago by the clock of this node, but the
+	// blocks were produced while it was open.
+	end := time.Now().Unix() - 60
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, bc.headers[0], end-30,
+		newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "e1", StartTime: end - 100, EndTime: end, AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}),
+		newVotingTx(t, admin, 1, CandidateRegistrationTx{CandidateID: "c1", ElectionID: "e1"}),
+	)))
+	election, err := bc.GetVotingState().GetElection("e1")
+	assert.Nil(t, err)
+	assert.Equal(t, ElectionStatusActive, election.Status)
+
+	vs := bc.GetVotingState()
+	assert.Nil(t, vs.ApproveVoter("v1", admin.PublicKey()))
+	assert.Nil(t, vs.ApproveCandidate("e1", "c1", admin.PublicKey()))
+
+	vote := newVotingTx(t, voter, 1, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: voter.PublicKey()})
+
+	// The proposer executes the vote with the time of the block it builds,
+	// like the validators of the block do.
+	snapshot := bc.journal.Snapshot()
+	bc.applyTransactions([]*Transaction{vote}, end-10)
+	assert.Equal(t, uint64(1), election.VoteCounts["c1"])
+	bc.journal.RevertTo(snapshot)
+	bc.journal.Commit()
+	assert.Equal(t, uint64(0), election.VoteCounts["c1"])
+
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, bc.headers[1], end-10, vote)))
+
+	receipt, err := bc.GetReceipt(vote.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status, receipt.Error)
+	assert.Equal(t, uint64(1), election.VoteCounts["c1"])
+	assert.Equal(t, ElectionStatusActive, election.Status)
+
+	// A block after the end closes the election and rejects late votes.
+	late := crypto.GeneratePrivateKey()
+	lateVote := newVotingTx(t, late, 0, VoteTx{ElectionID: "e1", CandidateID: "c1", VoterPublicKey: late.PublicKey()})
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, bc.headers[2], end, lateVote)))
+	assert.Equal(t, ElectionStatusEnded, election.Status)
+
+	receipt, err = bc.GetReceipt(lateVote.Hash(TxHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Contains(t, receipt.Error, "has ended")
+}
+
+func TestApprovalTransactions(t *testing.T) {
+	registrar := crypto.GeneratePrivateKey()
+	bc, err := NewBlockchain(log.NewNopLogger(), &Genesis{ChainID: testChainID, Registrars: []crypto.PublicKey{registrar.PublicKey()}})
+	assert.Nil(t, err)
+
+	admin := crypto.GeneratePrivateKey()
+	voter := crypto.GeneratePrivateKey()
+	now := time.Now().Unix()
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, bc.headers[0], now,
+		newVotingTx(t, admin, 0, ElectionCreationTx{ElectionID: "e1", StartTime: now, EndTime: now + 100, AdminPublicKey: admin.PublicKey()}),
+		newVotingTx(t, voter, 0, VoterRegistrationTx{VoterID: "v1", VoterPublicKey: voter.PublicKey()}),
+		newVotingTx(t, admin, 1, CandidateRegistrationTx{CandidateID: "c1", ElectionID: "e1"}),
+	)))
+
+	// Only registrars approve voters and only the admin of the election
+	// approves its candidates.
+	byAdmin := newVotingTx(t, admin, 2, VoterApprovalTx{VoterID: "v1", Approve: true})
+	byRegistrar := newVotingTx(t, registrar, 0, VoterApprovalTx{VoterID: "v1", Approve: true})
+	byVoter := newVotingTx(t, voter, 1, CandidateApprovalTx{ElectionID: "e1", CandidateID: "c1", Approve: true})
+	candidate := newVotingTx(t, admin, 3, CandidateApprovalTx{ElectionID: "e1", CandidateID: "c1", Approve: false})
+	assert.Nil(t, bc.AddBlock(newChildBlockAt(t, bc.headers[1], now+1, byAdmin, byRegistrar, byVoter, candidate)))
+
+	for _, tx := range []*Transaction{byAdmin, byVoter} {
+		receipt, err := bc.GetReceipt(tx.Hash(TxHasher{}))
+		assert.Nil(t, err)
+		assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	}
+	for _, tx := range []*Transaction{byRegistrar, candidate} {
+		receipt, err := bc.GetReceipt(tx.Hash(TxHasher{}))
+		assert.Nil(t, err)
+		assert.Equal(t, ReceiptStatusSuccess, receipt.Status, receipt.Error)
+	}
+
+	v, err := bc.GetVotingState().GetVoter("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, VoterStatusApproved, v.Status)
+
+	c, err := bc.GetVotingState().GetCandidate("e1", "c1")
+	assert.Nil(t, err)
+	assert.Equal(t, CandidateStatusRejected, c.Status)
+
+	// The approvals are undone with their block.
+	bc.rewind(bc.head.parent)
+	assert.Equal(t, VoterStatusPending, v.Status)
+	assert.Equal(t, CandidateStatusPending, c.Status)
+}
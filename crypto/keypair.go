@@ -1,11 +1,13 @@
 package crypto
 
 import (
+	gocrypto "crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"math/big"
 
@@ -43,6 +45,37 @@ func GeneratePrivateKey() PrivateKey {
 	return NewPrivateKeyFromReader(rand.Reader)
 }
 
+// PrivateKeyFromBytes decodes a private key from its 32 byte scalar, see
+// Bytes.
+func PrivateKeyFromBytes(b []byte) (PrivateKey, error) {
+	curve := elliptic.P256()
+	if len(b) != 32 {
+		return PrivateKey{}, fmt.Errorf("invalid private key length %d", len(b))
+	}
+
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return PrivateKey{}, fmt.Errorf("private key is out of range")
+	}
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.PublicKey.Curve = curve
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(b)
+
+	return PrivateKey{key: key}, nil
+}
+
+// Bytes returns the 32 byte scalar of the private key.
+func (k PrivateKey) Bytes() []byte {
+	return k.key.D.FillBytes(make([]byte, 32))
+}
+
+// Signer returns the key for use with the standard library, for example to
+// sign TLS certificates.
+func (k PrivateKey) Signer() gocrypto.Signer {
+	return k.key
+}
+
 func (k PrivateKey) PublicKey() PublicKey {
 	return elliptic.MarshalCompressed(k.key.PublicKey, k.key.PublicKey.X, k.key.PublicKey.Y)
 }
@@ -31,3 +31,20 @@ func TestKeypairSignVerifyFail(t *testing.T) {
 	assert.False(t, sig.Verify(otherPublicKey, msg))
 	assert.False(t, sig.Verify(publicKey, []byte("xxxxxx")))
 }
+
+func TestPrivateKeyFromBytes(t *testing.T) {
+	privKey := GeneratePrivateKey()
+
+	decoded, err := PrivateKeyFromBytes(privKey.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, privKey.PublicKey(), decoded.PublicKey())
+
+	sig, err := decoded.Sign([]byte("hello world"))
+	assert.Nil(t, err)
+	assert.True(t, sig.Verify(privKey.PublicKey(), []byte("hello world")))
+
+	_, err = PrivateKeyFromBytes(make([]byte, 32))
+	assert.NotNil(t, err)
+	_, err = PrivateKeyFromBytes(privKey.Bytes()[:31])
+	assert.NotNil(t, err)
+}
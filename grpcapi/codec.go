@@ -0,0 +1,28 @@
+package grpcapi
+
+import "fmt"
+
+// codec puts our hand written messages on the wire. It registers under the
+// name "proto" so clients generated from bockvote.proto in other languages
+// can talk to the node without any special configuration.
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(Message)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T: not a grpcapi message", v)
+	}
+	return m.Marshal()
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(Message)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T: not a grpcapi message", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,301 @@
+package grpcapi
+
+import (
+	"sort"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Message is implemented by all request and response types of the Node
+// service. It is what the codec uses to put them on the wire.
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+type Transaction struct {
+	*core.Transaction
+}
+
+func (m *Transaction) Marshal() ([]byte, error) {
+	return core.MarshalProtoTx(m.Transaction)
+}
+
+func (m *Transaction) Unmarshal(b []byte) error {
+	m.Transaction = new(core.Transaction)
+	return core.UnmarshalProtoTx(b, m.Transaction)
+}
+
+type Block struct {
+	*core.Block
+}
+
+func (m *Block) Marshal() ([]byte, error) {
+	return core.MarshalProtoBlock(m.Block)
+}
+
+func (m *Block) Unmarshal(b []byte) error {
+	m.Block = new(core.Block)
+	return core.UnmarshalProtoBlock(b, m.Block)
+}
+
+type SubmitTransactionResponse struct {
+	Hash types.Hash
+}
+
+func (m *SubmitTransactionResponse) Marshal() ([]byte, error) {
+	return appendBytes(nil, 1, m.Hash.ToSlice()), nil
+}
+
+func (m *SubmitTransactionResponse) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) (err error) {
+		if f.num == 1 {
+			m.Hash, err = decodeHash(f.bytes)
+		}
+		return
+	})
+}
+
+// GetBlockRequest queries a block by hash if Hash is set, by height otherwise.
+type GetBlockRequest struct {
+	Height uint32
+	Hash   []byte
+}
+
+func (m *GetBlockRequest) Marshal() ([]byte, error) {
+	if len(m.Hash) > 0 {
+		return appendBytes(nil, 2, m.Hash), nil
+	}
+	// The height is part of a oneof so it is written even when it is zero.
+	b := protowire.AppendTag(nil, 1, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(m.Height)), nil
+}
+
+func (m *GetBlockRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Height = uint32(f.varint)
+		case 2:
+			m.Hash = copyBytes(f.bytes)
+		}
+		return nil
+	})
+}
+
+type GetTransactionRequest struct {
+	Hash []byte
+}
+
+func (m *GetTransactionRequest) Marshal() ([]byte, error) {
+	return appendBytes(nil, 1, m.Hash), nil
+}
+
+func (m *GetTransactionRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		if f.num == 1 {
+			m.Hash = copyBytes(f.bytes)
+		}
+		return nil
+	})
+}
+
+type GetElectionRequest struct {
+	ElectionID string
+}
+
+func (m *GetElectionRequest) Marshal() ([]byte, error) {
+	return appendString(nil, 1, m.ElectionID), nil
+}
+
+func (m *GetElectionRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		if f.num == 1 {
+			m.ElectionID = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+type Candidate struct {
+	ID              string
+	PublicKey       []byte
+	IPFSProfileHash string
+	Status          core.CandidateStatus
+	VoteCount       uint64
+}
+
+func (m *Candidate) marshal() []byte {
+	b := appendString(nil, 1, m.ID)
+	b = appendBytes(b, 2, m.PublicKey)
+	b = appendString(b, 3, m.IPFSProfileHash)
+	b = appendVarint(b, 4, uint64(m.Status))
+	b = appendVarint(b, 5, m.VoteCount)
+	return b
+}
+
+func (m *Candidate) unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.ID = string(f.bytes)
+		case 2:
+			m.PublicKey = copyBytes(f.bytes)
+		case 3:
+			m.IPFSProfileHash = string(f.bytes)
+		case 4:
+			m.Status = core.CandidateStatus(f.varint)
+		case 5:
+			m.VoteCount = f.varint
+		}
+		return nil
+	})
+}
+
+type Election struct {
+	ID          string
+	Title       string
+	Description string
+	StartTime   int64
+	EndTime     int64
+	AdminKey    []byte
+	Status      core.ElectionStatus
+	Candidates  []*Candidate
+}
+
+func newElection(e *core.Election) *Election {
+	election := &Election{
+		ID:          e.ID,
+		Title:       e.Title,
+		Description: e.Description,
+		StartTime:   e.StartTime,
+		EndTime:     e.EndTime,
+		AdminKey:    e.AdminKey,
+		Status:      e.Status,
+		Candidates:  make([]*Candidate, 0, len(e.Candidates)),
+	}
+
+	for _, c := range e.Candidates {
+		election.Candidates = append(election.Candidates, &Candidate{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			IPFSProfileHash: c.IPFSProfileHash,
+			Status:          c.Status,
+			VoteCount:       c.VoteCount,
+		})
+	}
+
+	// Keep the encoding stable by ordering the candidates.
+	sort.Slice(election.Candidates, func(i, j int) bool {
+		return election.Candidates[i].ID < election.Candidates[j].ID
+	})
+
+	return election
+}
+
+func (m *Election) Marshal() ([]byte, error) {
+	b := appendString(nil, 1, m.ID)
+	b = appendString(b, 2, m.Title)
+	b = appendString(b, 3, m.Description)
+	b = appendVarint(b, 4, uint64(m.StartTime))
+	b = appendVarint(b, 5, uint64(m.EndTime))
+	b = appendBytes(b, 6, m.AdminKey)
+	b = appendVarint(b, 7, uint64(m.Status))
+	for _, c := range m.Candidates {
+		b = appendMessage(b, 8, c.marshal())
+	}
+	return b, nil
+}
+
+func (m *Election) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.ID = string(f.bytes)
+		case 2:
+			m.Title = string(f.bytes)
+		case 3:
+			m.Description = string(f.bytes)
+		case 4:
+			m.StartTime = int64(f.varint)
+		case 5:
+			m.EndTime = int64(f.varint)
+		case 6:
+			m.AdminKey = copyBytes(f.bytes)
+		case 7:
+			m.Status = core.ElectionStatus(f.varint)
+		case 8:
+			c := new(Candidate)
+			if err := c.unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Candidates = append(m.Candidates, c)
+		}
+		return nil
+	})
+}
+
+type VotingEvent struct {
+	core.VotingEvent
+}
+
+func (m *VotingEvent) Marshal() ([]byte, error) {
+	b := appendString(nil, 1, string(m.Type))
+	b = appendString(b, 2, m.ElectionID)
+	b = appendString(b, 3, m.VoterID)
+	b = appendString(b, 4, m.CandidateID)
+	b = appendVarint(b, 5, uint64(m.Timestamp))
+	return b, nil
+}
+
+func (m *VotingEvent) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Type = core.VotingEventType(f.bytes)
+		case 2:
+			m.ElectionID = string(f.bytes)
+		case 3:
+			m.VoterID = string(f.bytes)
+		case 4:
+			m.CandidateID = string(f.bytes)
+		case 5:
+			m.Timestamp = int64(f.varint)
+		}
+		return nil
+	})
+}
+
+type SubscribeBlocksRequest struct{}
+
+func (m *SubscribeBlocksRequest) Marshal() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *SubscribeBlocksRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error { return nil })
+}
+
+type SubscribeVotingEventsRequest struct {
+	// Only deliver events of the given types, all events if empty.
+	Types []core.VotingEventType
+}
+
+func (m *SubscribeVotingEventsRequest) Marshal() ([]byte, error) {
+	b := []byte{}
+	for _, t := range m.Types {
+		b = appendString(b, 1, string(t))
+	}
+	return b, nil
+}
+
+func (m *SubscribeVotingEventsRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		if f.num == 1 {
+			m.Types = append(m.Types, core.VotingEventType(f.bytes))
+		}
+		return nil
+	})
+}
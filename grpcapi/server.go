@@ -0,0 +1,271 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Number of messages a subscriber may lag behind before its stream is closed.
+var subscriberBufferSize = 128
+
+// PendingPool holds the transactions that are waiting to be included in a
+// block.
+type PendingPool interface {
+	Pending() []*core.Transaction
+}
+
+type ServerConfig struct {
+	Logger     log.Logger
+	ListenAddr string
+	// Mempool is used to check new transactions against the pending ones,
+	// it is optional.
+	Mempool PendingPool
+}
+
+type Server struct {
+	ServerConfig
+	bc         *core.Blockchain
+	txChan     chan *core.Transaction
+	grpcServer *grpc.Server
+
+	mu        sync.RWMutex
+	blockSubs map[*subscriber[*core.Block]]struct{}
+	eventSubs map[*subscriber[core.VotingEvent]]struct{}
+}
+
+func NewServer(cfg ServerConfig, bc *core.Blockchain, txChan chan *core.Transaction) *Server {
+	if cfg.Logger == nil {
+		cfg.Logger = log.NewNopLogger()
+	}
+
+	s := &Server{
+		ServerConfig: cfg,
+		bc:           bc,
+		txChan:       txChan,
+		grpcServer:   grpc.NewServer(grpc.ForceServerCodec(codec{})),
+		blockSubs:    make(map[*subscriber[*core.Block]]struct{}),
+		eventSubs:    make(map[*subscriber[core.VotingEvent]]struct{}),
+	}
+
+	RegisterNodeServer(s.grpcServer, s)
+
+	bc.OnBlock(s.publishBlock)
+	bc.GetVotingState().OnEvent(s.publishVotingEvent)
+
+	return s
+}
+
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) error {
+	return s.grpcServer.Serve(ln)
+}
+
+func (s *Server) Stop() {
+	s.grpcServer.Stop()
+}
+
+func (s *Server) SubmitTransaction(ctx context.Context, in *Transaction) (*SubmitTransactionResponse, error) {
+	if in.Transaction == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing transaction")
+	}
+
+	var pending []*core.Transaction
+	if s.Mempool != nil {
+		pending = s.Mempool.Pending()
+	}
+	if err := s.bc.CheckTransaction(in.Transaction, pending); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	select {
+	case s.txChan <- in.Transaction:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	return &SubmitTransactionResponse{
+		Hash: in.Transaction.Hash(core.TxHasher{}),
+	}, nil
+}
+
+func (s *Server) GetBlock(ctx context.Context, in *GetBlockRequest) (*Block, error) {
+	var (
+		block *core.Block
+		err   error
+	)
+
+	if len(in.Hash) > 0 {
+		hash, herr := decodeHash(in.Hash)
+		if herr != nil {
+			return nil, status.Error(codes.InvalidArgument, herr.Error())
+		}
+		block, err = s.bc.GetBlockByHash(hash)
+	} else {
+		block, err = s.bc.GetBlock(in.Height)
+	}
+
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &Block{block}, nil
+}
+
+func (s *Server) GetTransaction(ctx context.Context, in *GetTransactionRequest) (*Transaction, error) {
+	hash, err := decodeHash(in.Hash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx, err := s.bc.GetTxByHash(hash)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &Transaction{tx}, nil
+}
+
+func (s *Server) GetElection(ctx context.Context, in *GetElectionRequest) (*Election, error) {
+	election, err := s.bc.GetVotingState().GetElection(in.ElectionID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return newElection(election), nil
+}
+
+func (s *Server) SubscribeBlocks(in *SubscribeBlocksRequest, stream BlockStream) error {
+	sub := newSubscriber[*core.Block]()
+
+	s.mu.Lock()
+	s.blockSubs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.blockSubs, sub)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case b, ok := <-sub.ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber is too slow")
+			}
+			if err := stream.Send(&Block{b}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *Server) SubscribeVotingEvents(in *SubscribeVotingEventsRequest, stream VotingEventStream) error {
+	sub := newSubscriber[core.VotingEvent]()
+
+	s.mu.Lock()
+	s.eventSubs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.eventSubs, sub)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber is too slow")
+			}
+			if !acceptsEvent(in.Types, ev.Type) {
+				continue
+			}
+			if err := stream.Send(&VotingEvent{ev}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *Server) publishBlock(b *core.Block) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.blockSubs {
+		sub.publish(b)
+	}
+}
+
+func (s *Server) publishVotingEvent(ev core.VotingEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.eventSubs {
+		sub.publish(ev)
+	}
+}
+
+func acceptsEvent(filter []core.VotingEventType, t core.VotingEventType) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, ft := range filter {
+		if ft == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscriber buffers the messages for a single stream. Publishing never
+// blocks the chain, a subscriber that can't keep up gets its channel closed.
+type subscriber[T any] struct {
+	mu     sync.Mutex
+	closed bool
+	ch     chan T
+}
+
+func newSubscriber[T any]() *subscriber[T] {
+	return &subscriber[T]{
+		ch: make(chan T, subscriberBufferSize),
+	}
+}
+
+func (sub *subscriber[T]) publish(v T) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed {
+		return
+	}
+
+	select {
+	case sub.ch <- v:
+	default:
+		sub.closed = true
+		close(sub.ch)
+	}
+}
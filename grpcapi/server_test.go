@@ -0,0 +1,175 @@
+package grpcapi
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestTransactionWireRoundTrip(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	tx := core.NewTransaction([]byte("foo"))
+	tx.To = crypto.GeneratePrivateKey().PublicKey()
+	tx.Value = 100
+	tx.Nonce = math.MaxUint64
+	tx.TxInner = core.VoteTx{
+		ElectionID:     "e1",
+		CandidateID:    "c1",
+		VoterPublicKey: privKey.PublicKey(),
+		Timestamp:      time.Now().Unix(),
+	}
+	tx.ChainID = "test"
+	assert.Nil(t, tx.Sign(privKey))
+
+	b, err := (&Transaction{tx}).Marshal()
+	assert.Nil(t, err)
+
+	decoded := &Transaction{}
+	assert.Nil(t, decoded.Unmarshal(b))
+	assert.Equal(t, tx.TxInner, decoded.TxInner)
+	assert.Equal(t, tx.Nonce, decoded.Nonce)
+	assert.Equal(t, tx.Signature, decoded.Signature)
+	assert.Equal(t, txHash(tx), txHash(decoded.Transaction))
+}
+
+func TestServerGetBlockAndTransaction(t *testing.T) {
+	s, client := newTestServer(t)
+	genesis, err := s.bc.GetBlock(0)
+	assert.Nil(t, err)
+
+	block, err := client.GetBlock(context.Background(), &GetBlockRequest{Height: 0})
+	assert.Nil(t, err)
+	assert.Equal(t, genesis.Hash(core.BlockHasher{}), block.Hash(core.BlockHasher{}))
+
+	signed, err := core.NewBlockFromPrevHeader(genesis.Header, nil)
+	assert.Nil(t, err)
+	signed.ChainID = "test"
+	assert.Nil(t, signed.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, s.bc.AddBlock(signed))
+
+	block, err = client.GetBlock(context.Background(), &GetBlockRequest{Height: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, block.Verify())
+
+	hash := genesis.Hash(core.BlockHasher{})
+	block, err = client.GetBlock(context.Background(), &GetBlockRequest{Hash: hash.ToSlice()})
+	assert.Nil(t, err)
+	assert.Equal(t, genesis.Height, block.Height)
+
+	_, err = client.GetBlock(context.Background(), &GetBlockRequest{Height: 10})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetTransaction(context.Background(), &GetTransactionRequest{Hash: []byte{1}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerSubmitTransaction(t *testing.T) {
+	s, client := newTestServer(t)
+
+	privKey := crypto.GeneratePrivateKey()
+	tx := core.NewTransaction([]byte("foo"))
+	tx.From = privKey.PublicKey()
+	tx.ChainID = "test"
+	assert.Nil(t, tx.Sign(privKey))
+
+	resp, err := client.SubmitTransaction(context.Background(), &Transaction{tx})
+	assert.Nil(t, err)
+	assert.Equal(t, tx.Hash(core.TxHasher{}), resp.Hash)
+
+	received := <-s.txChan
+	assert.Nil(t, received.Verify())
+}
+
+func TestServerSubscribeBlocks(t *testing.T) {
+	s, client := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.SubscribeBlocks(ctx, &SubscribeBlocksRequest{})
+	assert.Nil(t, err)
+
+	// Give the server some time to register the subscription.
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.blockSubs) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	prevHeader, err := s.bc.GetHeader(0)
+	assert.Nil(t, err)
+	block, err := core.NewBlockFromPrevHeader(prevHeader, nil)
+	assert.Nil(t, err)
+	block.ChainID = "test"
+	assert.Nil(t, block.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, s.bc.AddBlock(block))
+
+	received, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, block.Hash(core.BlockHasher{}), received.Hash(core.BlockHasher{}))
+}
+
+func TestServerSubscribeVotingEvents(t *testing.T) {
+	s, client := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.SubscribeVotingEvents(ctx, &SubscribeVotingEventsRequest{
+		Types: []core.VotingEventType{core.VotingEventVoterPending},
+	})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.eventSubs) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	vs := s.bc.GetVotingState()
+	assert.Nil(t, vs.CreateElection(&core.ElectionCreationTx{ElectionID: "e1", EndTime: time.Now().Unix() + 100}, time.Now().Unix()))
+	assert.Nil(t, vs.RegisterVoter(&core.VoterRegistrationTx{VoterID: "v1"}, time.Now().Unix()))
+
+	ev, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, core.VotingEventVoterPending, ev.Type)
+	assert.Equal(t, "v1", ev.VoterID)
+
+	election, err := client.GetElection(context.Background(), &GetElectionRequest{ElectionID: "e1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "e1", election.ID)
+}
+
+func txHash(tx *core.Transaction) types.Hash {
+	return core.TxHasher{}.Hash(tx)
+}
+
+func newTestServer(t *testing.T) (*Server, *NodeClient) {
+	bc, err := core.NewBlockchain(log.NewNopLogger(), &core.Genesis{ChainID: "test"})
+	assert.Nil(t, err)
+
+	s := NewServer(ServerConfig{}, bc, make(chan *core.Transaction, 1))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return s, NewNodeClient(conn)
+}
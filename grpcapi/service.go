@@ -0,0 +1,199 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+const serviceName = "bockvote.v1.Node"
+
+// NodeServer is the server API of the Node service defined in bockvote.proto.
+type NodeServer interface {
+	SubmitTransaction(context.Context, *Transaction) (*SubmitTransactionResponse, error)
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*Transaction, error)
+	GetElection(context.Context, *GetElectionRequest) (*Election, error)
+	SubscribeBlocks(*SubscribeBlocksRequest, BlockStream) error
+	SubscribeVotingEvents(*SubscribeVotingEventsRequest, VotingEventStream) error
+}
+
+type BlockStream interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type VotingEventStream interface {
+	Send(*VotingEvent) error
+	grpc.ServerStream
+}
+
+type blockStream struct {
+	grpc.ServerStream
+}
+
+func (s *blockStream) Send(b *Block) error {
+	return s.ServerStream.SendMsg(b)
+}
+
+type votingEventStream struct {
+	grpc.ServerStream
+}
+
+func (s *votingEventStream) Send(ev *VotingEvent) error {
+	return s.ServerStream.SendMsg(ev)
+}
+
+func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
+	s.RegisterService(&nodeServiceDesc, srv)
+}
+
+func unaryHandler[Req any, Resp any](method string, call func(NodeServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(Req)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(NodeServer), ctx, in)
+			}
+
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + serviceName + "/" + method,
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return call(srv.(NodeServer), ctx, req.(*Req))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+var nodeServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("SubmitTransaction", NodeServer.SubmitTransaction),
+		unaryHandler("GetBlock", NodeServer.GetBlock),
+		unaryHandler("GetTransaction", NodeServer.GetTransaction),
+		unaryHandler("GetElection", NodeServer.GetElection),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "SubscribeBlocks",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				in := new(SubscribeBlocksRequest)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(NodeServer).SubscribeBlocks(in, &blockStream{stream})
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "SubscribeVotingEvents",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				in := new(SubscribeVotingEventsRequest)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(NodeServer).SubscribeVotingEvents(in, &votingEventStream{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bockvote.proto",
+}
+
+// NodeClient is a client for the Node service.
+type NodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeClient(cc grpc.ClientConnInterface) *NodeClient {
+	return &NodeClient{
+		cc: cc,
+	}
+}
+
+func (c *NodeClient) invoke(ctx context.Context, method string, in, out Message, opts []grpc.CallOption) error {
+	opts = append(opts, grpc.ForceCodec(codec{}))
+	return c.cc.Invoke(ctx, "/"+serviceName+"/"+method, in, out, opts...)
+}
+
+func (c *NodeClient) SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*SubmitTransactionResponse, error) {
+	out := new(SubmitTransactionResponse)
+	return out, c.invoke(ctx, "SubmitTransaction", in, out, opts)
+}
+
+func (c *NodeClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	return out, c.invoke(ctx, "GetBlock", in, out, opts)
+}
+
+func (c *NodeClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	return out, c.invoke(ctx, "GetTransaction", in, out, opts)
+}
+
+func (c *NodeClient) GetElection(ctx context.Context, in *GetElectionRequest, opts ...grpc.CallOption) (*Election, error) {
+	out := new(Election)
+	return out, c.invoke(ctx, "GetElection", in, out, opts)
+}
+
+func (c *NodeClient) openStream(ctx context.Context, i int, in Message, opts []grpc.CallOption) (grpc.ClientStream, error) {
+	desc := &nodeServiceDesc.Streams[i]
+	opts = append(opts, grpc.ForceCodec(codec{}))
+
+	stream, err := c.cc.NewStream(ctx, desc, "/"+serviceName+"/"+desc.StreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// BlockReceiver receives the blocks of a SubscribeBlocks stream.
+type BlockReceiver struct {
+	grpc.ClientStream
+}
+
+func (r *BlockReceiver) Recv() (*Block, error) {
+	b := new(Block)
+	return b, r.ClientStream.RecvMsg(b)
+}
+
+func (c *NodeClient) SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (*BlockReceiver, error) {
+	stream, err := c.openStream(ctx, 0, in, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockReceiver{stream}, nil
+}
+
+// VotingEventReceiver receives the events of a SubscribeVotingEvents stream.
+type VotingEventReceiver struct {
+	grpc.ClientStream
+}
+
+func (r *VotingEventReceiver) Recv() (*VotingEvent, error) {
+	ev := new(VotingEvent)
+	return ev, r.ClientStream.RecvMsg(ev)
+}
+
+func (c *NodeClient) SubscribeVotingEvents(ctx context.Context, in *SubscribeVotingEventsRequest, opts ...grpc.CallOption) (*VotingEventReceiver, error) {
+	stream, err := c.openStream(ctx, 1, in, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &VotingEventReceiver{stream}, nil
+}
@@ -15,36 +15,41 @@ import (
 )
 
 func main() {
+	genesis, err := core.LoadGenesis("genesis.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	validatorPrivKey := crypto.GeneratePrivateKey()
-	localNode := makeServer("LOCAL_NODE", &validatorPrivKey, ":3000", []string{":4000"}, ":9000")
+	localNode := makeServer("LOCAL_NODE", genesis, &validatorPrivKey, ":3000", []string{":4000"}, ":9000")
 	go localNode.Start()
 
-	remoteNode := makeServer("REMOTE_NODE", nil, ":4000", []string{":5000"}, "")
+	remoteNode := makeServer("REMOTE_NODE", genesis, nil, ":4000", []string{":5000"}, "")
 	go remoteNode.Start()
 
-	remoteNodeB := makeServer("REMOTE_NODE_B", nil, ":5000", nil, "")
+	remoteNodeB := makeServer("REMOTE_NODE_B", genesis, nil, ":5000", nil, "")
 	go remoteNodeB.Start()
 
 	go func() {
 		time.Sleep(11 * time.Second)
 
-		lateNode := makeServer("LATE_NODE", nil, ":6000", []string{":4000"}, "")
+		lateNode := makeServer("LATE_NODE", genesis, nil, ":6000", []string{":4000"}, "")
 		go lateNode.Start()
 	}()
 
 	time.Sleep(1 * time.Second)
 
-	// if err := sendTransaction(validatorPrivKey); err != nil {
+	// if err := sendTransaction(genesis.ChainID, validatorPrivKey); err != nil {
 	// 	panic(err)
 	// }
 
 	// collectionOwnerPrivKey := crypto.GeneratePrivateKey()
-	// collectionHash := createCollectionTx(collectionOwnerPrivKey)
+	// collectionHash := createCollectionTx(genesis.ChainID, collectionOwnerPrivKey)
 
 	// txSendTicker := time.NewTicker(1 * time.Second)
 	// go func() {
 	// 	for i := 0; i < 20; i++ {
-	// 		nftMinter(collectionOwnerPrivKey, collectionHash)
+	// 		nftMinter(genesis.ChainID, collectionOwnerPrivKey, collectionHash)
 
 	// 		<-txSendTicker.C
 	// 	}
@@ -53,12 +58,13 @@ func main() {
 	select {}
 }
 
-func sendTransaction(privKey crypto.PrivateKey) error {
+func sendTransaction(chainID string, privKey crypto.PrivateKey) error {
 	toPrivKey := crypto.GeneratePrivateKey()
 
 	tx := core.NewTransaction(nil)
 	tx.To = toPrivKey.PublicKey()
 	tx.Value = 666
+	tx.ChainID = chainID
 
 	if err := tx.Sign(privKey); err != nil {
 		return err
@@ -80,13 +86,16 @@ func sendTransaction(privKey crypto.PrivateKey) error {
 	return err
 }
 
-func makeServer(id string, pk *crypto.PrivateKey, addr string, seedNodes []string, apiListenAddr string) *network.Server {
+func makeServer(id string, genesis *core.Genesis, pk *crypto.PrivateKey, addr string, seedNodes []string, apiListenAddr string) *network.Server {
 	opts := network.ServerOpts{
 		APIListenAddr: apiListenAddr,
 		SeedNodes:     seedNodes,
 		ListenAddr:    addr,
 		PrivateKey:    pk,
 		ID:            id,
+		Genesis:       genesis,
+		// Peers talk over mutual TLS.
+		EncryptTransport: true,
 	}
 
 	s, err := network.NewServer(opts)
@@ -97,12 +106,13 @@ func makeServer(id string, pk *crypto.PrivateKey, addr string, seedNodes []strin
 	return s
 }
 
-func createCollectionTx(privKey crypto.PrivateKey) types.Hash {
+func createCollectionTx(chainID string, privKey crypto.PrivateKey) types.Hash {
 	tx := core.NewTransaction(nil)
 	tx.TxInner = core.CollectionTx{
 		Fee:      200,
 		MetaData: []byte("chicken and egg collection!"),
 	}
+	tx.ChainID = chainID
 	tx.Sign(privKey)
 
 	buf := &bytes.Buffer{}
@@ -124,7 +134,7 @@ func createCollectionTx(privKey crypto.PrivateKey) types.Hash {
 	return tx.Hash(core.TxHasher{})
 }
 
-func nftMinter(privKey crypto.PrivateKey, collection types.Hash) {
+func nftMinter(chainID string, privKey crypto.PrivateKey, collection types.Hash) {
 	metaData := map[string]any{
 		"power":  8,
 		"health": 100,
@@ -145,6 +155,7 @@ func nftMinter(privKey crypto.PrivateKey, collection types.Hash) {
 		Collection:      collection,
 		CollectionOwner: privKey.PublicKey(),
 	}
+	tx.ChainID = chainID
 	tx.Sign(privKey)
 
 	buf := &bytes.Buffer{}
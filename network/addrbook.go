@@ -0,0 +1,240 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Upper bound of addresses in the address book, the worst ones are
+	// evicted first.
+	maxAddrBookSize = 1000
+	maxAddrScore    = 100
+	// Addresses are forgotten after this many more failed than successful
+	// connection attempts.
+	minAddrScore = -5
+)
+
+// KnownAddr is an address in the address book.
+type KnownAddr struct {
+	Addr string `json:"addr"`
+	// ID of the node behind the address, empty if we never talked to it.
+	ID PeerID `json:"id,omitempty"`
+	// Score goes up with every successful and down with every failed
+	// connection attempt.
+	Score       int       `json:"score"`
+	LastSeen    time.Time `json:"lastSeen"`
+	LastAttempt time.Time `json:"lastAttempt"`
+}
+
+// AddrBook keeps the addresses of the nodes we know about, it is persisted
+// so a restarted node does not only depend on its seeds.
+type AddrBook struct {
+	mu sync.Mutex
+	// If empty the address book is not persisted.
+	path  string
+	addrs map[string]*KnownAddr
+}
+
+// NewAddrBook returns the address book persisted at path, a missing file
+// results in an empty address book.
+func NewAddrBook(path string) (*AddrBook, error) {
+	book := &AddrBook{
+		path:  path,
+		addrs: make(map[string]*KnownAddr),
+	}
+	if len(path) == 0 {
+		return book, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []*KnownAddr{}
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		book.addrs[addr.Addr] = addr
+	}
+
+	return book, nil
+}
+
+// Save persists the address book.
+func (b *AddrBook) Save() error {
+	if len(b.path) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.Addrs(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, b.path)
+}
+
+// Add adds a new address, the ID is only set if it is not known yet.
+func (b *AddrBook) Add(addr string, id PeerID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if known, ok := b.addrs[addr]; ok {
+		if len(known.ID) == 0 {
+			known.ID = id
+		}
+		return
+	}
+
+	if len(b.addrs) >= maxAddrBookSize {
+		b.evictWorst()
+	}
+	b.addrs[addr] = &KnownAddr{
+		Addr: addr,
+		ID:   id,
+	}
+}
+
+// Remove forgets about an address.
+func (b *AddrBook) Remove(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.addrs, addr)
+}
+
+// MarkAttempt records a connection attempt to the address.
+func (b *AddrBook) MarkAttempt(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if known, ok := b.addrs[addr]; ok {
+		known.LastAttempt = time.Now()
+	}
+}
+
+// MarkGood records that the node with the given ID is reachable at addr.
+func (b *AddrBook) MarkGood(addr string, id PeerID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		if len(b.addrs) >= maxAddrBookSize {
+			b.evictWorst()
+		}
+		known = &KnownAddr{Addr: addr}
+		b.addrs[addr] = known
+	}
+
+	known.ID = id
+	known.LastSeen = time.Now()
+	if known.Score < maxAddrScore {
+		known.Score++
+	}
+}
+
+// MarkFailed records a failed connection attempt to addr, addresses that
+// keep failing are removed.
+func (b *AddrBook) MarkFailed(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return
+	}
+
+	known.Score--
+	if known.Score < minAddrScore {
+		delete(b.addrs, addr)
+	}
+}
+
+// Get returns the known address.
+func (b *AddrBook) Get(addr string) (KnownAddr, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	known, ok := b.addrs[addr]
+	if !ok {
+		return KnownAddr{}, false
+	}
+
+	return *known, true
+}
+
+// Addrs returns all known addresses, the best scored ones first.
+func (b *AddrBook) Addrs() []KnownAddr {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	addrs := make([]KnownAddr, 0, len(b.addrs))
+	for _, known := range b.addrs {
+		addrs = append(addrs, *known)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Score != addrs[j].Score {
+			return addrs[i].Score > addrs[j].Score
+		}
+		return addrs[i].Addr < addrs[j].Addr
+	})
+
+	return addrs
+}
+
+// Candidates returns up to n of the best scored addresses to connect to.
+// Addresses for which skip returns true are left out.
+func (b *AddrBook) Candidates(n int, skip func(KnownAddr) bool) []string {
+	addrs := []string{}
+	for _, known := range b.Addrs() {
+		if len(addrs) == n {
+			break
+		}
+		if skip(known) {
+			continue
+		}
+
+		addrs = append(addrs, known.Addr)
+	}
+
+	return addrs
+}
+
+// Len returns the number of known addresses.
+func (b *AddrBook) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.addrs)
+}
+
+func (b *AddrBook) evictWorst() {
+	var worst *KnownAddr
+	for _, known := range b.addrs {
+		if worst == nil || known.Score < worst.Score {
+			worst = known
+		}
+	}
+
+	if worst != nil {
+		delete(b.addrs, worst.Addr)
+	}
+}
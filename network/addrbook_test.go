@@ -0,0 +1,84 @@
+package network
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddrBookScoring(t *testing.T) {
+	book, err := NewAddrBook("")
+	assert.Nil(t, err)
+
+	book.Add("127.0.0.1:3000", "")
+	book.Add("127.0.0.1:4000", "b")
+	book.Add("127.0.0.1:5000", "c")
+	assert.Equal(t, 3, book.Len())
+
+	book.MarkGood("127.0.0.1:5000", "c")
+	book.MarkGood("127.0.0.1:5000", "c")
+	book.MarkGood("127.0.0.1:3000", "a")
+	book.MarkFailed("127.0.0.1:4000")
+
+	skipNone := func(KnownAddr) bool { return false }
+	assert.Equal(t, []string{"127.0.0.1:5000", "127.0.0.1:3000"}, book.Candidates(2, skipNone))
+	assert.Equal(t, []string{"127.0.0.1:3000", "127.0.0.1:4000"}, book.Candidates(3, func(known KnownAddr) bool {
+		return known.ID == "c"
+	}))
+
+	known, ok := book.Get("127.0.0.1:3000")
+	assert.True(t, ok)
+	assert.Equal(t, PeerID("a"), known.ID)
+	assert.False(t, known.LastSeen.IsZero())
+
+	// Adding a known address again does not reset it.
+	book.Add("127.0.0.1:5000", "")
+	known, _ = book.Get("127.0.0.1:5000")
+	assert.Equal(t, 2, known.Score)
+
+	// Addresses that keep failing are forgotten.
+	for i := 0; i < -minAddrScore; i++ {
+		book.MarkFailed("127.0.0.1:4000")
+	}
+	_, ok = book.Get("127.0.0.1:4000")
+	assert.False(t, ok)
+	assert.Equal(t, 2, book.Len())
+}
+
+func TestAddrBookEvictsWorst(t *testing.T) {
+	book, err := NewAddrBook("")
+	assert.Nil(t, err)
+
+	book.Add("bad:1", "")
+	book.MarkFailed("bad:1")
+	for i := 0; i < maxAddrBookSize; i++ {
+		book.Add(fmt.Sprintf("127.0.0.1:%d", 3000+i), "")
+	}
+
+	assert.Equal(t, maxAddrBookSize, book.Len())
+	_, ok := book.Get("bad:1")
+	assert.False(t, ok)
+}
+
+func TestAddrBookPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+
+	book, err := NewAddrBook(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, book.Len())
+
+	book.Add("127.0.0.1:3000", "a")
+	book.MarkGood("127.0.0.1:4000", "b")
+	assert.Nil(t, book.Save())
+
+	loaded, err := NewAddrBook(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, loaded.Len())
+
+	known, ok := loaded.Get("127.0.0.1:4000")
+	assert.True(t, ok)
+	assert.Equal(t, PeerID("b"), known.ID)
+	assert.Equal(t, 1, known.Score)
+}
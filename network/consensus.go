@@ -0,0 +1,588 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/go-kit/log"
+)
+
+// Upper bound of buffered messages for the next height.
+const maxFutureMessages = 1024
+
+var (
+	defaultTimeoutPropose   = 3 * time.Second
+	defaultTimeoutPrevote   = time.Second
+	defaultTimeoutPrecommit = time.Second
+)
+
+// ConsensusConfig holds the timeouts of the consensus steps. Every failed
+// round increases the propose, prevote and precommit timeouts by half of
+// their configured value, so a network with slow validators still makes
+// progress.
+type ConsensusConfig struct {
+	TimeoutPropose   time.Duration
+	TimeoutPrevote   time.Duration
+	TimeoutPrecommit time.Duration
+	// Time to wait after a commit before the next height is started.
+	// Defaults to the block time of the server.
+	TimeoutCommit time.Duration
+}
+
+type ConsensusOpts struct {
+	ConsensusConfig
+	Logger     log.Logger
+	Chain      *core.Blockchain
+	Mempool    *TxPool
+	PrivateKey crypto.PrivateKey
+	Codec      core.Codec
+	// Broadcast sends an encoded message to all peers.
+	Broadcast func([]byte) error
+}
+
+type roundStep byte
+
+const (
+	stepPropose roundStep = iota
+	stepPrevote
+	stepPrecommit
+	stepCommit
+)
+
+// voteSet holds the votes of a single type in a round.
+type voteSet struct {
+	votes  map[string]*core.Vote
+	counts map[types.Hash]int
+}
+
+func newVoteSet() *voteSet {
+	return &voteSet{
+		votes:  make(map[string]*core.Vote),
+		counts: make(map[types.Hash]int),
+	}
+}
+
+// add returns false if the validator already voted.
+func (s *voteSet) add(v *core.Vote) bool {
+	if _, ok := s.votes[string(v.Validator)]; ok {
+		return false
+	}
+
+	s.votes[string(v.Validator)] = v
+	s.counts[v.BlockHash]++
+
+	return true
+}
+
+// majority returns the block hash that at least quorum validators voted for.
+func (s *voteSet) majority(quorum int) (types.Hash, bool) {
+	for hash, n := range s.counts {
+		if n >= quorum {
+			return hash, true
+		}
+	}
+
+	return types.Hash{}, false
+}
+
+func (s *voteSet) votesFor(hash types.Hash) []*core.Vote {
+	votes := []*core.Vote{}
+	for _, v := range s.votes {
+		if v.BlockHash == hash {
+			votes = append(votes, v)
+		}
+	}
+
+	return votes
+}
+
+func (s *voteSet) len() int {
+	return len(s.votes)
+}
+
+type roundVotes struct {
+	prevotes   *voteSet
+	precommits *voteSet
+
+	prevoteTimeout   bool
+	precommitTimeout bool
+}
+
+// voters returns the number of distinct validators that voted in the round.
+func (rv *roundVotes) voters() int {
+	n := rv.prevotes.len()
+	for key := range rv.precommits.votes {
+		if _, ok := rv.prevotes.votes[key]; !ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Consensus is a Tendermint style BFT engine. For every height the
+// validators run rounds of propose, prevote and precommit until 2/3+ of them
+// precommit the same block, which is then added to the chain together with
+// the commit certificate. A validator that precommits a block locks on it
+// and will only prevote for it in later rounds, until 2/3+ prevote for
+// something else.
+type Consensus struct {
+	ConsensusOpts
+
+	validator *core.BlockValidator
+
+	mu          sync.Mutex
+	height      uint32
+	round       uint32
+	step        roundStep
+	proposals   map[uint32]*core.Block
+	votes       map[uint32]*roundVotes
+	lockedBlock *core.Block
+	lockedRound uint32
+	// Messages for the next height that arrived before the current height
+	// was committed.
+	future  []any
+	stopped bool
+}
+
+func NewConsensus(opts ConsensusOpts) *Consensus {
+	if opts.TimeoutPropose == 0 {
+		opts.TimeoutPropose = defaultTimeoutPropose
+	}
+	if opts.TimeoutPrevote == 0 {
+		opts.TimeoutPrevote = defaultTimeoutPrevote
+	}
+	if opts.TimeoutPrecommit == 0 {
+		opts.TimeoutPrecommit = defaultTimeoutPrecommit
+	}
+	if opts.TimeoutCommit == 0 {
+		opts.TimeoutCommit = defaultBlockTime
+	}
+	if opts.Codec == nil {
+		opts.Codec = core.GobCodec{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.NewNopLogger()
+	}
+
+	return &Consensus{
+		ConsensusOpts: opts,
+		validator:     core.NewBlockValidator(opts.Chain),
+	}
+}
+
+// Start enters the next height of the chain.
+func (c *Consensus) Start() {
+	// Blocks with a valid commit can also be received from peers, in that
+	// case we move on to the next height. The handler is called while the
+	// chain is locked, hence the goroutine.
+	c.Chain.OnBlock(func(*core.Block) {
+		go c.syncHeight()
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Logger.Log("msg", "starting consensus", "validator", c.PrivateKey.PublicKey())
+
+	c.enterNewHeight()
+}
+
+func (c *Consensus) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+}
+
+// Height returns the height and round the consensus is currently in.
+func (c *Consensus) Height() (uint32, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.height, c.round
+}
+
+func (c *Consensus) HandleProposal(msg *ProposalMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		return nil
+	}
+
+	return c.handleProposal(msg)
+}
+
+func (c *Consensus) HandleVote(v *core.Vote) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		return nil
+	}
+
+	return c.addVote(v)
+}
+
+func (c *Consensus) syncHeight() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped || c.step == stepCommit {
+		return
+	}
+
+	if c.Chain.Height() >= c.height {
+		c.enterNewHeight()
+	}
+}
+
+func (c *Consensus) enterNewHeight() {
+	c.height = c.Chain.Height() + 1
+	c.proposals = make(map[uint32]*core.Block)
+	c.votes = make(map[uint32]*roundVotes)
+	c.lockedBlock = nil
+	c.lockedRound = 0
+
+	c.enterNewRound(0)
+
+	future := c.future
+	c.future = nil
+	for _, msg := range future {
+		switch t := msg.(type) {
+		case *ProposalMessage:
+			c.handleProposal(t)
+		case *core.Vote:
+			c.addVote(t)
+		}
+	}
+}
+
+func (c *Consensus) enterNewRound(round uint32) {
+	c.round = round
+	c.step = stepPropose
+
+	c.Logger.Log("msg", "entering new round", "height", c.height, "round", round)
+
+	vs := c.Chain.ValidatorSetAt(c.height)
+	if vs.IsProposer(c.PrivateKey.PublicKey(), c.height, round) {
+		if err := c.propose(); err != nil {
+			c.Logger.Log("msg", "failed to propose block", "err", err)
+		}
+	}
+
+	c.scheduleTimeout(c.timeout(c.TimeoutPropose), stepPropose)
+
+	// Votes for this round might have arrived before we entered it.
+	c.checkVotes(round)
+}
+
+func (c *Consensus) propose() error {
+	var block *core.Block
+
+	if c.lockedBlock != nil {
+		// Propose the block we are locked on again. The block hash only
+		// covers the header, so signing it as the new proposer keeps the
+		// hash the same.
+		b := *c.lockedBlock
+		b.Commit = nil
+		block = &b
+	} else {
+		prevHeader, err := c.Chain.GetHeader(c.height - 1)
+		if err != nil {
+			return err
+		}
+
+		// The transactions are selected with the time of the block, so they
+		// execute the same when the block is validated.
+		timestamp := time.Now().UnixNano()
+		block, err = core.NewBlockFromPrevHeader(prevHeader, c.Chain.ValidTransactions(c.Mempool.Pending(), timestamp))
+		if err != nil {
+			return err
+		}
+		block.Timestamp = timestamp
+	}
+
+	block.ChainID = c.Chain.ChainID()
+	if err := block.Sign(c.PrivateKey); err != nil {
+		return err
+	}
+
+	msg := &ProposalMessage{
+		Round: c.round,
+		Block: block,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := msg.Encode(c.Codec, buf); err != nil {
+		return err
+	}
+
+	if err := c.Broadcast(NewMessage(MessageTypeProposal, buf.Bytes()).Bytes()); err != nil {
+		c.Logger.Log("msg", "failed to broadcast proposal", "err", err)
+	}
+
+	return c.handleProposal(msg)
+}
+
+func (c *Consensus) handleProposal(msg *ProposalMessage) error {
+	b := msg.Block
+
+	if b.Height == c.height+1 {
+		c.bufferFuture(msg)
+		return nil
+	}
+	if b.Height != c.height || c.step == stepCommit {
+		return nil
+	}
+	if _, ok := c.proposals[msg.Round]; ok {
+		return nil
+	}
+
+	if err := c.validator.ValidateProposal(b, msg.Round); err != nil {
+		return err
+	}
+
+	c.proposals[msg.Round] = b
+
+	if msg.Round == c.round && c.step == stepPropose {
+		c.prevote(b)
+	}
+
+	c.checkVotes(msg.Round)
+
+	return nil
+}
+
+func (c *Consensus) prevote(b *core.Block) {
+	hash := types.Hash{}
+	if b != nil {
+		// Only vote for a different block than the one we are locked on if
+		// 2/3+ of the validators unlocked us.
+		if c.lockedBlock == nil || c.lockedBlock.Hash(core.BlockHasher{}) == b.Hash(core.BlockHasher{}) {
+			hash = b.Hash(core.BlockHasher{})
+		}
+	}
+
+	c.step = stepPrevote
+	c.sendVote(core.VoteTypePrevote, hash)
+}
+
+func (c *Consensus) precommit(hash types.Hash) {
+	c.step = stepPrecommit
+	c.sendVote(core.VoteTypePrecommit, hash)
+}
+
+func (c *Consensus) sendVote(t core.VoteType, hash types.Hash) {
+	// Nodes that are not in the validator set of this height follow the
+	// consensus without voting.
+	if !c.Chain.ValidatorSetAt(c.height).Contains(c.PrivateKey.PublicKey()) {
+		return
+	}
+
+	v := &core.Vote{
+		Type:      t,
+		Height:    c.height,
+		Round:     c.round,
+		BlockHash: hash,
+	}
+	if err := v.Sign(c.PrivateKey, c.Chain.ChainID()); err != nil {
+		c.Logger.Log("msg", "failed to sign vote", "err", err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		c.Logger.Log("msg", "failed to encode vote", "err", err)
+		return
+	}
+
+	if err := c.Broadcast(NewMessage(MessageTypeVote, buf.Bytes()).Bytes()); err != nil {
+		c.Logger.Log("msg", "failed to broadcast vote", "err", err)
+	}
+
+	c.addVote(v)
+}
+
+func (c *Consensus) addVote(v *core.Vote) error {
+	if v.Height == c.height+1 {
+		c.bufferFuture(v)
+		return nil
+	}
+	if v.Height != c.height || c.step == stepCommit {
+		return nil
+	}
+
+	vs := c.Chain.ValidatorSetAt(c.height)
+	if !vs.Contains(v.Validator) {
+		return core.ErrUnknownValidator
+	}
+	if err := v.Verify(c.Chain.ChainID()); err != nil {
+		return err
+	}
+
+	rv := c.roundVotes(v.Round)
+
+	var added bool
+	switch v.Type {
+	case core.VoteTypePrevote:
+		added = rv.prevotes.add(v)
+	case core.VoteTypePrecommit:
+		added = rv.precommits.add(v)
+	}
+	if !added {
+		return nil
+	}
+
+	// Skip ahead if enough validators are in a later round that at least
+	// one of them is honest.
+	if v.Round > c.round && rv.voters() > vs.Len()-vs.QuorumSize() {
+		c.enterNewRound(v.Round)
+		return nil
+	}
+
+	c.checkVotes(v.Round)
+
+	return nil
+}
+
+func (c *Consensus) bufferFuture(msg any) {
+	if len(c.future) < maxFutureMessages {
+		c.future = append(c.future, msg)
+	}
+}
+
+func (c *Consensus) roundVotes(round uint32) *roundVotes {
+	rv, ok := c.votes[round]
+	if !ok {
+		rv = &roundVotes{
+			prevotes:   newVoteSet(),
+			precommits: newVoteSet(),
+		}
+		c.votes[round] = rv
+	}
+
+	return rv
+}
+
+// checkVotes moves the consensus forward based on the votes of the given
+// round.
+func (c *Consensus) checkVotes(round uint32) {
+	rv, ok := c.votes[round]
+	if !ok || c.step == stepCommit {
+		return
+	}
+
+	quorum := c.Chain.ValidatorSetAt(c.height).QuorumSize()
+
+	// A block that 2/3+ precommitted is committed, whatever round we are in.
+	if hash, ok := rv.precommits.majority(quorum); ok {
+		if !hash.IsZero() {
+			if b, ok := c.proposals[round]; ok && b.Hash(core.BlockHasher{}) == hash {
+				c.commit(b, rv.precommits.votesFor(hash))
+			}
+			return
+		}
+
+		if round == c.round {
+			c.enterNewRound(round + 1)
+			return
+		}
+	}
+
+	if round != c.round {
+		return
+	}
+
+	if c.step == stepPrevote {
+		if hash, ok := rv.prevotes.majority(quorum); ok {
+			if hash.IsZero() {
+				c.lockedBlock = nil
+				c.precommit(hash)
+			} else if b, ok := c.proposals[round]; ok && b.Hash(core.BlockHasher{}) == hash {
+				c.lockedBlock = b
+				c.lockedRound = round
+				c.precommit(hash)
+			}
+		}
+	}
+
+	if c.step == stepPrevote && rv.prevotes.len() >= quorum && !rv.prevoteTimeout {
+		rv.prevoteTimeout = true
+		c.scheduleTimeout(c.timeout(c.TimeoutPrevote), stepPrevote)
+	}
+
+	if c.step == stepPrecommit && rv.precommits.len() >= quorum && !rv.precommitTimeout {
+		rv.precommitTimeout = true
+		c.scheduleTimeout(c.timeout(c.TimeoutPrecommit), stepPrecommit)
+	}
+}
+
+func (c *Consensus) commit(b *core.Block, precommits []*core.Vote) {
+	c.step = stepCommit
+	b.Commit = core.NewCommit(precommits)
+
+	c.Logger.Log(
+		"msg", "committing block",
+		"height", c.height,
+		"round", c.round,
+		"hash", b.Hash(core.BlockHasher{}),
+		"signatures", len(precommits),
+	)
+
+	if err := c.Chain.AddBlock(b); err != nil && err != core.ErrBlockKnown {
+		c.Logger.Log("msg", "failed to add committed block", "err", err)
+	}
+
+	// The proposer makes the committed block known to the rest of the
+	// network.
+	if bytes.Equal(b.Validator, c.PrivateKey.PublicKey()) {
+		buf := &bytes.Buffer{}
+		if err := b.Encode(c.Codec.NewBlockEncoder(buf)); err != nil {
+			c.Logger.Log("msg", "failed to encode block", "err", err)
+		} else if err := c.Broadcast(NewMessage(MessageTypeBlock, buf.Bytes()).Bytes()); err != nil {
+			c.Logger.Log("msg", "failed to broadcast block", "err", err)
+		}
+	}
+
+	c.scheduleTimeout(c.TimeoutCommit, stepCommit)
+}
+
+// timeout returns the timeout for the current round.
+func (c *Consensus) timeout(base time.Duration) time.Duration {
+	return base + base*time.Duration(c.round)/2
+}
+
+func (c *Consensus) scheduleTimeout(d time.Duration, step roundStep) {
+	height, round := c.height, c.round
+
+	time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.onTimeout(height, round, step)
+	})
+}
+
+func (c *Consensus) onTimeout(height, round uint32, step roundStep) {
+	if c.stopped || height != c.height || round != c.round || step != c.step {
+		return
+	}
+
+	switch step {
+	case stepPropose:
+		c.prevote(nil)
+	case stepPrevote:
+		c.precommit(types.Hash{})
+	case stepPrecommit:
+		c.enterNewRound(round + 1)
+	case stepCommit:
+		c.enterNewHeight()
+	}
+}
@@ -0,0 +1,235 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConsensusConfig = ConsensusConfig{
+	TimeoutPropose:   300 * time.Millisecond,
+	TimeoutPrevote:   100 * time.Millisecond,
+	TimeoutPrecommit: 100 * time.Millisecond,
+	TimeoutCommit:    20 * time.Millisecond,
+}
+
+type testConsensusNode struct {
+	chain     *core.Blockchain
+	consensus *Consensus
+	tr        *LocalTransport
+	quitCh    chan struct{}
+}
+
+func (n *testConsensusNode) start(t *testing.T) {
+	decode := NewRPCDecodeFunc(core.GobCodec{})
+
+	go func() {
+		for {
+			select {
+			case rpc := <-n.tr.Consume():
+				msg, err := decode(rpc)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				switch data := msg.Data.(type) {
+				case *ProposalMessage:
+					n.consensus.HandleProposal(data)
+				case *core.Vote:
+					n.consensus.HandleVote(data)
+				case *core.Block:
+					n.chain.AddBlock(data)
+				}
+			case <-n.quitCh:
+				return
+			}
+		}
+	}()
+
+	n.consensus.Start()
+}
+
+func (n *testConsensusNode) stop() {
+	n.consensus.Stop()
+	close(n.quitCh)
+}
+
+// newTestConsensusNetwork creates a fully connected network of validators.
+// The offline validators are part of the validator set but never run.
+func newTestConsensusNetwork(t *testing.T, numValidators int, offline ...int) ([]*testConsensusNode, []crypto.PublicKey) {
+	privKeys := make([]crypto.PrivateKey, numValidators)
+	validators := make([]crypto.PublicKey, numValidators)
+	for i := range privKeys {
+		privKeys[i] = crypto.GeneratePrivateKey()
+		validators[i] = privKeys[i].PublicKey()
+	}
+
+	isOffline := make(map[int]bool)
+	for _, i := range offline {
+		isOffline[i] = true
+	}
+
+	nodes := []*testConsensusNode{}
+	for i := range privKeys {
+		if isOffline[i] {
+			continue
+		}
+
+		chain, err := core.NewBlockchain(log.NewNopLogger(), &core.Genesis{ChainID: "test", Validators: validators})
+		assert.Nil(t, err)
+
+		tr := NewLocalTransport(NetAddr(fmt.Sprintf("VALIDATOR_%d", i)))
+		nodes = append(nodes, &testConsensusNode{
+			chain: chain,
+			tr:    tr,
+			consensus: NewConsensus(ConsensusOpts{
+				ConsensusConfig: testConsensusConfig,
+				Logger:          log.NewNopLogger(),
+				Chain:           chain,
+				Mempool:         NewTxPool(100),
+				PrivateKey:      privKeys[i],
+				Broadcast:       tr.Broadcast,
+			}),
+			quitCh: make(chan struct{}),
+		})
+	}
+
+	for _, a := range nodes {
+		for _, b := range nodes {
+			if a != b {
+				assert.Nil(t, a.tr.Connect(b.tr))
+			}
+		}
+	}
+
+	return nodes, validators
+}
+
+func waitForHeight(t *testing.T, nodes []*testConsensusNode, height uint32) {
+	assert.Eventually(t, func() bool {
+		for _, n := range nodes {
+			if n.chain.Height() < height {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func assertSameChain(t *testing.T, nodes []*testConsensusNode, validators []crypto.PublicKey, height uint32) {
+	vs := core.NewValidatorSet(validators...)
+
+	for h := uint32(1); h <= height; h++ {
+		block, err := nodes[0].chain.GetBlock(h)
+		assert.Nil(t, err)
+		assert.NotNil(t, block.Commit)
+		assert.Nil(t, block.Commit.Verify(vs, "test"))
+		assert.Equal(t, vs.Proposer(h, block.Commit.Round), block.Validator)
+
+		for _, n := range nodes[1:] {
+			other, err := n.chain.GetBlock(h)
+			assert.Nil(t, err)
+			assert.Equal(t, block.Hash(core.BlockHasher{}), other.Hash(core.BlockHasher{}))
+		}
+	}
+}
+
+func TestConsensusValidatorsTakeTurns(t *testing.T) {
+	nodes, validators := newTestConsensusNetwork(t, 4)
+	for _, n := range nodes {
+		n.start(t)
+		defer n.stop()
+	}
+
+	height := uint32(8)
+	waitForHeight(t, nodes, height)
+	assertSameChain(t, nodes, validators, height)
+
+	// Without failures every block is committed in the first round by the
+	// validator whose turn it is.
+	for h := uint32(1); h <= height; h++ {
+		block, err := nodes[0].chain.GetBlock(h)
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(0), block.Commit.Round)
+		assert.Equal(t, validators[h%4], block.Validator)
+	}
+}
+
+func TestConsensusRoundChangeWithOfflineValidator(t *testing.T) {
+	// One of four validators is down, the remaining three are still 2/3+.
+	nodes, validators := newTestConsensusNetwork(t, 4, 3)
+	for _, n := range nodes {
+		n.start(t)
+		defer n.stop()
+	}
+
+	height := uint32(6)
+	waitForHeight(t, nodes, height)
+	assertSameChain(t, nodes, validators, height)
+
+	// Height 3 is the turn of the offline validator.
+	block, err := nodes[0].chain.GetBlock(3)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), block.Commit.Round)
+	assert.Equal(t, validators[0], block.Validator)
+}
+
+func TestConsensusNoProgressWithoutQuorum(t *testing.T) {
+	// Two of four validators can never commit a block.
+	nodes, _ := newTestConsensusNetwork(t, 4, 2, 3)
+	for _, n := range nodes {
+		n.start(t)
+		defer n.stop()
+	}
+
+	time.Sleep(time.Second)
+
+	for _, n := range nodes {
+		assert.Equal(t, uint32(0), n.chain.Height())
+	}
+}
+
+func TestServerRejectsBlockWithoutCommit(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+
+	s, err := NewServer(ServerOpts{
+		Logger:  log.NewNopLogger(),
+		Genesis: &core.Genesis{ChainID: "test", Validators: []crypto.PublicKey{privKey.PublicKey()}},
+	})
+	assert.Nil(t, err)
+
+	header, err := s.chain.GetHeader(0)
+	assert.Nil(t, err)
+	block, err := core.NewBlockFromPrevHeader(header, nil)
+	assert.Nil(t, err)
+	block.ChainID = "test"
+	assert.Nil(t, block.Sign(privKey))
+
+	assert.Equal(t, core.ErrMissingCommit, s.processBlock(block))
+}
+
+func TestServerRefusesStatusOfOtherChain(t *testing.T) {
+	s, err := NewServer(ServerOpts{
+		Logger:  log.NewNopLogger(),
+		Genesis: &core.Genesis{ChainID: "test"},
+	})
+	assert.Nil(t, err)
+	from := NetAddr("peer")
+
+	other := &core.Genesis{ChainID: "other"}
+	err = s.processStatusMessage(from, &StatusMessage{ChainID: other.ChainID, GenesisHash: other.Block().Hash(core.BlockHasher{})})
+	assert.ErrorIs(t, err, ErrChainMismatch)
+
+	// Same chain ID, but a different genesis.
+	other = &core.Genesis{ChainID: "test", Timestamp: 1}
+	err = s.processStatusMessage(from, &StatusMessage{ChainID: other.ChainID, GenesisHash: other.Block().Hash(core.BlockHasher{})})
+	assert.ErrorIs(t, err, ErrChainMismatch)
+
+	assert.Nil(t, s.processStatusMessage(from, &StatusMessage{ChainID: "test", GenesisHash: s.chain.GenesisHash()}))
+}
@@ -0,0 +1,264 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net"
+	"time"
+)
+
+// PeerConfig configures how many peers a node connects to and how it finds
+// them.
+type PeerConfig struct {
+	// Number of outgoing connections the node tries to keep, defaults
+	// to 8.
+	TargetOutbound int
+	// Maximum number of incoming connections, defaults to 16.
+	MaxInbound int
+	// File the address book is persisted in, if empty it is only kept in
+	// memory.
+	AddrBookPath string
+	// How often the node asks its peers for addresses and dials new ones
+	// while it has less than TargetOutbound outgoing connections, defaults
+	// to 10 seconds.
+	DiscoveryInterval time.Duration
+	// Delay before a seed is dialed again, it doubles with every failed
+	// attempt up to MaxReconnectDelay. Defaults to 1 second and 1 minute.
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	// Peers are banned for BanDuration once their misbehavior score
+	// reaches BanThreshold. Defaults to 100 and 1 hour.
+	BanThreshold int
+	BanDuration  time.Duration
+}
+
+func (cfg *PeerConfig) setDefaults() {
+	if cfg.TargetOutbound == 0 {
+		cfg.TargetOutbound = 8
+	}
+	if cfg.MaxInbound == 0 {
+		cfg.MaxInbound = 16
+	}
+	if cfg.DiscoveryInterval == 0 {
+		cfg.DiscoveryInterval = 10 * time.Second
+	}
+	if cfg.ReconnectDelay == 0 {
+		cfg.ReconnectDelay = time.Second
+	}
+	if cfg.MaxReconnectDelay == 0 {
+		cfg.MaxReconnectDelay = time.Minute
+	}
+	if cfg.BanThreshold == 0 {
+		cfg.BanThreshold = 100
+	}
+	if cfg.BanDuration == 0 {
+		cfg.BanDuration = time.Hour
+	}
+}
+
+func (s *Server) discoveryLoop() {
+	ticker := time.NewTicker(s.Peers.DiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quitCh:
+			return
+		}
+
+		if s.needsPeers() {
+			if err := s.broadcastGetPeers(); err != nil {
+				s.Logger.Log("msg", "failed to request peers", "err", err)
+			}
+			s.dialPeers()
+		}
+
+		if err := s.addrBook.Save(); err != nil {
+			s.Logger.Log("msg", "failed to save address book", "err", err)
+		}
+	}
+}
+
+// needsPeers returns true if the node has less outgoing connections than
+// it should.
+func (s *Server) needsPeers() bool {
+	// Only TCP nodes find peers on their own.
+	if s.tcp == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.peerCount(true) < s.Peers.TargetOutbound
+}
+
+// peerCount returns the number of outgoing or incoming TCP peers.
+func (s *Server) peerCount(outgoing bool) int {
+	n := 0
+	for _, peer := range s.tcp.Peers() {
+		if peer.Outgoing == outgoing {
+			n++
+		}
+	}
+
+	return n
+}
+
+// dialPeers dials the best addresses of the address book until the target
+// of outgoing connections is reached.
+func (s *Server) dialPeers() {
+	s.mu.RLock()
+	n := s.Peers.TargetOutbound - s.peerCount(true) - len(s.dialing)
+	s.mu.RUnlock()
+
+	if n <= 0 {
+		return
+	}
+
+	for _, addr := range s.addrBook.Candidates(n, s.skipAddr) {
+		go s.dial(addr)
+	}
+}
+
+// skipAddr returns true for addresses we should not dial because they are
+// our own, banned, connected already or were tried recently.
+func (s *Server) skipAddr(known KnownAddr) bool {
+	if known.ID == s.nodeID() || known.Addr == s.listenAddr() {
+		return true
+	}
+	if time.Since(known.LastAttempt) < s.Peers.DiscoveryInterval {
+		return true
+	}
+	if s.isBanned(known.Addr) || (len(known.ID) > 0 && s.isBanned(string(known.ID))) {
+		return true
+	}
+
+	s.mu.RLock()
+	dialing := s.dialing[known.Addr]
+	s.mu.RUnlock()
+
+	return dialing || s.connectedTo(known.Addr)
+}
+
+func (s *Server) dial(addr string) {
+	s.mu.Lock()
+	if s.dialing[addr] {
+		s.mu.Unlock()
+		return
+	}
+	s.dialing[addr] = true
+	s.mu.Unlock()
+
+	s.addrBook.MarkAttempt(addr)
+
+	peer, err := s.tcp.Dial(addr)
+	if err != nil {
+		s.Logger.Log("msg", "could not connect to peer", "addr", addr, "err", err)
+		s.addrBook.MarkFailed(addr)
+		s.doneDialing(addr)
+		return
+	}
+
+	select {
+	case s.tcp.peerCh <- peer:
+	case <-s.quitCh:
+		peer.conn.Close()
+		s.doneDialing(addr)
+	}
+}
+
+func (s *Server) doneDialing(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.dialing, addr)
+}
+
+// listenAddr is the address the node accepts connections on.
+func (s *Server) listenAddr() string {
+	if addr := s.Transport.Addr(); addr != nil {
+		return addr.String()
+	}
+
+	return s.ListenAddr
+}
+
+func (s *Server) sendGetPeersMessage(peer *TCPPeer) error {
+	msg := NewMessage(MessageTypeGetPeers, nil)
+	return s.Transport.SendMessage(peer.ID, msg.Bytes())
+}
+
+func (s *Server) broadcastGetPeers() error {
+	msg := NewMessage(MessageTypeGetPeers, nil)
+	return s.Transport.Broadcast(msg.Bytes())
+}
+
+// processGetPeersMessage answers with the addresses of our peers followed
+// by the best addresses of the address book that were reachable before.
+func (s *Server) processGetPeersMessage(from net.Addr, data *GetPeersMessage) error {
+	s.Logger.Log("msg", "received getPeers message", "from", from)
+
+	peer, err := s.peer(from)
+	if err != nil {
+		return err
+	}
+
+	var (
+		peersMsg = &PeersMessage{}
+		seen     = map[string]bool{peer.advertisedAddr(): true}
+	)
+	add := func(id PeerID, addr string) {
+		if len(addr) == 0 || seen[addr] || len(peersMsg.Peers) == maxPeersPerMessage {
+			return
+		}
+
+		seen[addr] = true
+		peersMsg.Peers = append(peersMsg.Peers, PeerAddr{ID: id, Addr: addr})
+	}
+
+	for _, p := range s.tcp.Peers() {
+		if p.ID != peer.ID {
+			add(p.ID, p.advertisedAddr())
+		}
+	}
+
+	for _, known := range s.addrBook.Addrs() {
+		if known.Score > 0 && len(known.ID) > 0 && known.ID != peer.ID {
+			add(known.ID, known.Addr)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(peersMsg); err != nil {
+		return err
+	}
+
+	msg := NewMessage(MessageTypePeers, buf.Bytes())
+
+	return s.Transport.SendMessage(peer.ID, msg.Bytes())
+}
+
+// processPeersMessage adds the gossiped addresses to the address book and
+// dials them if we need more peers.
+func (s *Server) processPeersMessage(from net.Addr, data *PeersMessage) error {
+	s.Logger.Log("msg", "received peers message", "from", from, "count", len(data.Peers))
+
+	for _, p := range data.Peers {
+		if p.ID == s.nodeID() {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(p.Addr); err != nil || len(port) == 0 {
+			continue
+		}
+
+		s.addrBook.Add(p.Addr, p.ID)
+	}
+
+	if s.needsPeers() {
+		s.dialPeers()
+	}
+
+	return nil
+}
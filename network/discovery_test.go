@@ -0,0 +1,127 @@
+package network
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// freeAddr returns a loopback address that is free to listen on.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	return ln.Addr().String()
+}
+
+// startTestServer starts a server on a free loopback address.
+func startTestServer(t *testing.T, seeds []string, cfg PeerConfig) *Server {
+	s, err := NewServer(ServerOpts{
+		Logger:     log.NewNopLogger(),
+		ListenAddr: freeAddr(t),
+		SeedNodes:  seeds,
+		Genesis:    &core.Genesis{ChainID: "test"},
+		Peers:      cfg,
+	})
+	assert.Nil(t, err)
+
+	go s.Start()
+	t.Cleanup(s.Stop)
+
+	return s
+}
+
+func newDiscoveryTestServer(t *testing.T, seeds []string, addrBookPath string) *Server {
+	return startTestServer(t, seeds, PeerConfig{
+		TargetOutbound:    4,
+		AddrBookPath:      addrBookPath,
+		DiscoveryInterval: 100 * time.Millisecond,
+	})
+}
+
+func connectedPeers(s *Server) map[PeerID]bool {
+	ids := map[PeerID]bool{}
+	for _, peer := range s.tcp.Peers() {
+		ids[peer.ID] = true
+	}
+	return ids
+}
+
+func TestJoinNetworkThroughSingleSeed(t *testing.T) {
+	seed := newDiscoveryTestServer(t, nil, "")
+	nodes := []*Server{seed}
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, newDiscoveryTestServer(t, []string{seed.ListenAddr}, ""))
+	}
+
+	// The new node only knows the seed, it learns about the other nodes
+	// from it and connects to all of them.
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+	node := newDiscoveryTestServer(t, []string{seed.ListenAddr}, path)
+
+	assert.Eventually(t, func() bool {
+		peers := connectedPeers(node)
+		for _, other := range nodes {
+			if !peers[other.nodeID()] {
+				return false
+			}
+		}
+		return true
+	}, 15*time.Second, 50*time.Millisecond)
+
+	// The other nodes know the new node as well.
+	assert.Eventually(t, func() bool {
+		for _, other := range nodes {
+			if !connectedPeers(other)[node.nodeID()] {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// All nodes end up in the persisted address book.
+	assert.Eventually(t, func() bool {
+		book, err := NewAddrBook(path)
+		if err != nil {
+			return false
+		}
+
+		for _, other := range nodes {
+			known, ok := book.Get(other.ListenAddr)
+			if !ok || known.ID != other.nodeID() {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestServerLimitsInboundPeers(t *testing.T) {
+	s, err := NewServer(ServerOpts{
+		Logger:  log.NewNopLogger(),
+		Genesis: &core.Genesis{ChainID: "test"},
+		Peers:   PeerConfig{MaxInbound: 1},
+	})
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		other, err := NewServer(ServerOpts{
+			Logger:  log.NewNopLogger(),
+			Genesis: &core.Genesis{ChainID: "test"},
+		})
+		assert.Nil(t, err)
+
+		connA, connB := newTCPConnPair(t)
+		go other.addPeer(&TCPPeer{conn: connA, Outgoing: true})
+		go s.addPeer(&TCPPeer{conn: connB})
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 1, len(connectedPeers(s)))
+}
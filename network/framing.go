@@ -0,0 +1,93 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Messages are framed on the wire so that they can be read from a stream:
+//
+//	length (4) | type (1) | checksum (4) | data (length)
+//
+// The length is the length of the data, the checksum is the CRC-32C of the
+// type and the data. All integers are big endian.
+const frameHeaderSize = 9
+
+// MaxMessageSize is the maximum size of the data of a single message.
+const MaxMessageSize = 32 << 20
+
+var (
+	ErrMessageTooLarge = errors.New("message too large")
+	ErrInvalidChecksum = errors.New("invalid message checksum")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func frameChecksum(t MessageType, data []byte) uint32 {
+	sum := crc32.Update(0, crcTable, []byte{byte(t)})
+	return crc32.Update(sum, crcTable, data)
+}
+
+// Encode writes the framed message to w.
+func (msg *Message) Encode(w io.Writer) error {
+	if len(msg.Data) > MaxMessageSize {
+		return fmt.Errorf("%w (%d bytes)", ErrMessageTooLarge, len(msg.Data))
+	}
+
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(msg.Data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(msg.Data)))
+	buf[4] = byte(msg.Header)
+	binary.BigEndian.PutUint32(buf[5:9], frameChecksum(msg.Header, msg.Data))
+	buf = append(buf, msg.Data...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// MessageDecoder reads framed messages from a stream. It reads exactly one
+// frame per message and never more, wrap the reader with a bufio.Reader to
+// reduce the number of reads.
+type MessageDecoder struct {
+	r      io.Reader
+	header [frameHeaderSize]byte
+}
+
+func NewMessageDecoder(r io.Reader) *MessageDecoder {
+	return &MessageDecoder{
+		r: r,
+	}
+}
+
+// Decode reads the next message. It returns io.EOF if the stream ends
+// between two messages and io.ErrUnexpectedEOF if it ends within one. After
+// any other error the stream can not be trusted anymore.
+func (d *MessageDecoder) Decode() (*Message, error) {
+	if _, err := io.ReadFull(d.r, d.header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(d.header[0:4])
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrMessageTooLarge, size)
+	}
+
+	msg := &Message{
+		Header: MessageType(d.header[4]),
+		Data:   make([]byte, size),
+	}
+	if _, err := io.ReadFull(d.r, msg.Data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint32(d.header[5:9]) != frameChecksum(msg.Header, msg.Data) {
+		return nil, ErrInvalidChecksum
+	}
+
+	return msg, nil
+}
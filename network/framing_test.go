@@ -0,0 +1,144 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/anthdm/projectx/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageDecoderStream(t *testing.T) {
+	messages := []*Message{
+		NewMessage(MessageTypeGetStatus, nil),
+		NewMessage(MessageTypeTx, []byte("foo")),
+		NewMessage(MessageTypeBlocks, bytes.Repeat([]byte{0xab}, 10000)),
+	}
+
+	// Coalesced messages are split again and a message can arrive in any
+	// number of reads.
+	buf := new(bytes.Buffer)
+	for _, msg := range messages {
+		assert.Nil(t, msg.Encode(buf))
+	}
+
+	dec := NewMessageDecoder(iotest.OneByteReader(buf))
+	for _, msg := range messages {
+		decoded, err := dec.Decode()
+		assert.Nil(t, err)
+		assert.Equal(t, msg.Header, decoded.Header)
+		assert.Equal(t, len(msg.Data), len(decoded.Data))
+		assert.True(t, bytes.Equal(msg.Data, decoded.Data))
+	}
+
+	_, err := dec.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMessageDecoderRejectsMalformedFrames(t *testing.T) {
+	frame := NewMessage(MessageTypeTx, []byte("foo")).Bytes()
+
+	_, err := NewMessageDecoder(bytes.NewReader(frame[:len(frame)-1])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewMessageDecoder(bytes.NewReader(frame[:4])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	corrupted := bytes.Clone(frame)
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, err = NewMessageDecoder(bytes.NewReader(corrupted)).Decode()
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+
+	// The type is covered by the checksum as well.
+	corrupted = bytes.Clone(frame)
+	corrupted[4] = byte(MessageTypeBlock)
+	_, err = NewMessageDecoder(bytes.NewReader(corrupted)).Decode()
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+
+	// The length is checked before the data is read.
+	tooLarge := bytes.Clone(frame)
+	binary.BigEndian.PutUint32(tooLarge, MaxMessageSize+1)
+	_, err = NewMessageDecoder(bytes.NewReader(tooLarge)).Decode()
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+
+	msg := NewMessage(MessageTypeBlocks, make([]byte, MaxMessageSize+1))
+	assert.ErrorIs(t, msg.Encode(io.Discard), ErrMessageTooLarge)
+	assert.Nil(t, msg.Bytes())
+}
+
+// newTCPConnPair returns both ends of a loopback TCP connection.
+func newTCPConnPair(t *testing.T) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	connCh := make(chan net.Conn)
+	go func() {
+		conn, err := ln.Accept()
+		assert.Nil(t, err)
+		connCh <- conn
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	accepted := <-connCh
+
+	t.Cleanup(func() {
+		conn.Close()
+		accepted.Close()
+	})
+
+	return conn, accepted
+}
+
+func TestTCPPeerLargeMessages(t *testing.T) {
+	conn, accepted := newTCPConnPair(t)
+	sender := &TCPPeer{conn: conn, Outgoing: true}
+	receiver := &TCPPeer{conn: accepted}
+
+	rpcCh := make(chan RPC)
+	go receiver.readLoop(rpcCh)
+
+	privKey := crypto.GeneratePrivateKey()
+	blocksMsg := &BlocksMessage{}
+	for i := 1; i <= 50; i++ {
+		b := util.NewRandomBlockWithSignature(t, privKey, uint32(i), types.Hash{})
+		blocksMsg.Blocks = append(blocksMsg.Blocks, b)
+	}
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, blocksMsg.Encode(core.GobCodec{}, buf))
+	assert.Greater(t, buf.Len(), 4096)
+
+	// Both messages are written at once.
+	payload := append(NewMessage(MessageTypeBlocks, buf.Bytes()).Bytes(), NewMessage(MessageTypeGetStatus, nil).Bytes()...)
+	assert.Nil(t, sender.Send(payload))
+
+	for _, expected := range []MessageType{MessageTypeBlocks, MessageTypeGetStatus} {
+		select {
+		case rpc := <-rpcCh:
+			msg, err := DefaultRPCDecodeFunc(rpc)
+			assert.Nil(t, err)
+
+			switch expected {
+			case MessageTypeBlocks:
+				blocks, ok := msg.Data.(*BlocksMessage)
+				assert.True(t, ok)
+				assert.Equal(t, len(blocksMsg.Blocks), len(blocks.Blocks))
+			case MessageTypeGetStatus:
+				_, ok := msg.Data.(*GetStatusMessage)
+				assert.True(t, ok)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+)
+
+// ProtocolVersion is the version of the peer protocol, peers of another
+// version are rejected.
+const ProtocolVersion uint32 = 1
+
+const (
+	handshakeTimeout = 5 * time.Second
+	// Signing domain of the handshake signatures, see core.SigningDigest.
+	signingDomainHandshake = "projectx/handshake"
+)
+
+// ErrIncompatiblePeer is returned by the handshake for peers of another
+// protocol version or network.
+var ErrIncompatiblePeer = errors.New("incompatible peer")
+
+// PeerID identifies a peer by the address of its node key. It implements
+// net.Addr so it can be used as the sender of a RPC.
+type PeerID string
+
+func (id PeerID) Network() string {
+	return "projectx"
+}
+
+func (id PeerID) String() string {
+	return string(id)
+}
+
+// NewPeerID returns the ID of the node with the given key.
+func NewPeerID(nodeKey crypto.PublicKey) PeerID {
+	return PeerID(nodeKey.Address().String())
+}
+
+// HandshakeMessage is the first message both sides of a connection send.
+type HandshakeMessage struct {
+	Version     uint32
+	ChainID     string
+	GenesisHash types.Hash
+	// Key that identifies the node, the peer has to prove it owns it by
+	// signing the nonce of the other side.
+	NodeKey crypto.PublicKey
+	// Address the node accepts connections on, empty if it does not.
+	ListenAddr string
+	Nonce      types.Hash
+}
+
+// HandshakeAuthMessage answers the HandshakeMessage of the other side.
+type HandshakeAuthMessage struct {
+	Signature *crypto.Signature
+}
+
+// handshake exchanges a HandshakeMessage and a HandshakeAuthMessage with the
+// peer on the other side of conn. It returns the HandshakeMessage of the
+// peer once it proved that it owns its node key and is compatible with us.
+//
+// local has to be the handshake of nodeKey without a nonce, a fresh nonce
+// is generated for every connection.
+func handshake(conn net.Conn, local HandshakeMessage, nodeKey crypto.PrivateKey) (*HandshakeMessage, error) {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := rand.Read(local.Nonce[:]); err != nil {
+		return nil, err
+	}
+
+	// Both sides write before they read, the messages are small enough to
+	// not block on the socket buffers.
+	if err := writeHandshakeMessage(conn, MessageTypeHandshake, local); err != nil {
+		return nil, err
+	}
+
+	dec := NewMessageDecoder(conn)
+	remote := new(HandshakeMessage)
+	if err := readHandshakeMessage(dec, MessageTypeHandshake, remote); err != nil {
+		return nil, err
+	}
+	if err := checkHandshake(&local, remote); err != nil {
+		return nil, err
+	}
+
+	sig, err := nodeKey.Sign(handshakeDigest(local.ChainID, remote.Nonce, local.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHandshakeMessage(conn, MessageTypeHandshakeAuth, HandshakeAuthMessage{Signature: sig}); err != nil {
+		return nil, err
+	}
+
+	auth := new(HandshakeAuthMessage)
+	if err := readHandshakeMessage(dec, MessageTypeHandshakeAuth, auth); err != nil {
+		return nil, err
+	}
+	if auth.Signature == nil || auth.Signature.R == nil || auth.Signature.S == nil {
+		return nil, fmt.Errorf("handshake has no signature")
+	}
+	if !auth.Signature.Verify(remote.NodeKey, handshakeDigest(local.ChainID, local.Nonce, remote.Nonce)) {
+		return nil, fmt.Errorf("invalid handshake signature")
+	}
+
+	return remote, nil
+}
+
+// checkHandshake checks that the remote peer can talk to the local one.
+func checkHandshake(local, remote *HandshakeMessage) error {
+	if remote.Version != local.Version {
+		return fmt.Errorf("%w: protocol version (%d)", ErrIncompatiblePeer, remote.Version)
+	}
+
+	if remote.ChainID != local.ChainID || remote.GenesisHash != local.GenesisHash {
+		return fmt.Errorf("%w: %w: chain (%s) with genesis (%s)", ErrIncompatiblePeer, ErrChainMismatch, remote.ChainID, remote.GenesisHash)
+	}
+
+	if len(remote.NodeKey) != 33 {
+		return fmt.Errorf("invalid node key length (%d)", len(remote.NodeKey))
+	}
+	if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), remote.NodeKey); x == nil {
+		return fmt.Errorf("invalid node key (%s)", remote.NodeKey)
+	}
+
+	return nil
+}
+
+// handshakeDigest is signed by a node to prove it owns its key. It covers
+// the nonce of the other side, which makes it fresh, and the nonce of the
+// signer, which binds it to this connection.
+func handshakeDigest(chainID string, challenge, nonce types.Hash) []byte {
+	h := sha256.Sum256(append(challenge.ToSlice(), nonce.ToSlice()...))
+	return core.SigningDigest(signingDomainHandshake, chainID, types.Hash(h))
+}
+
+func writeHandshakeMessage(conn net.Conn, t MessageType, data any) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(data); err != nil {
+		return err
+	}
+
+	return NewMessage(t, buf.Bytes()).Encode(conn)
+}
+
+func readHandshakeMessage(dec *MessageDecoder, t MessageType, data any) error {
+	msg, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+	if msg.Header != t {
+		return fmt.Errorf("expected handshake message %x, got %x", t, msg.Header)
+	}
+
+	return gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(data)
+}
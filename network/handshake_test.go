@@ -0,0 +1,140 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type handshakeResult struct {
+	remote *HandshakeMessage
+	err    error
+}
+
+// runHandshakes runs the handshake on both ends of a connection.
+func runHandshakes(t *testing.T, a, b HandshakeMessage, keyA, keyB crypto.PrivateKey) (handshakeResult, handshakeResult) {
+	connA, connB := newTCPConnPair(t)
+
+	resCh := make(chan handshakeResult)
+	go func() {
+		remote, err := handshake(connB, b, keyB)
+		connB.Close()
+		resCh <- handshakeResult{remote, err}
+	}()
+
+	remote, err := handshake(connA, a, keyA)
+	connA.Close()
+
+	return handshakeResult{remote, err}, <-resCh
+}
+
+func newTestHandshake(chainID string, key crypto.PrivateKey) HandshakeMessage {
+	genesis := &core.Genesis{ChainID: chainID}
+	return HandshakeMessage{
+		Version:     ProtocolVersion,
+		ChainID:     chainID,
+		GenesisHash: genesis.Block().Hash(core.BlockHasher{}),
+		NodeKey:     key.PublicKey(),
+		ListenAddr:  ":3000",
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	keyA := crypto.GeneratePrivateKey()
+	keyB := crypto.GeneratePrivateKey()
+
+	resA, resB := runHandshakes(t, newTestHandshake("test", keyA), newTestHandshake("test", keyB), keyA, keyB)
+	assert.Nil(t, resA.err)
+	assert.Nil(t, resB.err)
+	assert.Equal(t, keyB.PublicKey(), resA.remote.NodeKey)
+	assert.Equal(t, keyA.PublicKey(), resB.remote.NodeKey)
+	assert.Equal(t, ":3000", resA.remote.ListenAddr)
+	assert.NotEqual(t, resA.remote.Nonce, resB.remote.Nonce)
+}
+
+func TestHandshakeRejectsIncompatiblePeers(t *testing.T) {
+	keyA := crypto.GeneratePrivateKey()
+	keyB := crypto.GeneratePrivateKey()
+
+	resA, resB := runHandshakes(t, newTestHandshake("test", keyA), newTestHandshake("other", keyB), keyA, keyB)
+	assert.ErrorIs(t, resA.err, ErrIncompatiblePeer)
+	assert.ErrorIs(t, resA.err, ErrChainMismatch)
+	assert.ErrorIs(t, resB.err, ErrIncompatiblePeer)
+
+	old := newTestHandshake("test", keyB)
+	old.Version = ProtocolVersion + 1
+	resA, _ = runHandshakes(t, newTestHandshake("test", keyA), old, keyA, keyB)
+	assert.ErrorIs(t, resA.err, ErrIncompatiblePeer)
+}
+
+func TestHandshakeRejectsStolenNodeKey(t *testing.T) {
+	keyA := crypto.GeneratePrivateKey()
+	victim := crypto.GeneratePrivateKey()
+	attacker := crypto.GeneratePrivateKey()
+
+	// The attacker claims the key of the victim, but can only sign with its
+	// own key.
+	resA, _ := runHandshakes(t, newTestHandshake("test", keyA), newTestHandshake("test", victim), keyA, attacker)
+	assert.NotNil(t, resA.err)
+	assert.Nil(t, resA.remote)
+}
+
+func TestServerAddPeerByNodeID(t *testing.T) {
+	newTestServer := func() *Server {
+		s, err := NewServer(ServerOpts{
+			Logger:  log.NewNopLogger(),
+			Genesis: &core.Genesis{ChainID: "test"},
+		})
+		assert.Nil(t, err)
+		return s
+	}
+	connect := func(a, b *Server) {
+		connA, connB := newTCPConnPair(t)
+		go a.addPeer(&TCPPeer{conn: connA, Outgoing: true})
+		go b.addPeer(&TCPPeer{conn: connB})
+	}
+	peerIDs := func(s *Server) []PeerID {
+		ids := []PeerID{}
+		for _, peer := range s.tcp.Peers() {
+			ids = append(ids, peer.ID)
+		}
+		return ids
+	}
+
+	a := newTestServer()
+	b := newTestServer()
+
+	connect(a, b)
+	assert.Eventually(t, func() bool { return len(peerIDs(a)) == 1 && len(peerIDs(b)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []PeerID{b.nodeID()}, peerIDs(a))
+	assert.Equal(t, []PeerID{a.nodeID()}, peerIDs(b))
+
+	peer, err := a.peer(b.nodeID())
+	assert.Nil(t, err)
+	assert.True(t, peer.Outgoing)
+
+	// Of two connections between the same nodes both keep the one dialed
+	// by the node with the lower ID.
+	connect(b, a)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []PeerID{b.nodeID()}, peerIDs(a))
+	assert.Equal(t, []PeerID{a.nodeID()}, peerIDs(b))
+	peer, err = a.peer(b.nodeID())
+	assert.Nil(t, err)
+	assert.Equal(t, a.nodeID() < b.nodeID(), peer.Outgoing)
+	peer, err = b.peer(a.nodeID())
+	assert.Nil(t, err)
+	assert.Equal(t, b.nodeID() < a.nodeID(), peer.Outgoing)
+
+	// So is a connection to ourselves.
+	connect(a, a)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []PeerID{b.nodeID()}, peerIDs(a))
+
+	_, err = a.peer(NetAddr("unknown"))
+	assert.NotNil(t, err)
+}
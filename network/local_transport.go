@@ -58,8 +58,15 @@ func (t *LocalTransport) SendMessage(to net.Addr, payload []byte) error {
 }
 
 func (t *LocalTransport) Broadcast(payload []byte) error {
-	for _, peer := range t.peers {
-		if err := t.SendMessage(peer.Addr(), payload); err != nil {
+	t.lock.RLock()
+	addrs := make([]net.Addr, 0, len(t.peers))
+	for addr := range t.peers {
+		addrs = append(addrs, addr)
+	}
+	t.lock.RUnlock()
+
+	for _, addr := range addrs {
+		if err := t.SendMessage(addr, payload); err != nil {
 			return err
 		}
 	}
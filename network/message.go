@@ -1,6 +1,18 @@
 package network
 
-import "github.com/anthdm/projectx/core"
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/types"
+)
+
+// Upper bound of blocks in a single BlocksMessage.
+const maxBlocksPerMessage = 1 << 16
 
 type GetBlocksMessage struct {
 	From uint32
@@ -12,11 +24,109 @@ type BlocksMessage struct {
 	Blocks []*core.Block
 }
 
+// Encode writes the number of blocks followed by every block encoded with
+// the given codec.
+func (msg *BlocksMessage) Encode(codec core.Codec, w io.Writer) error {
+	buf := binary.AppendUvarint(nil, uint64(len(msg.Blocks)))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	for _, b := range msg.Blocks {
+		if err := b.Encode(codec.NewBlockEncoder(w)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (msg *BlocksMessage) Decode(codec core.Codec, r io.Reader) error {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+		r = br.(io.Reader)
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	if n > maxBlocksPerMessage {
+		return fmt.Errorf("too many blocks in message (%d)", n)
+	}
+
+	msg.Blocks = make([]*core.Block, n)
+	for i := range msg.Blocks {
+		msg.Blocks[i] = new(core.Block)
+		if err := msg.Blocks[i].Decode(codec.NewBlockDecoder(r)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type GetStatusMessage struct{}
 
+// Upper bound of peers in a single PeersMessage.
+const maxPeersPerMessage = 64
+
+type GetPeersMessage struct{}
+
+// PeerAddr is the address a node accepts connections on.
+type PeerAddr struct {
+	ID   PeerID
+	Addr string
+}
+
+type PeersMessage struct {
+	Peers []PeerAddr
+}
+
 type StatusMessage struct {
 	// the id of the server
 	ID            string
 	Version       uint32
 	CurrentHeight uint32
+	// Peers only sync with peers of the same network.
+	ChainID     string
+	GenesisHash types.Hash
+}
+
+// ProposalMessage is the block proposed by a validator in a consensus
+// round.
+type ProposalMessage struct {
+	Round uint32
+	Block *core.Block
+}
+
+func (msg *ProposalMessage) Encode(codec core.Codec, w io.Writer) error {
+	buf := binary.AppendUvarint(nil, uint64(msg.Round))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	return msg.Block.Encode(codec.NewBlockEncoder(w))
+}
+
+func (msg *ProposalMessage) Decode(codec core.Codec, r io.Reader) error {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+		r = br.(io.Reader)
+	}
+
+	round, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	if round > math.MaxUint32 {
+		return fmt.Errorf("invalid proposal round (%d)", round)
+	}
+
+	msg.Round = uint32(round)
+	msg.Block = new(core.Block)
+
+	return msg.Block.Decode(codec.NewBlockDecoder(r))
 }
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/anthdm/projectx/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocksMessageEncodeDecode(t *testing.T) {
+	for _, codec := range []core.Codec{core.GobCodec{}, core.ProtoCodec{}} {
+		privKey := crypto.GeneratePrivateKey()
+		msg := &BlocksMessage{
+			Blocks: []*core.Block{
+				util.NewRandomBlockWithSignature(t, privKey, 1, types.Hash{}),
+				util.NewRandomBlockWithSignature(t, privKey, 2, types.Hash{}),
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, msg.Encode(codec, buf))
+
+		decoded := new(BlocksMessage)
+		assert.Nil(t, decoded.Decode(codec, buf))
+		assert.Equal(t, len(msg.Blocks), len(decoded.Blocks))
+
+		for i, b := range decoded.Blocks {
+			assert.Equal(t, msg.Blocks[i].Header, b.Header)
+			assert.Nil(t, b.Verify())
+		}
+	}
+}
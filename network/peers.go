@@ -0,0 +1,153 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+)
+
+// Misbehavior penalties, a peer is banned once the sum of its penalties
+// reaches PeerConfig.BanThreshold.
+const (
+	penaltyMalformedMessage = 10
+	penaltyInvalidBlock     = 25
+)
+
+// runPeer reads the messages of a peer until it disconnects and removes it
+// afterwards.
+func (s *Server) runPeer(peer *TCPPeer) {
+	err := peer.readLoop(s.tcp.rpcCh)
+	if errors.Is(err, ErrInvalidChecksum) || errors.Is(err, ErrMessageTooLarge) {
+		s.penalize(peer.ID, penaltyMalformedMessage, err)
+	}
+
+	s.removePeer(peer, err)
+}
+
+// removePeer forgets about a disconnected peer. The peer might have been
+// replaced by another connection to the same node already.
+func (s *Server) removePeer(peer *TCPPeer, reason error) {
+	if s.tcp.RemovePeer(peer) {
+		s.Logger.Log("msg", "peer disconnected", "id", peer.ID, "addr", peer.conn.RemoteAddr(), "err", reason)
+	}
+}
+
+// connectedTo returns true if we are connected to the node at addr.
+func (s *Server) connectedTo(addr string) bool {
+	known, _ := s.addrBook.Get(addr)
+	if _, ok := s.tcp.Peer(known.ID); ok && len(known.ID) > 0 {
+		return true
+	}
+
+	for _, peer := range s.tcp.Peers() {
+		if peer.DialAddr == addr || peer.advertisedAddr() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seedLoop keeps the node connected to a seed. While it can not connect to
+// the seed the delay between two attempts doubles up to
+// PeerConfig.MaxReconnectDelay.
+func (s *Server) seedLoop(addr string) {
+	s.addrBook.Add(addr, "")
+
+	delay := s.Peers.ReconnectDelay
+	for {
+		if !s.connectedTo(addr) && !s.isBanned(addr) {
+			s.dial(addr)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-s.quitCh:
+			return
+		}
+
+		if s.connectedTo(addr) {
+			delay = s.Peers.ReconnectDelay
+		} else {
+			delay = min(2*delay, s.Peers.MaxReconnectDelay)
+		}
+	}
+}
+
+// isInvalidBlock returns true if processing a message failed because it
+// contained an invalid block. Blocks that are known already or arrive out
+// of order are not invalid.
+func isInvalidBlock(data any, err error) bool {
+	switch data.(type) {
+	case *core.Block, *BlocksMessage:
+	default:
+		return false
+	}
+
+	return !errors.Is(err, core.ErrBlockKnown) &&
+		!errors.Is(err, core.ErrUnknownParent) &&
+		!errors.Is(err, core.ErrBelowFinalized)
+}
+
+// penalize adds a misbehavior penalty to the peer. Peers that reach the ban
+// threshold are disconnected and banned together with their addresses for
+// PeerConfig.BanDuration.
+func (s *Server) penalize(from net.Addr, penalty int, reason error) {
+	id := PeerID(from.String())
+	if len(id) == 0 {
+		return
+	}
+
+	var peer *TCPPeer
+	if s.tcp != nil {
+		peer, _ = s.tcp.Peer(id)
+	}
+
+	s.mu.Lock()
+	s.misbehavior[id] += penalty
+	score := s.misbehavior[id]
+
+	banned := score >= s.Peers.BanThreshold
+	if banned {
+		delete(s.misbehavior, id)
+
+		until := time.Now().Add(s.Peers.BanDuration)
+		s.bans[string(id)] = until
+		if peer != nil {
+			for _, addr := range []string{peer.DialAddr, peer.advertisedAddr()} {
+				if len(addr) > 0 {
+					s.bans[addr] = until
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	s.Logger.Log("msg", "peer misbehaved", "id", id, "score", score, "err", reason)
+
+	if banned {
+		s.Logger.Log("msg", "banning peer", "id", id, "duration", s.Peers.BanDuration)
+		if peer != nil {
+			peer.conn.Close()
+		}
+	}
+}
+
+// isBanned returns true if the peer ID or address is banned.
+func (s *Server) isBanned(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.bans[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(s.bans, key)
+		return false
+	}
+
+	return true
+}
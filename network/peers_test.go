@@ -0,0 +1,168 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerRemovesDisconnectedPeer(t *testing.T) {
+	seed := startTestServer(t, nil, PeerConfig{})
+	node := startTestServer(t, []string{seed.ListenAddr}, PeerConfig{})
+
+	assert.Eventually(t, func() bool {
+		return connectedPeers(seed)[node.nodeID()] && connectedPeers(node)[seed.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	node.Stop()
+
+	assert.Eventually(t, func() bool {
+		return len(connectedPeers(seed)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServerReconnectsToSeed(t *testing.T) {
+	addr := freeAddr(t)
+	cfg := PeerConfig{
+		ReconnectDelay:    20 * time.Millisecond,
+		MaxReconnectDelay: 100 * time.Millisecond,
+	}
+	node := startTestServer(t, []string{addr}, cfg)
+
+	// The seed comes up after the node.
+	time.Sleep(1500 * time.Millisecond)
+	seed, err := NewServer(ServerOpts{Logger: log.NewNopLogger(), ListenAddr: addr, Genesis: &core.Genesis{ChainID: "test"}, Peers: cfg})
+	assert.Nil(t, err)
+	go seed.Start()
+
+	assert.Eventually(t, func() bool {
+		return connectedPeers(node)[seed.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// And restarts later on.
+	seed.Stop()
+	assert.Eventually(t, func() bool {
+		return len(connectedPeers(node)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	restarted, err := NewServer(ServerOpts{Logger: log.NewNopLogger(), ListenAddr: addr, Genesis: &core.Genesis{ChainID: "test"}, Peers: cfg})
+	assert.Nil(t, err)
+	go restarted.Start()
+	defer restarted.Stop()
+
+	assert.Eventually(t, func() bool {
+		return connectedPeers(node)[restarted.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServerBacksOffFromFailingSeed(t *testing.T) {
+	// The seed accepts connections, but closes them right away.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	var attempts atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			attempts.Add(1)
+			conn.Close()
+		}
+	}()
+
+	startTestServer(t, []string{ln.Addr().String()}, PeerConfig{
+		ReconnectDelay:    10 * time.Millisecond,
+		MaxReconnectDelay: time.Second,
+	})
+
+	// The server dials the seed one second after it starts, then waits 10,
+	// 20, 40, 80, 160, 320 and 640 milliseconds between the attempts.
+	time.Sleep(2500 * time.Millisecond)
+	n := attempts.Load()
+	assert.GreaterOrEqual(t, n, int32(5))
+	assert.LessOrEqual(t, n, int32(9))
+}
+
+func TestServerBansMisbehavingPeer(t *testing.T) {
+	seed := startTestServer(t, nil, PeerConfig{BanThreshold: 50})
+	node := startTestServer(t, []string{seed.ListenAddr}, PeerConfig{ReconnectDelay: 20 * time.Millisecond})
+
+	assert.Eventually(t, func() bool {
+		return connectedPeers(seed)[node.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	peer, err := node.peer(seed.nodeID())
+	assert.Nil(t, err)
+	score := func() int {
+		seed.mu.RLock()
+		defer seed.mu.RUnlock()
+		return seed.misbehavior[node.nodeID()]
+	}
+
+	// A block with an invalid signature.
+	header, err := seed.chain.GetHeader(0)
+	assert.Nil(t, err)
+	block, err := core.NewBlockFromPrevHeader(header, nil)
+	assert.Nil(t, err)
+	block.ChainID = "test"
+	assert.Nil(t, block.Sign(crypto.GeneratePrivateKey()))
+	block.Timestamp++
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, block.Encode(core.NewGobBlockEncoder(buf)))
+	assert.Nil(t, peer.Send(NewMessage(MessageTypeBlock, buf.Bytes()).Bytes()))
+	assert.Eventually(t, func() bool { return score() == penaltyInvalidBlock }, 5*time.Second, 10*time.Millisecond)
+
+	// Messages of an unknown type.
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, peer.Send(NewMessage(0xff, nil).Bytes()))
+	}
+	assert.Eventually(t, func() bool { return score() == penaltyInvalidBlock+2*penaltyMalformedMessage }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, connectedPeers(seed)[node.nodeID()])
+
+	// The peer reaches the threshold and is banned.
+	assert.Nil(t, peer.Send(NewMessage(0xff, nil).Bytes()))
+	assert.Eventually(t, func() bool {
+		return !connectedPeers(seed)[node.nodeID()] && seed.isBanned(string(node.nodeID()))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// It can not connect again while the ban lasts.
+	assert.Never(t, func() bool {
+		return connectedPeers(seed)[node.nodeID()]
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestServerPenalizesCorruptedFrames(t *testing.T) {
+	seed := startTestServer(t, nil, PeerConfig{})
+	node := startTestServer(t, []string{seed.ListenAddr}, PeerConfig{ReconnectDelay: time.Minute})
+
+	assert.Eventually(t, func() bool {
+		return connectedPeers(seed)[node.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	peer, err := node.peer(seed.nodeID())
+	assert.Nil(t, err)
+
+	frame := NewMessage(MessageTypeGetStatus, []byte("foo")).Bytes()
+	frame[len(frame)-1] ^= 0xff
+	assert.Nil(t, peer.Send(frame))
+
+	// The stream is out of sync, the seed disconnects the peer.
+	assert.Eventually(t, func() bool {
+		return !connectedPeers(seed)[node.nodeID()]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	seed.mu.RLock()
+	defer seed.mu.RUnlock()
+	assert.Equal(t, penaltyMalformedMessage, seed.misbehavior[node.nodeID()])
+}
@@ -21,6 +21,13 @@ const (
 	MessageTypeStatus    MessageType = 0x4
 	MessageTypeGetStatus MessageType = 0x5
 	MessageTypeBlocks    MessageType = 0x6
+	MessageTypeProposal  MessageType = 0x7
+	MessageTypeVote      MessageType = 0x8
+	// Only sent during the handshake of a new connection.
+	MessageTypeHandshake     MessageType = 0x9
+	MessageTypeHandshakeAuth MessageType = 0xa
+	MessageTypeGetPeers      MessageType = 0xb
+	MessageTypePeers         MessageType = 0xc
 )
 
 type RPC struct {
@@ -40,9 +47,13 @@ func NewMessage(t MessageType, data []byte) *Message {
 	}
 }
 
+// Bytes returns the framed message, or nil if it is larger than
+// MaxMessageSize.
 func (msg *Message) Bytes() []byte {
 	buf := &bytes.Buffer{}
-	gob.NewEncoder(buf).Encode(msg)
+	if err := msg.Encode(buf); err != nil {
+		return nil
+	}
 	return buf.Bytes()
 }
 
@@ -53,9 +64,20 @@ type DecodedMessage struct {
 
 type RPCDecodeFunc func(RPC) (*DecodedMessage, error)
 
-func DefaultRPCDecodeFunc(rpc RPC) (*DecodedMessage, error) {
-	msg := Message{}
-	if err := gob.NewDecoder(rpc.Payload).Decode(&msg); err != nil {
+// DefaultRPCDecodeFunc decodes transactions and blocks with the gob codec.
+var DefaultRPCDecodeFunc = NewRPCDecodeFunc(core.GobCodec{})
+
+// NewRPCDecodeFunc returns a RPCDecodeFunc that decodes transactions and
+// blocks with the given codec.
+func NewRPCDecodeFunc(codec core.Codec) RPCDecodeFunc {
+	return func(rpc RPC) (*DecodedMessage, error) {
+		return decodeRPC(rpc, codec)
+	}
+}
+
+func decodeRPC(rpc RPC, codec core.Codec) (*DecodedMessage, error) {
+	msg, err := NewMessageDecoder(rpc.Payload).Decode()
+	if err != nil {
 		return nil, fmt.Errorf("failed to decode message from %s: %s", rpc.From, err)
 	}
 
@@ -69,7 +91,7 @@ func DefaultRPCDecodeFunc(rpc RPC) (*DecodedMessage, error) {
 	switch msg.Header {
 	case MessageTypeTx:
 		tx := new(core.Transaction)
-		if err := tx.Decode(core.NewGobTxDecoder(bytes.NewReader(msg.Data))); err != nil {
+		if err := tx.Decode(codec.NewTxDecoder(bytes.NewReader(msg.Data))); err != nil {
 			return nil, err
 		}
 
@@ -80,7 +102,7 @@ func DefaultRPCDecodeFunc(rpc RPC) (*DecodedMessage, error) {
 
 	case MessageTypeBlock:
 		block := new(core.Block)
-		if err := block.Decode(core.NewGobBlockDecoder(bytes.NewReader(msg.Data))); err != nil {
+		if err := block.Decode(codec.NewBlockDecoder(bytes.NewReader(msg.Data))); err != nil {
 			return nil, err
 		}
 
@@ -119,7 +141,7 @@ func DefaultRPCDecodeFunc(rpc RPC) (*DecodedMessage, error) {
 
 	case MessageTypeBlocks:
 		blocks := new(BlocksMessage)
-		if err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(blocks); err != nil {
+		if err := blocks.Decode(codec, bytes.NewReader(msg.Data)); err != nil {
 			return nil, err
 		}
 
@@ -128,6 +150,48 @@ func DefaultRPCDecodeFunc(rpc RPC) (*DecodedMessage, error) {
 			Data: blocks,
 		}, nil
 
+	case MessageTypeProposal:
+		proposal := new(ProposalMessage)
+		if err := proposal.Decode(codec, bytes.NewReader(msg.Data)); err != nil {
+			return nil, err
+		}
+
+		return &DecodedMessage{
+			From: rpc.From,
+			Data: proposal,
+		}, nil
+
+	case MessageTypeVote:
+		vote := new(core.Vote)
+		if err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(vote); err != nil {
+			return nil, err
+		}
+
+		return &DecodedMessage{
+			From: rpc.From,
+			Data: vote,
+		}, nil
+
+	case MessageTypeGetPeers:
+		return &DecodedMessage{
+			From: rpc.From,
+			Data: &GetPeersMessage{},
+		}, nil
+
+	case MessageTypePeers:
+		peers := new(PeersMessage)
+		if err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(peers); err != nil {
+			return nil, err
+		}
+		if len(peers.Peers) > maxPeersPerMessage {
+			return nil, fmt.Errorf("too many peers in message (%d)", len(peers.Peers))
+		}
+
+		return &DecodedMessage{
+			From: rpc.From,
+			Data: peers,
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("invalid message header %x", msg.Header)
 	}
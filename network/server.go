@@ -2,7 +2,9 @@ package network
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -12,67 +14,154 @@ import (
 	"github.com/anthdm/projectx/api"
 	"github.com/anthdm/projectx/core"
 	"github.com/anthdm/projectx/crypto"
-	"github.com/anthdm/projectx/types"
+	"github.com/anthdm/projectx/grpcapi"
+	"github.com/anthdm/projectx/webhook"
 	"github.com/go-kit/log"
 )
 
 var defaultBlockTime = 5 * time.Second
 
+// ErrChainMismatch is returned for peers that belong to a different network.
+var ErrChainMismatch = errors.New("peer belongs to a different chain")
+
 type ServerOpts struct {
 	APIListenAddr string
-	SeedNodes     []string
-	ListenAddr    string
-	TCPTransport  *TCPTransport
+	// If set a gRPC server will be started next to the JSON API server.
+	GRPCListenAddr string
+	SeedNodes      []string
+	ListenAddr     string
+	// Transport the node talks to its peers over, defaults to a
+	// TCPTransport on ListenAddr. Handshakes, peer discovery and bans are
+	// only available over TCP, peers of other transports have to be
+	// connected by the caller.
+	Transport     Transport
 	ID            string
 	Logger        log.Logger
 	RPCDecodeFunc RPCDecodeFunc
-	RPCProcessor  RPCProcessor
-	BlockTime     time.Duration
-	PrivateKey    *crypto.PrivateKey
+	// Codec used to encode transactions and blocks on the wire, defaults
+	// to core.GobCodec.
+	Codec        core.Codec
+	RPCProcessor RPCProcessor
+	PrivateKey   *crypto.PrivateKey
+	// Key that identifies the node to its peers, defaults to the
+	// PrivateKey or a generated key.
+	NodeKey *crypto.PrivateKey
+	// Genesis of the chain, all nodes of a network have to use the same
+	// one. Validators of the genesis take turns proposing blocks in the
+	// given order and agree on them with BFT consensus. If it has no
+	// validators, every node with a PrivateKey produces blocks. Defaults to
+	// a local development genesis.
+	Genesis *core.Genesis
+	// Timeouts of the consensus rounds, only used with a validator set.
+	Consensus ConsensusConfig
+	// If set connections to peers are encrypted with mutual TLS, every node
+	// presents a self-signed certificate of its NodeKey. All nodes of a
+	// network have to enable it.
+	EncryptTransport bool
+	// Peer counts and discovery.
+	Peers PeerConfig
+	// Webhooks that will be notified about election lifecycle events.
+	Webhooks []webhook.Config
 }
 
 type Server struct {
-	TCPTransport *TCPTransport
-	peerCh       chan *TCPPeer
-
-	mu      sync.RWMutex
-	peerMap map[net.Addr]*TCPPeer
+	// Set if the Transport is a TCPTransport.
+	tcp *TCPTransport
+
+	// Guards the fields below and serializes adding peers.
+	mu sync.RWMutex
+	// Addresses that are being dialed.
+	dialing  map[string]bool
+	addrBook *AddrBook
+	// Misbehavior scores of peers and the time bans of peer IDs and
+	// addresses end.
+	misbehavior map[PeerID]int
+	bans        map[string]time.Time
+	// Heights of the peers that blocks are requested from, there is one
+	// sync loop per peer.
+	syncing map[string]uint32
 
 	ServerOpts
 	mempool     *TxPool
 	chain       *core.Blockchain
+	consensus   *Consensus
+	webhooks    []*webhook.Subscriber
 	isValidator bool
-	rpcCh       chan RPC
+	blockTime   time.Duration
 	quitCh      chan struct{}
+	stopOnce    sync.Once
 	txChan      chan *core.Transaction
 }
 
 func NewServer(opts ServerOpts) (*Server, error) {
-	if opts.BlockTime == time.Duration(0) {
-		opts.BlockTime = defaultBlockTime
+	if opts.Genesis == nil {
+		opts.Genesis = defaultGenesis()
+	}
+	if opts.Codec == nil {
+		opts.Codec = core.GobCodec{}
 	}
 	if opts.RPCDecodeFunc == nil {
-		opts.RPCDecodeFunc = DefaultRPCDecodeFunc
+		opts.RPCDecodeFunc = NewRPCDecodeFunc(opts.Codec)
+	}
+	if opts.NodeKey == nil {
+		if opts.PrivateKey != nil {
+			opts.NodeKey = opts.PrivateKey
+		} else {
+			nodeKey := crypto.GeneratePrivateKey()
+			opts.NodeKey = &nodeKey
+		}
 	}
+	opts.Peers.setDefaults()
 	if opts.Logger == nil {
 		opts.Logger = log.NewLogfmtLogger(os.Stderr)
 		opts.Logger = log.With(opts.Logger, "addr", opts.ID)
 	}
 
-	chain, err := core.NewBlockchain(opts.Logger, genesisBlock())
+	addrBook, err := NewAddrBook(opts.Peers.AddrBookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := core.NewBlockchain(opts.Logger, opts.Genesis)
 	if err != nil {
 		return nil, err
 	}
 
+	webhooks := make([]*webhook.Subscriber, 0, len(opts.Webhooks))
+	for _, cfg := range opts.Webhooks {
+		if cfg.Logger == nil {
+			cfg.Logger = opts.Logger
+		}
+
+		sub, err := webhook.NewSubscriber(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		chain.GetVotingState().OnEvent(sub.Handle)
+		go sub.Start()
+		webhooks = append(webhooks, sub)
+
+		opts.Logger.Log("msg", "webhook subscriber registered", "url", cfg.URL)
+	}
+
 	// Channel being used to communicate between the JSON RPC server
 	// and the node that will process this message.
 	txChan := make(chan *core.Transaction)
+	mempool := NewTxPool(1000)
+
+	// Every node drops the transactions whose nonce was used by a block,
+	// also the ones that only follow the chain.
+	chain.OnBlock(func(*core.Block) {
+		mempool.Prune(chain.NextNonce)
+	})
 
 	// Only boot up the API server if the config has a valid port number.
 	if len(opts.APIListenAddr) > 0 {
 		apiServerCfg := api.ServerConfig{
 			Logger:     opts.Logger,
 			ListenAddr: opts.APIListenAddr,
+			Mempool:    mempool,
 		}
 		apiServer := api.NewServer(apiServerCfg, chain, txChan)
 		go apiServer.Start()
@@ -80,23 +169,47 @@ func NewServer(opts ServerOpts) (*Server, error) {
 		opts.Logger.Log("msg", "JSON API server running", "port", opts.APIListenAddr)
 	}
 
-	peerCh := make(chan *TCPPeer)
-	tr := NewTCPTransport(opts.ListenAddr, peerCh)
+	if len(opts.GRPCListenAddr) > 0 {
+		grpcServerCfg := grpcapi.ServerConfig{
+			Logger:     opts.Logger,
+			ListenAddr: opts.GRPCListenAddr,
+			Mempool:    mempool,
+		}
+		grpcServer := grpcapi.NewServer(grpcServerCfg, chain, txChan)
+		go grpcServer.Start()
 
-	s := &Server{
-		TCPTransport: tr,
-		peerCh:       peerCh,
-		peerMap:      make(map[net.Addr]*TCPPeer),
-		ServerOpts:   opts,
-		chain:        chain,
-		mempool:      NewTxPool(1000),
-		isValidator:  opts.PrivateKey != nil,
-		rpcCh:        make(chan RPC),
-		quitCh:       make(chan struct{}, 1),
-		txChan:       txChan,
+		opts.Logger.Log("msg", "gRPC server running", "port", opts.GRPCListenAddr)
 	}
 
-	s.TCPTransport.peerCh = peerCh
+	if opts.Transport == nil {
+		opts.Transport = NewTCPTransport(opts.ListenAddr, make(chan *TCPPeer))
+	}
+	tcp, _ := opts.Transport.(*TCPTransport)
+	if tcp != nil && opts.EncryptTransport {
+		if err := tcp.EnableTLS(*opts.NodeKey); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		tcp:         tcp,
+		dialing:     make(map[string]bool),
+		misbehavior: make(map[PeerID]int),
+		bans:        make(map[string]time.Time),
+		syncing:     make(map[string]uint32),
+		addrBook:    addrBook,
+		ServerOpts:  opts,
+		chain:       chain,
+		mempool:     mempool,
+		webhooks:    webhooks,
+		isValidator: isValidator(opts),
+		blockTime:   opts.Genesis.BlockTime,
+		quitCh:      make(chan struct{}),
+		txChan:      txChan,
+	}
+	if s.blockTime == 0 {
+		s.blockTime = defaultBlockTime
+	}
 
 	// If we dont got any processor from the server options, we going to use
 	// the server as default.
@@ -104,64 +217,90 @@ func NewServer(opts ServerOpts) (*Server, error) {
 		s.RPCProcessor = s
 	}
 
-	if s.isValidator {
-		go s.validatorLoop()
+	// Nodes with a key follow the consensus even if they are not in the
+	// genesis validator set, they might be added to it later on.
+	if opts.PrivateKey != nil && len(opts.Genesis.Validators) > 0 {
+		if s.Consensus.TimeoutCommit == 0 {
+			s.Consensus.TimeoutCommit = s.blockTime
+		}
+		s.consensus = NewConsensus(ConsensusOpts{
+			ConsensusConfig: s.Consensus,
+			Logger:          s.Logger,
+			Chain:           chain,
+			Mempool:         s.mempool,
+			PrivateKey:      *s.PrivateKey,
+			Codec:           s.Codec,
+			Broadcast:       s.Transport.Broadcast,
+		})
 	}
 
 	return s, nil
 }
 
+func isValidator(opts ServerOpts) bool {
+	if opts.PrivateKey == nil {
+		return false
+	}
+	if len(opts.Genesis.Validators) == 0 {
+		return true
+	}
+
+	return core.NewValidatorSet(opts.Genesis.Validators...).Contains(opts.PrivateKey.PublicKey())
+}
+
 func (s *Server) bootstrapNetwork() {
 	for _, addr := range s.SeedNodes {
 		fmt.Println("trying to connect to ", addr)
 
-		go func(addr string) {
-			conn, err := net.Dial("tcp", addr)
-			if err != nil {
-				fmt.Printf("could not connect to %+v\n", conn)
-				return
-			}
-
-			s.peerCh <- &TCPPeer{
-				conn: conn,
-			}
-		}(addr)
+		go s.seedLoop(addr)
 	}
 }
 
 func (s *Server) Start() {
-	s.TCPTransport.Start()
+	var peerCh chan *TCPPeer
+	if s.tcp != nil {
+		if err := s.tcp.Start(); err != nil {
+			s.Logger.Log("msg", "failed to start transport", "err", err)
+		}
+		peerCh = s.tcp.peerCh
 
-	time.Sleep(time.Second * 1)
+		time.Sleep(time.Second * 1)
 
-	s.bootstrapNetwork()
+		s.bootstrapNetwork()
 
-	s.Logger.Log("msg", "accepting TCP connection on", "addr", s.ListenAddr, "id", s.ID)
+		go s.discoveryLoop()
 
-free:
-	for {
-		select {
-		case peer := <-s.peerCh:
-			s.peerMap[peer.conn.RemoteAddr()] = peer
+		s.Logger.Log("msg", "accepting TCP connection on", "addr", s.ListenAddr, "id", s.ID)
+	}
 
-			go peer.readLoop(s.rpcCh)
+	if s.consensus != nil {
+		s.consensus.Start()
+	} else if s.isValidator {
+		go s.validatorLoop()
+	}
 
-			if err := s.sendGetStatusMessage(peer); err != nil {
-				s.Logger.Log("err", err)
-				continue
-			}
+	// Peers that were connected before the start do not know our status
+	// yet, TCP peers are asked for it after the handshake.
+	if err := s.broadcastGetStatus(); err != nil {
+		s.Logger.Log("msg", "failed to request status", "err", err)
+	}
 
-			s.Logger.Log("msg", "peer added to the server", "outgoing", peer.Outgoing, "addr", peer.conn.RemoteAddr())
+free:
+	for {
+		select {
+		case peer := <-peerCh:
+			go s.addPeer(peer)
 
 		case tx := <-s.txChan:
 			if err := s.processTransaction(tx); err != nil {
 				s.Logger.Log("process TX error", err)
 			}
 
-		case rpc := <-s.rpcCh:
+		case rpc := <-s.Transport.Consume():
 			msg, err := s.RPCDecodeFunc(rpc)
 			if err != nil {
 				s.Logger.Log("RPC error", err)
+				s.penalize(rpc.From, penaltyMalformedMessage, err)
 				continue
 			}
 
@@ -169,6 +308,9 @@ free:
 				if err != core.ErrBlockKnown {
 					s.Logger.Log("error", err)
 				}
+				if isInvalidBlock(msg.Data, err) {
+					s.penalize(msg.From, penaltyInvalidBlock, err)
+				}
 			}
 
 		case <-s.quitCh:
@@ -179,10 +321,143 @@ free:
 	s.Logger.Log("msg", "Server is shutting down")
 }
 
+// Stop stops the server and closes the connections to all peers. It is
+// safe to call Stop more than once.
+func (s *Server) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
+	close(s.quitCh)
+
+	if s.consensus != nil {
+		s.consensus.Stop()
+	}
+
+	for _, sub := range s.webhooks {
+		sub.Stop()
+	}
+
+	if s.tcp != nil {
+		if err := s.tcp.Close(); err != nil {
+			s.Logger.Log("msg", "failed to close transport", "err", err)
+		}
+	}
+}
+
+// addPeer performs the handshake with a new peer and adds it to the known
+// peers. Incompatible peers, second connections to known peers and incoming
+// connections above the limit are closed.
+func (s *Server) addPeer(peer *TCPPeer) {
+	defer s.doneDialing(peer.DialAddr)
+
+	local := HandshakeMessage{
+		Version:     ProtocolVersion,
+		ChainID:     s.chain.ChainID(),
+		GenesisHash: s.chain.GenesisHash(),
+		NodeKey:     s.NodeKey.PublicKey(),
+		ListenAddr:  s.listenAddr(),
+	}
+
+	remote, err := handshake(peer.conn, local, *s.NodeKey)
+	if err == nil {
+		err = checkTLSNodeKey(peer.conn, remote.NodeKey)
+	}
+	if err != nil {
+		s.Logger.Log("msg", "handshake failed", "addr", peer.conn.RemoteAddr(), "err", err)
+		s.addrBook.MarkFailed(peer.DialAddr)
+		peer.conn.Close()
+		return
+	}
+
+	peer.ID = NewPeerID(remote.NodeKey)
+	peer.ListenAddr = remote.ListenAddr
+
+	if peer.ID == s.nodeID() {
+		// Our own address, most likely gossiped by a peer.
+		s.addrBook.Remove(peer.DialAddr)
+		peer.conn.Close()
+		return
+	}
+
+	if s.isBanned(string(peer.ID)) {
+		s.Logger.Log("msg", "dropping connection of banned peer", "id", peer.ID, "addr", peer.conn.RemoteAddr())
+		peer.conn.Close()
+		return
+	}
+
+	if peer.Outgoing {
+		s.addrBook.MarkGood(peer.DialAddr, peer.ID)
+	} else if addr := peer.advertisedAddr(); len(addr) > 0 {
+		s.addrBook.Add(addr, peer.ID)
+	}
+
+	// If two nodes dial each other at the same time, both sides keep the
+	// connection dialed by the node with the lower ID.
+	s.mu.Lock()
+	existing, known := s.tcp.Peer(peer.ID)
+	replace := known && existing.Outgoing != peer.Outgoing && peer.Outgoing == (s.nodeID() < peer.ID)
+	full := !peer.Outgoing && !replace && s.peerCount(false) >= s.Peers.MaxInbound
+	if (!known || replace) && !full {
+		s.tcp.AddPeer(peer)
+	}
+	s.mu.Unlock()
+
+	if known && !replace {
+		s.Logger.Log("msg", "dropping duplicate connection", "id", peer.ID, "addr", peer.conn.RemoteAddr())
+		peer.conn.Close()
+		return
+	}
+	if replace {
+		existing.conn.Close()
+	}
+	if full {
+		s.Logger.Log("msg", "dropping incoming connection, too many peers", "id", peer.ID, "addr", peer.conn.RemoteAddr())
+		peer.conn.Close()
+		return
+	}
+
+	go s.runPeer(peer)
+
+	if err := s.sendGetStatusMessage(peer.ID); err != nil {
+		s.Logger.Log("err", err)
+		return
+	}
+
+	if s.needsPeers() {
+		if err := s.sendGetPeersMessage(peer); err != nil {
+			s.Logger.Log("err", err)
+			return
+		}
+	}
+
+	s.Logger.Log("msg", "peer added to the server", "outgoing", peer.Outgoing, "id", peer.ID, "addr", peer.conn.RemoteAddr())
+}
+
+// nodeID is the ID of this node in the network.
+func (s *Server) nodeID() PeerID {
+	return NewPeerID(s.NodeKey.PublicKey())
+}
+
+// peer returns the known TCP peer that sent a RPC.
+func (s *Server) peer(from net.Addr) (*TCPPeer, error) {
+	if s.tcp == nil {
+		return nil, fmt.Errorf("peer %s not known", from)
+	}
+
+	peer, ok := s.tcp.Peer(PeerID(from.String()))
+	if !ok {
+		return nil, fmt.Errorf("peer %s not known", from)
+	}
+
+	return peer, nil
+}
+
 func (s *Server) validatorLoop() {
-	ticker := time.NewTicker(s.BlockTime)
+	ticker := time.NewTicker(s.blockTime)
+	defer ticker.Stop()
 
-	s.Logger.Log("msg", "Starting validator loop", "blockTime", s.BlockTime)
+	s.Logger.Log("msg", "Starting validator loop", "blockTime", s.blockTime)
 
 	for {
 		fmt.Println("creating new block")
@@ -191,7 +466,11 @@ func (s *Server) validatorLoop() {
 			s.Logger.Log("create block error", err)
 		}
 
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-s.quitCh:
+			return
+		}
 	}
 }
 
@@ -209,6 +488,18 @@ func (s *Server) ProcessMessage(msg *DecodedMessage) error {
 		return s.processGetBlocksMessage(msg.From, t)
 	case *BlocksMessage:
 		return s.processBlocksMessage(msg.From, t)
+	case *GetPeersMessage:
+		return s.processGetPeersMessage(msg.From, t)
+	case *PeersMessage:
+		return s.processPeersMessage(msg.From, t)
+	case *ProposalMessage:
+		if s.consensus != nil {
+			return s.consensus.HandleProposal(t)
+		}
+	case *core.Vote:
+		if s.consensus != nil {
+			return s.consensus.HandleVote(t)
+		}
 	}
 
 	return nil
@@ -220,6 +511,7 @@ func (s *Server) processGetBlocksMessage(from net.Addr, data *GetBlocksMessage)
 	var (
 		blocks    = []*core.Block{}
 		ourHeight = s.chain.Height()
+		size      = binary.MaxVarintLen64
 	)
 
 	if data.To == 0 {
@@ -229,6 +521,17 @@ func (s *Server) processGetBlocksMessage(from net.Addr, data *GetBlocksMessage)
 				return err
 			}
 
+			// Stop before the message gets too large, the peer requests
+			// the remaining blocks once it added these.
+			blockBuf := new(bytes.Buffer)
+			if err := block.Encode(s.Codec.NewBlockEncoder(blockBuf)); err != nil {
+				return err
+			}
+			size += blockBuf.Len()
+			if size > MaxMessageSize && len(blocks) > 0 {
+				break
+			}
+
 			blocks = append(blocks, block)
 		}
 	}
@@ -238,45 +541,43 @@ func (s *Server) processGetBlocksMessage(from net.Addr, data *GetBlocksMessage)
 	}
 
 	buf := new(bytes.Buffer)
-	if err := gob.NewEncoder(buf).Encode(blocksMsg); err != nil {
+	if err := blocksMsg.Encode(s.Codec, buf); err != nil {
 		return err
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	msg := NewMessage(MessageTypeBlocks, buf.Bytes())
-	peer, ok := s.peerMap[from]
-	if !ok {
-		return fmt.Errorf("peer %s not known", peer.conn.RemoteAddr())
-	}
 
-	return peer.Send(msg.Bytes())
+	return s.Transport.SendMessage(from, msg.Bytes())
 }
 
-func (s *Server) sendGetStatusMessage(peer *TCPPeer) error {
+func getStatusMessage() ([]byte, error) {
 	var (
 		getStatusMsg = new(GetStatusMessage)
 		buf          = new(bytes.Buffer)
 	)
 	if err := gob.NewEncoder(buf).Encode(getStatusMsg); err != nil {
+		return nil, err
+	}
+
+	return NewMessage(MessageTypeGetStatus, buf.Bytes()).Bytes(), nil
+}
+
+func (s *Server) sendGetStatusMessage(to net.Addr) error {
+	msg, err := getStatusMessage()
+	if err != nil {
 		return err
 	}
 
-	msg := NewMessage(MessageTypeGetStatus, buf.Bytes())
-	return peer.Send(msg.Bytes())
+	return s.Transport.SendMessage(to, msg)
 }
 
-func (s *Server) broadcast(payload []byte) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for netAddr, peer := range s.peerMap {
-		if err := peer.Send(payload); err != nil {
-			fmt.Printf("peer send error => addr %s [err: %s]\n", netAddr, err)
-		}
+func (s *Server) broadcastGetStatus() error {
+	msg, err := getStatusMessage()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return s.Transport.Broadcast(msg)
 }
 
 func (s *Server) processBlocksMessage(from net.Addr, data *BlocksMessage) error {
@@ -295,22 +596,60 @@ func (s *Server) processBlocksMessage(from net.Addr, data *BlocksMessage) error
 func (s *Server) processStatusMessage(from net.Addr, data *StatusMessage) error {
 	s.Logger.Log("msg", "received STATUS message", "from", from)
 
+	if data.ChainID != s.chain.ChainID() || data.GenesisHash != s.chain.GenesisHash() {
+		s.Logger.Log("msg", "refusing to sync with peer of another chain", "chainID", data.ChainID, "genesis", data.GenesisHash, "addr", from)
+		return fmt.Errorf("%w: chain (%s) with genesis (%s)", ErrChainMismatch, data.ChainID, data.GenesisHash)
+	}
+
 	if data.CurrentHeight <= s.chain.Height() {
 		s.Logger.Log("msg", "cannot sync blockHeight to low", "ourHeight", s.chain.Height(), "theirHeight", data.CurrentHeight, "addr", from)
 		return nil
 	}
 
-	go s.requestBlocksLoop(from)
+	s.startSync(from, data.CurrentHeight)
 
 	return nil
 }
 
+// startSync requests blocks from the peer until the chain reaches the given
+// height. If blocks are already requested from the peer, only the height is
+// raised.
+func (s *Server) startSync(peer net.Addr, height uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, running := s.syncing[peer.String()]
+	if height > target {
+		s.syncing[peer.String()] = height
+	}
+	if !running {
+		go s.requestBlocksLoop(peer)
+	}
+}
+
+// stopSync stops the sync loop of the peer if the chain caught up with it or
+// if force is set. It returns true if the loop has to stop.
+func (s *Server) stopSync(peer net.Addr, force bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && s.chain.Height() < s.syncing[peer.String()] {
+		return false
+	}
+	delete(s.syncing, peer.String())
+
+	return true
+}
+
 func (s *Server) processGetStatusMessage(from net.Addr, data *GetStatusMessage) error {
 	s.Logger.Log("msg", "received getStatus message", "from", from)
 
 	statusMessage := &StatusMessage{
 		CurrentHeight: s.chain.Height(),
 		ID:            s.ID,
+		Version:       ProtocolVersion,
+		ChainID:       s.chain.ChainID(),
+		GenesisHash:   s.chain.GenesisHash(),
 	}
 
 	buf := new(bytes.Buffer)
@@ -318,17 +657,9 @@ func (s *Server) processGetStatusMessage(from net.Addr, data *GetStatusMessage)
 		return err
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	peer, ok := s.peerMap[from]
-	if !ok {
-		return fmt.Errorf("peer %s not known", peer.conn.RemoteAddr())
-	}
-
 	msg := NewMessage(MessageTypeStatus, buf.Bytes())
 
-	return peer.Send(msg.Bytes())
+	return s.Transport.SendMessage(from, msg.Bytes())
 }
 
 func (s *Server) processBlock(b *core.Block) error {
@@ -349,7 +680,7 @@ func (s *Server) processTransaction(tx *core.Transaction) error {
 		return nil
 	}
 
-	if err := tx.Verify(); err != nil {
+	if err := s.chain.CheckTransaction(tx, s.mempool.Pending()); err != nil {
 		return err
 	}
 
@@ -366,12 +697,17 @@ func (s *Server) processTransaction(tx *core.Transaction) error {
 	return nil
 }
 
-// TODO: Find a way to make sure we dont keep syncing when we are at the highest
-// block height in the network.
+// requestBlocksLoop requests the blocks above the head from the peer until
+// the chain reaches the height the peer announced, see startSync.
 func (s *Server) requestBlocksLoop(peer net.Addr) error {
 	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
 
 	for {
+		if s.stopSync(peer, false) {
+			s.Logger.Log("msg", "synced with peer", "height", s.chain.Height(), "peer", peer)
+			return nil
+		}
 		ourHeight := s.chain.Height()
 
 		s.Logger.Log("msg", "requesting new blocks", "requesting height", ourHeight+1)
@@ -384,46 +720,46 @@ func (s *Server) requestBlocksLoop(peer net.Addr) error {
 
 		buf := new(bytes.Buffer)
 		if err := gob.NewEncoder(buf).Encode(getBlocksMessage); err != nil {
+			s.stopSync(peer, true)
 			return err
 		}
 
-		s.mu.RLock()
-		defer s.mu.RUnlock()
-
 		msg := NewMessage(MessageTypeGetBlocks, buf.Bytes())
-		peer, ok := s.peerMap[peer]
-		if !ok {
-			return fmt.Errorf("peer %s not known", peer.conn.RemoteAddr())
-		}
-
-		if err := peer.Send(msg.Bytes()); err != nil {
+		if err := s.Transport.SendMessage(peer, msg.Bytes()); err != nil {
 			s.Logger.Log("error", "failed to send to peer", "err", err, "peer", peer)
+			s.stopSync(peer, true)
+			return err
 		}
 
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-s.quitCh:
+			s.stopSync(peer, true)
+			return nil
+		}
 	}
 }
 
 func (s *Server) broadcastBlock(b *core.Block) error {
 	buf := &bytes.Buffer{}
-	if err := b.Encode(core.NewGobBlockEncoder(buf)); err != nil {
+	if err := b.Encode(s.Codec.NewBlockEncoder(buf)); err != nil {
 		return err
 	}
 
 	msg := NewMessage(MessageTypeBlock, buf.Bytes())
 
-	return s.broadcast(msg.Bytes())
+	return s.Transport.Broadcast(msg.Bytes())
 }
 
 func (s *Server) broadcastTx(tx *core.Transaction) error {
 	buf := &bytes.Buffer{}
-	if err := tx.Encode(core.NewGobTxEncoder(buf)); err != nil {
+	if err := tx.Encode(s.Codec.NewTxEncoder(buf)); err != nil {
 		return err
 	}
 
 	msg := NewMessage(MessageTypeTx, buf.Bytes())
 
-	return s.broadcast(msg.Bytes())
+	return s.Transport.Broadcast(msg.Bytes())
 }
 
 func (s *Server) createNewBlock() error {
@@ -436,13 +772,19 @@ func (s *Server) createNewBlock() error {
 	// Later on when we know the internal structure of our transaction
 	// we will implement some kind of complexity function to determine how
 	// many transactions can be included in a block.
-	txx := s.mempool.Pending()
+	//
+	// The transactions are selected with the time of the block, so they
+	// execute the same when the block is validated.
+	timestamp := time.Now().UnixNano()
+	txx := s.chain.ValidTransactions(s.mempool.Pending(), timestamp)
 
 	block, err := core.NewBlockFromPrevHeader(currentHeader, txx)
 	if err != nil {
 		return err
 	}
+	block.Timestamp = timestamp
 
+	block.ChainID = s.chain.ChainID()
 	if err := block.Sign(*s.PrivateKey); err != nil {
 		return err
 	}
@@ -451,36 +793,16 @@ func (s *Server) createNewBlock() error {
 		return err
 	}
 
-	// TODO(@anthdm): pending pool of tx should only reflect on validator nodes.
-	// Right now "normal nodes" does not have their pending pool cleared.
-	s.mempool.ClearPending()
-
 	go s.broadcastBlock(block)
 
 	return nil
 }
 
-func genesisBlock() *core.Block {
-	header := &core.Header{
-		Version:   1,
-		DataHash:  types.Hash{},
-		Height:    0,
-		Timestamp: 000000,
+// defaultGenesis is the genesis of a local development network without
+// validators and allocations.
+func defaultGenesis() *core.Genesis {
+	return &core.Genesis{
+		ChainID:   "projectx-local",
+		BlockTime: defaultBlockTime,
 	}
-
-	b, _ := core.NewBlock(header, nil)
-
-	coinbase := crypto.PublicKey{}
-	tx := core.NewTransaction(nil)
-	tx.From = coinbase
-	tx.To = coinbase
-	tx.Value = 10_000_000
-	b.Transactions = append(b.Transactions, tx)
-
-	privKey := crypto.GeneratePrivateKey()
-	if err := b.Sign(privKey); err != nil {
-		panic(err)
-	}
-
-	return b
 }
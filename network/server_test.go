@@ -0,0 +1,201 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNetwork is an in-process network of servers that talk over
+// LocalTransport.
+type testNetwork struct {
+	t       *testing.T
+	servers []*Server
+}
+
+// newTestNetwork creates n servers of the same chain, the first one is the
+// only one that produces blocks. The servers are neither connected nor
+// started.
+func newTestNetwork(t *testing.T, n int) *testNetwork {
+	privKey := crypto.GeneratePrivateKey()
+
+	tn := &testNetwork{t: t}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("NODE_%d", i)
+		opts := ServerOpts{
+			ID:        id,
+			Logger:    log.NewNopLogger(),
+			Transport: NewLocalTransport(NetAddr(id)),
+			Genesis:   &core.Genesis{ChainID: "test", BlockTime: 100 * time.Millisecond},
+		}
+		if i == 0 {
+			opts.PrivateKey = &privKey
+		}
+
+		s, err := NewServer(opts)
+		assert.Nil(t, err)
+		t.Cleanup(s.Stop)
+
+		tn.servers = append(tn.servers, s)
+	}
+
+	return tn
+}
+
+// connect makes the servers i and j peers of each other.
+func (tn *testNetwork) connect(i, j int) {
+	a, b := tn.servers[i].Transport, tn.servers[j].Transport
+	assert.Nil(tn.t, a.Connect(b))
+	assert.Nil(tn.t, b.Connect(a))
+}
+
+// connectRing connects the first n servers in a ring, blocks and
+// transactions have to be relayed to reach the servers on the other side.
+func (tn *testNetwork) connectRing(n int) {
+	for i := 0; i < n; i++ {
+		tn.connect(i, (i+1)%n)
+	}
+}
+
+func (tn *testNetwork) start(servers ...*Server) {
+	for _, s := range servers {
+		go s.Start()
+	}
+}
+
+// waitForConvergence waits until all servers have the same block at the
+// given height.
+func (tn *testNetwork) waitForConvergence(height uint32) {
+	assert.Eventually(tn.t, func() bool {
+		for _, s := range tn.servers {
+			if s.chain.Height() < height {
+				return false
+			}
+		}
+		return true
+	}, 20*time.Second, 10*time.Millisecond)
+
+	header, err := tn.servers[0].chain.GetHeader(height)
+	assert.Nil(tn.t, err)
+	for _, s := range tn.servers[1:] {
+		other, err := s.chain.GetHeader(height)
+		assert.Nil(tn.t, err)
+		assert.Equal(tn.t, core.BlockHasher{}.Hash(header), core.BlockHasher{}.Hash(other), s.ID)
+	}
+}
+
+// hasTransaction returns true if the chain of the server includes the
+// transaction.
+func hasTransaction(s *Server, hash types.Hash) bool {
+	for h := uint32(1); h <= s.chain.Height(); h++ {
+		block, err := s.chain.GetBlock(h)
+		if err != nil {
+			return false
+		}
+
+		for _, tx := range block.Transactions {
+			if tx.Hash(core.TxHasher{}) == hash {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestLocalNetworkConverges(t *testing.T) {
+	tn := newTestNetwork(t, 10)
+	tn.connectRing(10)
+	tn.start(tn.servers...)
+
+	tn.waitForConvergence(3)
+
+	// A transaction sent to the node on the other side of the ring is
+	// relayed to the block producer and included in the chain of all nodes.
+	tx := core.NewTransaction([]byte("foo"))
+	tx.ChainID = "test"
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	tn.servers[5].txChan <- tx
+
+	hash := tx.Hash(core.TxHasher{})
+	assert.Eventually(t, func() bool {
+		for _, s := range tn.servers {
+			if !hasTransaction(s, hash) {
+				return false
+			}
+		}
+		return true
+	}, 20*time.Second, 10*time.Millisecond)
+
+	// All nodes drop the included transaction from their pool, not only
+	// the block producer.
+	assert.Eventually(t, func() bool {
+		for _, s := range tn.servers {
+			if s.mempool.PendingCount() > 0 {
+				return false
+			}
+		}
+		return true
+	}, 20*time.Second, 10*time.Millisecond)
+
+	tn.waitForConvergence(tn.servers[0].chain.Height())
+}
+
+func TestLocalNetworkSyncsLateNode(t *testing.T) {
+	tn := newTestNetwork(t, 10)
+	tn.connectRing(9)
+	tn.start(tn.servers[:9]...)
+
+	assert.Eventually(t, func() bool {
+		return tn.servers[0].chain.Height() >= 5
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The last node joins through a single peer and catches up with the
+	// blocks it missed.
+	tn.connect(9, 4)
+	tn.start(tn.servers[9])
+
+	tn.waitForConvergence(tn.servers[0].chain.Height())
+}
+
+func TestServerSyncsOncePerPeer(t *testing.T) {
+	tn := newTestNetwork(t, 2)
+	tn.connect(0, 1)
+	s, peer := tn.servers[0], tn.servers[1]
+
+	requests := make(chan RPC, 10)
+	go func() {
+		for rpc := range peer.Transport.Consume() {
+			requests <- rpc
+		}
+	}()
+
+	// Repeated status messages of the same peer share one sync loop.
+	status := &StatusMessage{CurrentHeight: 1, ChainID: s.chain.ChainID(), GenesisHash: s.chain.GenesisHash()}
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, s.processStatusMessage(peer.Transport.Addr(), status))
+	}
+	<-requests
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, len(requests))
+
+	// The loop stops once the chain caught up with the peer.
+	prevHeader, err := s.chain.GetHeader(0)
+	assert.Nil(t, err)
+	b, err := core.NewBlockFromPrevHeader(prevHeader, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, b.Sign(crypto.GeneratePrivateKey()))
+	assert.Nil(t, s.chain.AddBlock(b))
+
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.syncing) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
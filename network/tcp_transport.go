@@ -1,53 +1,240 @@
 package network
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
+
+	"github.com/anthdm/projectx/crypto"
 )
 
 type TCPPeer struct {
 	conn     net.Conn
 	Outgoing bool
+	// Address the peer was dialed on, empty for incoming connections.
+	DialAddr string
+	// ID and ListenAddr of the peer, they are known after the handshake.
+	ID         PeerID
+	ListenAddr string
 }
 
+// advertisedAddr is the address other nodes can reach the peer on, it is
+// empty if the peer does not accept connections.
+func (p *TCPPeer) advertisedAddr() string {
+	host, port, err := net.SplitHostPort(p.ListenAddr)
+	if err != nil || len(port) == 0 || port == "0" {
+		return ""
+	}
+
+	// Nodes listening on all interfaces are reached on the address they
+	// connected from.
+	if ip := net.ParseIP(host); len(host) == 0 || (ip != nil && ip.IsUnspecified()) {
+		host, _, err = net.SplitHostPort(p.conn.RemoteAddr().String())
+		if err != nil {
+			return ""
+		}
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// Send writes a framed message, as returned by Message.Bytes, to the peer.
 func (p *TCPPeer) Send(b []byte) error {
+	if len(b) == 0 {
+		return fmt.Errorf("empty message")
+	}
+
 	_, err := p.conn.Write(b)
 	return err
 }
 
-func (p *TCPPeer) readLoop(rpcCh chan RPC) {
-	buf := make([]byte, 4096)
+// readLoop reads messages from the peer until the connection is closed or
+// the peer sends a malformed frame, after which the stream is out of sync
+// and the connection is closed. It returns nil if the connection was
+// closed by either side.
+func (p *TCPPeer) readLoop(rpcCh chan RPC) error {
+	defer p.conn.Close()
+
+	dec := NewMessageDecoder(bufio.NewReader(p.conn))
 	for {
-		n, err := p.conn.Read(buf)
-		if err == io.EOF {
-			continue
+		msg, err := dec.Decode()
+		if err == io.EOF || errors.Is(err, net.ErrClosed) {
+			return nil
 		}
 		if err != nil {
-			fmt.Printf("read error: %s", err)
-			continue
+			return err
 		}
 
-		msg := buf[:n]
 		rpcCh <- RPC{
-			From:    p.conn.RemoteAddr(),
-			Payload: bytes.NewReader(msg),
+			From:    p.ID,
+			Payload: bytes.NewReader(msg.Bytes()),
 		}
 	}
 }
 
+// TCPTransport connects nodes over TCP. New connections are handed to the
+// consumer of the peer channel, which adds them with AddPeer once they
+// completed the handshake.
 type TCPTransport struct {
 	peerCh     chan *TCPPeer
+	rpcCh      chan RPC
 	listenAddr string
-	listener   net.Listener
+
+	mu       sync.RWMutex
+	listener net.Listener
+	peers    map[PeerID]*TCPPeer
+	// If set all connections are wrapped with TLS.
+	tlsConfig *tls.Config
 }
 
 func NewTCPTransport(addr string, peerCh chan *TCPPeer) *TCPTransport {
 	return &TCPTransport{
 		peerCh:     peerCh,
+		rpcCh:      make(chan RPC),
 		listenAddr: addr,
+		peers:      make(map[PeerID]*TCPPeer),
+	}
+}
+
+// EnableTLS encrypts and authenticates all connections with mutual TLS
+// using a self-signed certificate of the node key. All nodes of a network
+// have to enable it.
+func (t *TCPTransport) EnableTLS(nodeKey crypto.PrivateKey) error {
+	cfg, err := newTLSConfig(nodeKey)
+	if err != nil {
+		return err
+	}
+
+	t.tlsConfig = cfg
+
+	return nil
+}
+
+// Dial connects to the node listening on addr.
+func (t *TCPTransport) Dial(addr string) (*TCPPeer, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
 	}
+
+	if t.tlsConfig != nil {
+		conn = tls.Client(conn, t.tlsConfig)
+	}
+
+	return &TCPPeer{
+		conn:     conn,
+		Outgoing: true,
+		DialAddr: addr,
+	}, nil
+}
+
+// Consume returns the messages received from all peers.
+func (t *TCPTransport) Consume() <-chan RPC {
+	return t.rpcCh
+}
+
+// Connect dials the node of the given transport. Like incoming connections
+// the peer is handed to the consumer of the peer channel.
+func (t *TCPTransport) Connect(tr Transport) error {
+	addr := tr.Addr()
+	if addr == nil {
+		return fmt.Errorf("could not connect to transport that is not listening")
+	}
+
+	peer, err := t.Dial(addr.String())
+	if err != nil {
+		return err
+	}
+
+	t.peerCh <- peer
+
+	return nil
+}
+
+// SendMessage sends a framed message to the peer with the given ID.
+func (t *TCPTransport) SendMessage(to net.Addr, payload []byte) error {
+	peer, ok := t.Peer(PeerID(to.String()))
+	if !ok {
+		return fmt.Errorf("could not send message to unknown peer %s", to)
+	}
+
+	return peer.Send(payload)
+}
+
+// Broadcast sends a framed message to all peers.
+func (t *TCPTransport) Broadcast(payload []byte) error {
+	var errs []error
+	for _, peer := range t.Peers() {
+		if err := peer.Send(payload); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// AddPeer adds a peer that completed the handshake, it replaces a known
+// peer with the same ID.
+func (t *TCPTransport) AddPeer(peer *TCPPeer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.peers[peer.ID] = peer
+}
+
+// RemovePeer removes the peer, unless it was replaced by another connection
+// to the same node already. It returns true if the peer was removed.
+func (t *TCPTransport) RemovePeer(peer *TCPPeer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.peers[peer.ID] != peer {
+		return false
+	}
+
+	delete(t.peers, peer.ID)
+
+	return true
+}
+
+// Peer returns the peer with the given ID.
+func (t *TCPTransport) Peer(id PeerID) (*TCPPeer, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	peer, ok := t.peers[id]
+	return peer, ok
+}
+
+// Peers returns all peers.
+func (t *TCPTransport) Peers() []*TCPPeer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	peers := make([]*TCPPeer, 0, len(t.peers))
+	for _, peer := range t.peers {
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
+
+// Addr returns the address the transport listens on, nil if it is not
+// started.
+func (t *TCPTransport) Addr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.listener == nil {
+		return nil
+	}
+
+	return t.listener.Addr()
 }
 
 func (t *TCPTransport) Start() error {
@@ -56,21 +243,47 @@ func (t *TCPTransport) Start() error {
 		return err
 	}
 
+	t.mu.Lock()
 	t.listener = ln
+	t.mu.Unlock()
 
-	go t.acceptLoop()
+	go t.acceptLoop(ln)
 
 	return nil
 }
 
-func (t *TCPTransport) acceptLoop() {
+// Close stops accepting connections and closes the connections to all
+// peers.
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, peer := range t.peers {
+		peer.conn.Close()
+	}
+
+	if t.listener == nil {
+		return nil
+	}
+
+	return t.listener.Close()
+}
+
+func (t *TCPTransport) acceptLoop(ln net.Listener) {
 	for {
-		conn, err := t.listener.Accept()
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			fmt.Printf("accept error from %+v\n", conn)
 			continue
 		}
 
+		if t.tlsConfig != nil {
+			conn = tls.Server(conn, t.tlsConfig)
+		}
+
 		peer := &TCPPeer{
 			conn: conn,
 		}
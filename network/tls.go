@@ -0,0 +1,111 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/anthdm/projectx/crypto"
+)
+
+// newTLSConfig returns the config for mutual TLS between nodes. Every node
+// presents a self-signed certificate of its node key. There is no CA, the
+// certificate of a peer is accepted if it is a valid P-256 certificate, the
+// handshake then checks that it belongs to the node key of the peer, see
+// checkTLSNodeKey.
+func newTLSConfig(nodeKey crypto.PrivateKey) (*tls.Config, error) {
+	cert, err := newNodeCertificate(nodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+		ClientAuth:   tls.RequireAnyClientCert,
+		// The chain is verified by VerifyPeerCertificate, for both the
+		// client and the server.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyNodeCertificate,
+	}, nil
+}
+
+func newNodeCertificate(nodeKey crypto.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: string(NewPeerID(nodeKey.PublicKey()))},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer := nodeKey.Signer()
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  signer,
+	}, nil
+}
+
+func verifyNodeCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) != 1 {
+		return fmt.Errorf("expected a single node certificate, got %d", len(rawCerts))
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return fmt.Errorf("node certificate has no P-256 key")
+	}
+
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature)
+}
+
+// checkTLSNodeKey checks that the TLS certificate of the peer belongs to the
+// node key it proved to own in the handshake. Otherwise a man in the middle
+// could relay the handshake between two nodes. Connections without TLS are
+// not checked.
+func checkTLSNodeKey(conn net.Conn, nodeKey crypto.PublicKey) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("peer has no TLS certificate")
+	}
+
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), nodeKey)
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     x,
+		Y:     y,
+	}
+	if !key.Equal(certs[0].PublicKey) {
+		return fmt.Errorf("TLS certificate does not belong to node key (%s)", nodeKey)
+	}
+
+	return nil
+}
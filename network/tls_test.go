@@ -0,0 +1,165 @@
+package network
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTLSTestTransport starts a TCPTransport on a loopback port.
+func newTLSTestTransport(t *testing.T, nodeKey *crypto.PrivateKey) *TCPTransport {
+	tr := NewTCPTransport("127.0.0.1:0", make(chan *TCPPeer, 1))
+	if nodeKey != nil {
+		assert.Nil(t, tr.EnableTLS(*nodeKey))
+	}
+	assert.Nil(t, tr.Start())
+	t.Cleanup(func() { tr.Close() })
+
+	return tr
+}
+
+// recordingProxy forwards connections to addr and records everything the
+// client sends.
+type recordingProxy struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (p *recordingProxy) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.Write(b)
+}
+
+func (p *recordingProxy) Bytes() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return bytes.Clone(p.buf.Bytes())
+}
+
+func startRecordingProxy(t *testing.T, addr string) (*recordingProxy, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	proxy := &recordingProxy{}
+	go func() {
+		client, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", addr)
+		if err != nil {
+			client.Close()
+			return
+		}
+
+		go io.Copy(client, server)
+		io.Copy(io.MultiWriter(server, proxy), client)
+	}()
+
+	return proxy, ln.Addr().String()
+}
+
+func TestTCPTransportTLS(t *testing.T) {
+	keyA := crypto.GeneratePrivateKey()
+	keyB := crypto.GeneratePrivateKey()
+	a := newTLSTestTransport(t, &keyA)
+	b := newTLSTestTransport(t, &keyB)
+
+	proxy, proxyAddr := startRecordingProxy(t, b.listener.Addr().String())
+
+	outgoing, err := a.Dial(proxyAddr)
+	assert.Nil(t, err)
+	defer outgoing.conn.Close()
+	incoming := <-b.peerCh
+	defer incoming.conn.Close()
+
+	secret := []byte("vote for alice in the election")
+	go outgoing.Send(NewMessage(MessageTypeTx, secret).Bytes())
+
+	msg, err := NewMessageDecoder(incoming.conn).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, secret, msg.Data)
+
+	assert.Nil(t, incoming.Send(NewMessage(MessageTypeGetStatus, nil).Bytes()))
+	msg, err = NewMessageDecoder(outgoing.conn).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, MessageTypeGetStatus, msg.Header)
+
+	// Nothing is sent in cleartext.
+	assert.NotEmpty(t, proxy.Bytes())
+	assert.False(t, bytes.Contains(proxy.Bytes(), secret))
+
+	for _, conn := range []net.Conn{outgoing.conn, incoming.conn} {
+		tlsConn, ok := conn.(*tls.Conn)
+		assert.True(t, ok)
+		assert.Equal(t, uint16(tls.VersionTLS13), tlsConn.ConnectionState().Version)
+	}
+
+	// Both sides know the node key of the other side.
+	assert.Nil(t, checkTLSNodeKey(outgoing.conn, keyB.PublicKey()))
+	assert.Nil(t, checkTLSNodeKey(incoming.conn, keyA.PublicKey()))
+	assert.NotNil(t, checkTLSNodeKey(outgoing.conn, keyA.PublicKey()))
+}
+
+func TestTCPTransportTLSRejectsPlainPeers(t *testing.T) {
+	key := crypto.GeneratePrivateKey()
+	secure := newTLSTestTransport(t, &key)
+	plain := newTLSTestTransport(t, nil)
+
+	outgoing, err := plain.Dial(secure.listener.Addr().String())
+	assert.Nil(t, err)
+	defer outgoing.conn.Close()
+	incoming := <-secure.peerCh
+	defer incoming.conn.Close()
+
+	other := crypto.GeneratePrivateKey()
+	go handshake(outgoing.conn, newTestHandshake("test", other), other)
+
+	_, err = handshake(incoming.conn, newTestHandshake("test", key), key)
+	assert.NotNil(t, err)
+}
+
+func TestServerRejectsTLSKeyOfOtherNode(t *testing.T) {
+	s, err := NewServer(ServerOpts{
+		Logger:           log.NewNopLogger(),
+		ListenAddr:       "127.0.0.1:0",
+		Genesis:          &core.Genesis{ChainID: "test"},
+		EncryptTransport: true,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, s.tcp.Start())
+	defer s.tcp.Close()
+
+	// The peer presents the certificate of one key, but proves to own
+	// another one in the handshake.
+	tlsKey := crypto.GeneratePrivateKey()
+	nodeKey := crypto.GeneratePrivateKey()
+	tr := newTLSTestTransport(t, &tlsKey)
+
+	peer, err := tr.Dial(s.tcp.Addr().String())
+	assert.Nil(t, err)
+	defer peer.conn.Close()
+	go s.addPeer(<-s.tcp.peerCh)
+
+	remote, err := handshake(peer.conn, newTestHandshake("test", nodeKey), nodeKey)
+	assert.Nil(t, err)
+	assert.Equal(t, s.NodeKey.PublicKey(), remote.NodeKey)
+
+	// The server closes the connection instead of adding the peer.
+	assert.Nil(t, peer.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = NewMessageDecoder(peer.conn).Decode()
+	assert.NotNil(t, err)
+
+	assert.Empty(t, s.tcp.Peers())
+}
@@ -11,3 +11,11 @@ type Transport interface {
 	Broadcast([]byte) error
 	Addr() net.Addr
 }
+
+func (a NetAddr) Network() string {
+	return "local"
+}
+
+func (a NetAddr) String() string {
+	return string(a)
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/anthdm/projectx/core"
@@ -40,9 +41,52 @@ func (p *TxPool) Contains(hash types.Hash) bool {
 	return p.all.Contains(hash)
 }
 
-// Pending returns a slice of transactions that are in the pending pool
+// IsPending returns true if the transaction is waiting to be included in a
+// block.
+func (p *TxPool) IsPending(hash types.Hash) bool {
+	return p.pending.Contains(hash)
+}
+
+// Pending returns a slice of transactions that are in the pending pool. The
+// transactions of a sender are ordered by nonce, senders are ordered by the
+// arrival of their first transaction.
 func (p *TxPool) Pending() []*core.Transaction {
-	return p.pending.txx.Data
+	p.pending.lock.RLock()
+	txx := make([]*core.Transaction, len(p.pending.txx.Data))
+	copy(txx, p.pending.txx.Data)
+	p.pending.lock.RUnlock()
+
+	senders := []string{}
+	bySender := make(map[string][]*core.Transaction)
+	for _, tx := range txx {
+		sender := string(tx.From)
+		if _, ok := bySender[sender]; !ok {
+			senders = append(senders, sender)
+		}
+		bySender[sender] = append(bySender[sender], tx)
+	}
+
+	sorted := make([]*core.Transaction, 0, len(txx))
+	for _, sender := range senders {
+		group := bySender[sender]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Nonce < group[j].Nonce
+		})
+		sorted = append(sorted, group...)
+	}
+
+	return sorted
+}
+
+// Prune removes the pending transactions whose nonce is used up, because
+// they were included in a block or replaced by another transaction with the
+// same nonce. Transactions with a future nonce stay queued.
+func (p *TxPool) Prune(nextNonce func(types.Address) uint64) {
+	for _, tx := range p.Pending() {
+		if tx.Nonce < nextNonce(tx.From.Address()) {
+			p.pending.Remove(tx.Hash(core.TxHasher{}))
+		}
+	}
 }
 
 func (p *TxPool) ClearPending() {
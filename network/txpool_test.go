@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/anthdm/projectx/core"
+	"github.com/anthdm/projectx/crypto"
+	"github.com/anthdm/projectx/types"
 	"github.com/anthdm/projectx/util"
 	"github.com/stretchr/testify/assert"
 )
@@ -105,3 +107,31 @@ func TestTxSortedMapRemove(t *testing.T) {
 	assert.Equal(t, m.Count(), 0)
 	assert.False(t, m.Contains(tx.Hash(core.TxHasher{})))
 }
+
+func TestTxPoolPendingOrderedByNonce(t *testing.T) {
+	p := NewTxPool(10)
+	alice := crypto.GeneratePrivateKey()
+	bob := crypto.GeneratePrivateKey()
+
+	newTx := func(key crypto.PrivateKey, nonce uint64) *core.Transaction {
+		tx := core.NewTransaction(nil)
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(key))
+		return tx
+	}
+
+	a2, b0, a0, a1 := newTx(alice, 2), newTx(bob, 0), newTx(alice, 0), newTx(alice, 1)
+	for _, tx := range []*core.Transaction{a2, b0, a0, a1} {
+		p.Add(tx)
+	}
+	assert.Equal(t, []*core.Transaction{a0, a1, a2, b0}, p.Pending())
+
+	// Alice has two transactions on the chain, the third one stays queued.
+	p.Prune(func(addr types.Address) uint64 {
+		if addr == alice.PublicKey().Address() {
+			return 2
+		}
+		return 0
+	})
+	assert.Equal(t, []*core.Transaction{a2, b0}, p.Pending())
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Delivery is a single attempt to deliver an event to a webhook endpoint.
+type Delivery struct {
+	ID         string `json:"id"`
+	Event      string `json:"event"`
+	URL        string `json:"url"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Success    bool   `json:"success"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// DeliveryLog keeps track of all delivery attempts. If a path is given every
+// entry is appended to that file as a JSON line so the log survives restarts.
+type DeliveryLog struct {
+	mu         sync.RWMutex
+	path       string
+	deliveries []Delivery
+}
+
+// NewDeliveryLog opens (or creates) the delivery log at path. An empty path
+// results in an in-memory log.
+func NewDeliveryLog(path string) (*DeliveryLog, error) {
+	l := &DeliveryLog{
+		path:       path,
+		deliveries: []Delivery{},
+	}
+
+	if len(path) == 0 {
+		return l, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, err
+		}
+		l.deliveries = append(l.deliveries, d)
+	}
+
+	return l, scanner.Err()
+}
+
+func (l *DeliveryLog) Append(d Delivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.deliveries = append(l.deliveries, d)
+
+	if len(l.path) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(d)
+}
+
+// Deliveries returns a copy of all logged delivery attempts.
+func (l *DeliveryLog) Deliveries() []Delivery {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	deliveries := make([]Delivery, len(l.deliveries))
+	copy(deliveries, l.deliveries)
+
+	return deliveries
+}
@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/go-kit/log"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	defaultMaxRetries   = 5
+	defaultRetryBackoff = time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultQueueSize    = 1024
+)
+
+type Config struct {
+	Logger log.Logger
+	URL    string
+	// Events the subscriber is interested in. If empty all events are delivered.
+	Events []core.VotingEventType
+	// Secret used to sign the payload with HMAC-SHA256.
+	Secret       string
+	MaxRetries   int
+	RetryBackoff time.Duration
+	Timeout      time.Duration
+	// Path of the delivery log file. If empty the log is kept in memory.
+	DeliveryLogPath string
+}
+
+// Payload is the JSON body that is POSTed to the webhook URL.
+type Payload struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	ElectionID  string `json:"electionId,omitempty"`
+	VoterID     string `json:"voterId,omitempty"`
+	CandidateID string `json:"candidateId,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+type Subscriber struct {
+	Config
+	client *http.Client
+	log    *DeliveryLog
+	queue  chan Payload
+	quitCh chan struct{}
+
+	mu sync.Mutex
+	// Events that were handled before. Blocks on the new branch of a reorg
+	// are executed again and emit the same events.
+	handled map[string]bool
+}
+
+func NewSubscriber(cfg Config) (*Subscriber, error) {
+	if len(cfg.URL) == 0 {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.RetryBackoff == time.Duration(0) {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.Timeout == time.Duration(0) {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.NewNopLogger()
+	}
+
+	deliveryLog, err := NewDeliveryLog(cfg.DeliveryLogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscriber{
+		Config:  cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		log:     deliveryLog,
+		queue:   make(chan Payload, defaultQueueSize),
+		quitCh:  make(chan struct{}),
+		handled: make(map[string]bool),
+	}, nil
+}
+
+// Handle can be registered as a core.VotingEventHandler. It never blocks, if
+// the queue is full the event is dropped and recorded in the delivery log.
+// Every event is delivered once, also if it is emitted again.
+func (s *Subscriber) Handle(ev core.VotingEvent) {
+	if !s.accepts(ev.Type) || !s.firstTime(ev) {
+		return
+	}
+
+	payload := Payload{
+		ID:          newDeliveryID(),
+		Type:        string(ev.Type),
+		ElectionID:  ev.ElectionID,
+		VoterID:     ev.VoterID,
+		CandidateID: ev.CandidateID,
+		Timestamp:   ev.Timestamp,
+	}
+
+	select {
+	case s.queue <- payload:
+	default:
+		s.record(payload, 0, 0, fmt.Errorf("delivery queue is full"))
+	}
+}
+
+// Start delivers queued events until Stop is called.
+func (s *Subscriber) Start() {
+	for {
+		select {
+		case payload := <-s.queue:
+			s.deliver(payload)
+		case <-s.quitCh:
+			return
+		}
+	}
+}
+
+func (s *Subscriber) Stop() {
+	close(s.quitCh)
+}
+
+// DeliveryLog returns the log of all delivery attempts of this subscriber.
+func (s *Subscriber) DeliveryLog() *DeliveryLog {
+	return s.log
+}
+
+// firstTime reports whether the event is handled for the first time. Each
+// kind of event happens at most once per election, voter or candidate.
+func (s *Subscriber) firstTime(ev core.VotingEvent) bool {
+	key := strings.Join([]string{string(ev.Type), ev.ElectionID, ev.VoterID, ev.CandidateID}, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.handled[key] {
+		return false
+	}
+	s.handled[key] = true
+
+	return true
+}
+
+func (s *Subscriber) accepts(t core.VotingEventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, et := range s.Events {
+		if et == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Subscriber) deliver(payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.record(payload, 0, 0, err)
+		return
+	}
+
+	backoff := s.RetryBackoff
+	for attempt := 1; attempt <= s.MaxRetries+1; attempt++ {
+		statusCode, err := s.post(payload, body)
+		s.record(payload, attempt, statusCode, err)
+		if err == nil {
+			return
+		}
+
+		s.Logger.Log("msg", "webhook delivery failed", "url", s.URL, "attempt", attempt, "err", err)
+
+		if attempt > s.MaxRetries {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.quitCh:
+			return
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Subscriber) post(payload Payload, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, payload.Type)
+	req.Header.Set(DeliveryHeader, payload.ID)
+	if len(s.Secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign([]byte(s.Secret), body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s *Subscriber) record(payload Payload, attempt, statusCode int, err error) {
+	d := Delivery{
+		ID:         payload.ID,
+		Event:      payload.Type,
+		URL:        s.URL,
+		Attempt:    attempt,
+		StatusCode: statusCode,
+		Success:    err == nil,
+		Timestamp:  time.Now().Unix(),
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	if err := s.log.Append(d); err != nil {
+		s.Logger.Log("msg", "failed to write webhook delivery log", "err", err)
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using secret. Receivers
+// can use it to verify the X-Webhook-Signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
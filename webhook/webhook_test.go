@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/projectx/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriberDeliverSigned(t *testing.T) {
+	secret := "supersecret"
+	received := make(chan Payload, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "sha256="+Sign([]byte(secret), body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, string(core.VotingEventElectionOpened), r.Header.Get(EventHeader))
+
+		var payload Payload
+		assert.Nil(t, json.Unmarshal(body, &payload))
+		received <- payload
+	}))
+	defer srv.Close()
+
+	sub, err := NewSubscriber(Config{URL: srv.URL, Secret: secret})
+	assert.Nil(t, err)
+	go sub.Start()
+	defer sub.Stop()
+
+	sub.Handle(core.VotingEvent{Type: core.VotingEventElectionOpened, ElectionID: "e1", Timestamp: 1})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "e1", payload.ElectionID)
+		assert.Equal(t, int64(1), payload.Timestamp)
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestSubscriberEventFilter(t *testing.T) {
+	sub, err := NewSubscriber(Config{
+		URL:    "http://localhost",
+		Events: []core.VotingEventType{core.VotingEventElectionClosed},
+	})
+	assert.Nil(t, err)
+
+	sub.Handle(core.VotingEvent{Type: core.VotingEventElectionOpened})
+	assert.Equal(t, 0, len(sub.queue))
+
+	sub.Handle(core.VotingEvent{Type: core.VotingEventElectionClosed})
+	assert.Equal(t, 1, len(sub.queue))
+}
+
+func TestSubscriberDeduplicatesEvents(t *testing.T) {
+	sub, err := NewSubscriber(Config{URL: "http://localhost"})
+	assert.Nil(t, err)
+
+	// Blocks that are executed again after a reorg emit the same events.
+	ev := core.VotingEvent{Type: core.VotingEventVoterPending, VoterID: "v1", Timestamp: 10}
+	sub.Handle(ev)
+	sub.Handle(ev)
+	assert.Equal(t, 1, len(sub.queue))
+
+	sub.Handle(core.VotingEvent{Type: core.VotingEventVoterPending, VoterID: "v2", Timestamp: 10})
+	sub.Handle(core.VotingEvent{Type: core.VotingEventCandidatePending, ElectionID: "e1", CandidateID: "v1", Timestamp: 10})
+	assert.Equal(t, 3, len(sub.queue))
+	assert.Equal(t, int64(10), (<-sub.queue).Timestamp)
+}
+
+func TestSubscriberRetryAndPersistLog(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	logPath := filepath.Join(t.TempDir(), "deliveries.log")
+	sub, err := NewSubscriber(Config{
+		URL:             srv.URL,
+		RetryBackoff:    time.Millisecond,
+		DeliveryLogPath: logPath,
+	})
+	assert.Nil(t, err)
+
+	sub.deliver(Payload{ID: "foo", Type: string(core.VotingEventVoterPending)})
+
+	deliveries := sub.DeliveryLog().Deliveries()
+	assert.Equal(t, 3, len(deliveries))
+	assert.False(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	assert.True(t, deliveries[2].Success)
+	assert.Equal(t, 3, deliveries[2].Attempt)
+
+	reopened, err := NewDeliveryLog(logPath)
+	assert.Nil(t, err)
+	assert.Equal(t, deliveries, reopened.Deliveries())
+}
+
+func TestSubscriberGiveUpAfterMaxRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	sub, err := NewSubscriber(Config{
+		URL:          srv.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+	assert.Nil(t, err)
+
+	sub.deliver(Payload{ID: "foo", Type: string(core.VotingEventElectionClosed)})
+
+	deliveries := sub.DeliveryLog().Deliveries()
+	assert.Equal(t, 3, len(deliveries))
+	for _, d := range deliveries {
+		assert.False(t, d.Success)
+	}
+}